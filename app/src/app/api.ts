@@ -99,11 +99,19 @@ export enum BallotType {
   // API types.
   Closed = 0,
   Uninominal,
+  Approval,
+  Ranked,
+  Grading,
 }
 
 export enum InformationType {
   NoneYet,
   Counts,
+  Grades,
+  Ranking,
+  Winner,
+  Top,
+  Hidden,
 }
 
 export class PollAnswer {
@@ -123,6 +131,9 @@ export class PollAnswer {
   MaxNbRounds:      number;
   Ballot:           BallotType;
   Information:      InformationType;
+  Sequential:       boolean;
+  YourTurn:         boolean;
+  Sealed:           boolean;
 
   static fromJSON(raw: string): PollAnswer {
     return JSON.parse(raw, function(key: string, value: any): any{
@@ -159,19 +170,146 @@ export interface UninominalVoteQuery {
   Round:        number;
 }
 
+export interface ApprovalBallotAnswer {
+  Previous?:         number[];
+  PreviousIsBlank?:  boolean;
+  Current?:          number[];
+  CurrentIsBlank?:   boolean;
+  MaxBallotCost:     number;
+  BallotCostIsCount: boolean;
+  Alternatives:      Array<PollAlternative>;
+}
+
+export interface ApprovalVoteQuery {
+  Alternatives: number[]; // Empty for blank votes.
+  Round:        number;
+}
+
+export interface RankedBallotAnswer {
+  Previous?:        number[]; // From the most preferred to the least preferred.
+  PreviousIsBlank?: boolean;
+  Current?:         number[]; // From the most preferred to the least preferred.
+  CurrentIsBlank?:  boolean;
+  Alternatives:     Array<PollAlternative>;
+}
+
+export interface RankedVoteQuery {
+  Ranking: number[]; // Empty for blank votes.
+  Round:   number;
+}
+
+export interface GradingBallotAnswer {
+  Previous?:        number[]; // Grade of each alternative.
+  PreviousIsBlank?: boolean;
+  Current?:         number[]; // Grade of each alternative.
+  CurrentIsBlank?:  boolean;
+  Grades:           string[]; // From the worst to the best.
+  Alternatives:     Array<PollAlternative>;
+}
+
+export interface DelegateQuery {
+  Delegate: string; // Name of the delegate. Empty to cancel the delegation.
+  Round:    number;
+}
+
+export interface GradingVoteQuery {
+  Grades: number[]; // Grade of each alternative. Empty for blank votes.
+  Round:  number;
+}
+
 export interface CountInfoEntry {
   Alternative: PollAlternative;
   Count: number;
+  Score: number;
 }
 
 export interface CountInfoAnswer {
+  Result:    Array<CountInfoEntry>;
+  Delegated: number; // Number of ballots cast through delegation.
+}
+
+export interface BudgetInfoAnswer {
+  Budget: number;
+  Spent:  number;
+  Funded: Array<PollAlternative>;
+}
+
+export interface GradesInfoEntry {
+  Alternative:  PollAlternative;
+  Distribution: number[]; // Number of participants for each grade.
+  Score:        number;
+}
+
+export interface GradesInfoAnswer {
+  Grades: string[]; // From the worst to the best.
+  Result: Array<GradesInfoEntry>;
+}
+
+export interface RankingInfoAnswer {
+  Ranking: Array<PollAlternative>; // From the best to the worst.
+}
+
+export interface WinnerInfoAnswer {
+  Winner: PollAlternative;
+}
+
+export interface TopInfoAnswer {
   Result: Array<CountInfoEntry>;
 }
 
+export interface HistoryInfoRound {
+  Round:     number;
+  Turnout:   number; // Total weight, including blank ballots.
+  Blank:     number;
+  Delegated: number; // Number of ballots cast through delegation.
+  Result:    Array<CountInfoEntry>;
+}
+
+export interface HistoryInfoAnswer {
+  Rounds: Array<HistoryInfoRound>; // From the first round to the last completed one.
+}
+
+export interface TransitionsInfoAnswer {
+  From:         number;
+  To:           number;
+  Alternatives: Array<PollAlternative>;
+  // Rows are states during From, columns states during To. States are the alternatives, then blank,
+  // then abstention. Hidden counts are -1.
+  Matrix:       number[][];
+}
+
 export enum Electorate {
   All = -1,
   Logged,
-  Verified
+  Verified,
+  Invited,
+}
+
+export enum PollRule {
+  Plurality,
+  Borda,
+  Copeland,
+  Schulze,
+  InstantRunoff,
+  GreedyApproval,
+  EqualShares,
+  MajorityJudgment,
+  RangeVoting,
+}
+
+export enum PollInformation {
+  Counts,
+  Ranking,
+  Winner,
+  Top,
+  None,
+}
+
+export enum RoundType {
+  FreelyAsynchronous,
+  Sequential,
+  RandomSequential,
+  Synchronous,
 }
 
 export interface SimpleAlternative {
@@ -193,6 +331,100 @@ export interface CreateQuery {
   MaxRoundDuration: number; // milliseconds
   RoundThreshold:   number;
   ShortURL:         string;
+  Ballot?:            BallotType; // Uninominal by default.
+  MaxBallotCost?:     number;     // Number of alternatives by default.
+  BallotCostIsCount?: boolean;    // True by default.
+  Rule?:              PollRule;   // Plurality by default.
+  MaxOutcomeCost?:    number;     // Highest cost of the alternatives by default.
+  Grades?:            string[];   // Only for grading polls. From the worst to the best.
+  Information?:       PollInformation; // Counts by default.
+  InformationTop?:    number;          // 3 by default.
+  RoundType?:         RoundType;       // FreelyAsynchronous by default.
+  MinCellSize?:       number;          // Smallest count in transition matrices. 0 by default.
+  StopStableProfile?: boolean;         // False by default.
+  StopStableWinner?:  number;          // Number of rounds. 0 (disabled) by default.
+  ThresholdOnWeight?: boolean;         // False by default.
+  LockComments?:      boolean;         // False by default.
+  Proposals?:         boolean;         // False by default. Requires Start.
+}
+
+export interface CloneQuery {
+  Start?:    Date;   // Immediately, without proposals, by default.
+  Deadline?: Date;   // Same duration as the original poll by default.
+  ShortURL?: string;
+}
+
+export interface InviteQuery {
+  Emails:  string[];
+  Weight?: number; // 1 by default.
+}
+
+export interface InvitationEntry {
+  Email:    string;
+  Weight:   number;
+  Redeemed: boolean;
+  Sent:     Date;
+}
+
+export interface ParticipantEntry {
+  Name:      string;
+  LastRound: number;
+  Weight:    number;
+}
+
+export interface ParticipantsAnswer {
+  Logged:   ParticipantEntry[];
+  Unlogged: number; // Number of unlogged participants.
+  Blocked:  string[];
+}
+
+export interface ParticipantQuery {
+  Name: string;
+}
+
+export interface WeightQuery {
+  Name:   string;
+  Weight: number;
+}
+
+export interface ProposalQuery {
+  Name:  string;
+  Cost?: number; // 1 by default.
+}
+
+export interface ProposalDecisionQuery {
+  Id:           number;
+  Alternative?: number; // Only for merge requests.
+}
+
+export interface ProposalEntry {
+  Id:          number;
+  Name:        string;
+  Cost:        number;
+  Author:      string;
+  Pending:     boolean;
+  Alternative: number; // Only meaningful when Pending is false.
+}
+
+export interface CommentQuery {
+  Round:   number;
+  Content: string;
+}
+
+export interface CommentDeleteQuery {
+  Id: number;
+}
+
+export interface CommentEntry {
+  Id:      number;
+  Author:  string;
+  Posted:  Date;
+  Content: string;
+}
+
+export interface CommentsAnswer {
+  Comments: CommentEntry[]; // From the oldest to the most recent.
+  More:     boolean;        // Whether there is a next page.
 }
 
 export enum PollNotifAction {
@@ -200,6 +432,8 @@ export enum PollNotifAction {
   Next,
   Term,
   Delete,
+  Turn,
+  Invite,
 }
 
 export class PollNotifAnswerEntry {
@@ -217,6 +451,48 @@ export class PollNotifAnswerEntry {
   }
 }
 
+export class InboxEntry {
+  Id:        number;
+  Timestamp: Date;
+  Segment:   string; // Empty when the poll has been deleted.
+  Title:     string;
+  Round:     number;
+  Action:    PollNotifAction;
+  Seen:      boolean;
+}
+
+export class InboxAnswer {
+  Entries: InboxEntry[]; // From the most recent to the oldest.
+  More:    boolean;      // Whether there is a next page.
+
+  static fromJSON(json: string): InboxAnswer {
+    return JSON.parse(json, function(key: string, value: any) {
+      if (key === 'Timestamp') { return new Date(value as string); }
+      return value;
+    });
+  }
+}
+
+export interface InboxReadQuery {
+  Ids?: number[];
+  All?: boolean;  // If true, Ids is ignored.
+}
+
+export enum DigestPeriod {
+  None,
+  Daily,
+  Weekly,
+}
+
+// Which notifications the user wants to receive by email.
+export interface EmailPrefs {
+  Start:  boolean;
+  Next:   boolean;
+  Term:   boolean;
+  Delete: boolean;
+  Digest: DigestPeriod;
+}
+
 export interface ConfirmAnswer {
   Type: string
 }
@@ -83,6 +83,9 @@ export class NotifyService {
     case PollNotifAction.Delete:
       msg = `Poll "${notif.Title}" has been deleted.`;
       break;
+    case PollNotifAction.Turn:
+      msg = `It is your turn to vote in poll "${notif.Title}".`;
+      break;
     }
     this.send(msg, notif.Segment, notif.Segment);
   }
@@ -0,0 +1,70 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+import { ComponentFixture, TestBed } from '@angular/core/testing';
+import { ActivatedRoute, Router } from '@angular/router';
+import { HttpClientTestingModule, HttpTestingController } from '@angular/common/http/testing';
+
+import { ActivatedRouteStub } from 'src/testing/activated-route-stub'
+import { RouterStub } from 'src/testing/router.stub';
+
+import { RedeemComponent } from './redeem.component';
+
+describe('RedeemComponent', () => {
+  let component: RedeemComponent;
+  let fixture: ComponentFixture<RedeemComponent>;
+  let httpControler: HttpTestingController;
+  let activatedRouteStub: ActivatedRouteStub;
+  let routerStub: RouterStub;
+
+  beforeEach(async () => {
+    activatedRouteStub = new ActivatedRouteStub();
+    routerStub = new RouterStub('');
+
+    await TestBed.configureTestingModule({
+      declarations: [ RedeemComponent ],
+      imports: [
+        HttpClientTestingModule,
+      ],
+      providers: [
+        { provide: ActivatedRoute, useValue: activatedRouteStub },
+        { provide: Router, useValue: routerStub },
+      ],
+    })
+    .compileComponents();
+  });
+
+  beforeEach(() => {
+    activatedRouteStub.setParamMap({invitationSegment: '123456789'});
+
+    fixture = TestBed.createComponent(RedeemComponent);
+    component = fixture.componentInstance;
+    fixture.detectChanges();
+
+    httpControler = TestBed.inject(HttpTestingController);
+  });
+
+  it('should create', () => {
+    expect(component).toBeTruthy();
+  });
+
+  it('redirects to the poll', () => {
+    const req = httpControler.expectOne('/a/redeem/123456789');
+    expect(req.request.method).toEqual('POST');
+    req.flush('987654321');
+    expect(routerStub.navigateByUrl).toHaveBeenCalledWith('/r/poll/987654321');
+  });
+});
@@ -0,0 +1,70 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+import { Component, OnInit, ChangeDetectionStrategy } from '@angular/core';
+import { HttpClient, HttpErrorResponse } from '@angular/common/http';
+import { ActivatedRoute, ParamMap, Router } from '@angular/router';
+
+import { BehaviorSubject, Observable } from 'rxjs';
+import { take } from 'rxjs/operators';
+
+import { ServerError } from 'src/app/shared/server-error';
+
+interface RedeemState {
+  type: string
+  data?: ServerError
+}
+
+@Component({
+  selector: 'app-redeem',
+  templateUrl: './redeem.component.html',
+  styleUrls: ['./redeem.component.sass'],
+  changeDetection: ChangeDetectionStrategy.OnPush
+})
+export class RedeemComponent implements OnInit {
+
+  private _state = new BehaviorSubject<RedeemState>({ type: 'loading' })
+  get state$(): Observable<RedeemState> {
+    return this._state
+  }
+
+  constructor(
+    private http: HttpClient,
+    private route: ActivatedRoute,
+    private router: Router,
+  ) { }
+
+  ngOnInit(): void {
+    this.route.paramMap.pipe(take(1)).subscribe((params: ParamMap) => {
+      const segment = params.get('invitationSegment')
+      this.http.post<string>('/a/redeem/' + segment, null).pipe(take(1)).subscribe({
+        next: (pollSegment: string) => {
+          this.router.navigateByUrl('/r/poll/' + pollSegment)
+        },
+        error: (err: HttpErrorResponse) => {
+          if (err.status == 404) {
+            this._state.next({ type: 'notfound' })
+          } else if (err.status == 409) {
+            this._state.next({ type: 'redeemed' })
+          } else {
+            this._state.next({ type: 'error', data: new ServerError(err, 'redeeming invitation') })
+          }
+        },
+      })
+    })
+  }
+
+}
@@ -18,7 +18,9 @@ import { NgModule } from '@angular/core';
 import { Routes, RouterModule } from '@angular/router';
 
 import { ConfirmationComponent } from './confirmation/confirmation.component';
+import { LoggedGuard } from './logged.guard';
 import { LoginComponent }   from './login/login.component';
+import { RedeemComponent } from './redeem/redeem.component';
 import { SessionGuard } from './session.guard';
 import { SignupComponent }  from './signup/signup.component';
 
@@ -28,6 +30,8 @@ const routes: Routes = [
     { path: 'signup', component: SignupComponent, data: {title: 'Sign up'} },
   ]},
   { path: 'r/confirm/:confirmSegment', component: ConfirmationComponent },
+  { path: 'r/redeem/:invitationSegment', component: RedeemComponent, canActivate: [ LoggedGuard ],
+    data: {title: 'Invitation'} },
 ];
 
 @NgModule({
@@ -34,6 +34,7 @@ import { SessionInterceptor } from './session.interceptor';
 import { LoginComponent } from './login/login.component';
 import { SignupComponent } from './signup/signup.component';
 import { ConfirmationComponent } from './confirmation/confirmation.component';
+import { RedeemComponent } from './redeem/redeem.component';
 import { EmailVerificationDialog } from './session.service';
 import { RetypePasswordComponent } from './retype-password/retype-password.component';
 
@@ -42,6 +43,7 @@ import { RetypePasswordComponent } from './retype-password/retype-password.compo
     LoginComponent,
     SignupComponent,
     ConfirmationComponent,
+    RedeemComponent,
     EmailVerificationDialog,
     RetypePasswordComponent,
   ],
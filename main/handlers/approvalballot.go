@@ -0,0 +1,131 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/mid/unlogged"
+)
+
+// AlternativeSet is a set of alternative identifiers.
+// Contrary to []uint8, it is represented in JSON by an array of numbers.
+type AlternativeSet []uint8
+
+// MarshalJSON implements json.Marshaler.
+func (self AlternativeSet) MarshalJSON() ([]byte, error) {
+	tmp := make([]uint16, len(self))
+	for i, alt := range self {
+		tmp[i] = uint16(alt)
+	}
+	return json.Marshal(tmp)
+}
+
+// listBallots contains the previous and the current ballots of a user, when these ballots are
+// lists of alternatives.
+// The fields Previous and Current are not sent in the JSON representation if the user did not vote.
+// If the user abstained, these fields are replaced with fields PreviousIsBlank or CurrentIsBlank
+// with the boolean value true.
+type listBallots struct {
+	Previous        AlternativeSet `json:",omitempty"`
+	PreviousIsBlank bool           `json:",omitempty"`
+	Current         AlternativeSet `json:",omitempty"`
+	CurrentIsBlank  bool           `json:",omitempty"`
+}
+
+// getListBallots retrieves the ballots of the user for the current and the previous rounds.
+// Alternatives in each ballot are sorted by rank, then by id. Errors are sent by panic.
+func getListBallots(ctx context.Context, pollInfo PollInfo, user uint32) (ret listBallots) {
+	const qGetBallots = `
+		SELECT p.Round, b.Alternative
+		  FROM Participants AS p
+			LEFT OUTER JOIN Ballots AS b ON (p.User, p.Poll, p.Round) = (b.User, b.Poll, b.Round)
+		 WHERE p.User = ? AND p.Poll = ? AND p.Round IN (?, ?)
+		 ORDER BY p.Round, b.Rank, b.Alternative`
+
+	var previousRound uint8
+	if pollInfo.CurrentRound > 0 {
+		// Round is unsigned
+		previousRound = pollInfo.CurrentRound - 1
+	}
+	rows, err := db.DB.QueryContext(ctx, qGetBallots, user, pollInfo.Id, previousRound,
+		pollInfo.CurrentRound)
+	must(err)
+	defer rows.Close()
+	for rows.Next() {
+		var round uint8
+		var alternative sql.NullInt32
+		must(rows.Scan(&round, &alternative))
+		setBallot := func(field *AlternativeSet, blank *bool) {
+			if *blank {
+				must(errors.New("Duplicated ballot"))
+			}
+			if alternative.Valid {
+				*field = append(*field, uint8(alternative.Int32))
+			} else if len(*field) > 0 {
+				must(errors.New("Duplicated ballot"))
+			} else {
+				*blank = true
+			}
+		}
+		switch round {
+		case pollInfo.CurrentRound:
+			setBallot(&ret.Current, &ret.CurrentIsBlank)
+		case previousRound:
+			setBallot(&ret.Previous, &ret.PreviousIsBlank)
+		default:
+			must(errors.New("Impossible round"))
+		}
+	}
+	must(rows.Err())
+	return
+}
+
+// ApprovalBallotAnswer represents the response sent by ApprovalBallotHandler.
+type ApprovalBallotAnswer struct {
+	listBallots
+	MaxBallotCost     float64
+	BallotCostIsCount bool
+	Alternatives      []PollAlternative
+}
+
+// ApprovalBallotHandler sends the previous ballot (if any), the current one (if any), the
+// constraints on ballots and all the alternatives.
+func ApprovalBallotHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+
+	if request.User == nil {
+		var user server.User
+		user, err = unlogged.FromAddr(ctx, request.RemoteAddr())
+		must(err)
+		request.User = &user
+	}
+
+	answer := ApprovalBallotAnswer{
+		listBallots:       getListBallots(ctx, pollInfo, request.User.Id),
+		MaxBallotCost:     pollInfo.MaxBallotCost,
+		BallotCostIsCount: pollInfo.BallotCostIsCount,
+	}
+	allAlternatives(ctx, pollInfo, &answer.Alternatives)
+	response.SendJSON(ctx, answer)
+}
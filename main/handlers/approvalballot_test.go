@@ -0,0 +1,151 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+func TestAlternativeSet_JSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		set    AlternativeSet
+		expect string
+	}{
+		{name: "Nil", set: nil, expect: `[]`},
+		{name: "Empty", set: AlternativeSet{}, expect: `[]`},
+		{name: "Several", set: AlternativeSet{0, 2, 255}, expect: `[0,2,255]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.set)
+			mustt(t, err)
+			if string(got) != tt.expect {
+				t.Errorf("Got %s. Expect %s.", got, tt.expect)
+			}
+
+			var back AlternativeSet
+			mustt(t, json.Unmarshal(got, &back))
+			if len(back) != len(tt.set) {
+				t.Fatalf("Wrong length. Got %d. Expect %d.", len(back), len(tt.set))
+			}
+			for i := range back {
+				if back[i] != tt.set[i] {
+					t.Errorf("Wrong element %d. Got %d. Expect %d.", i, back[i], tt.set[i])
+				}
+			}
+		})
+	}
+}
+
+func TestApprovalBallotHandler(t *testing.T) {
+	precheck(t)
+
+	env := new(dbt.Env)
+	defer env.Close()
+
+	userId := env.CreateUser()
+	pollId := env.CreatePollWith("ApprovalBallotHandler", userId, db.ElectorateAll,
+		[]string{"A", "B", "C"})
+	env.QuietExec(`UPDATE Polls SET MaxBallotCost = 2 WHERE Id = ?`, pollId)
+	mustt(t, env.Error)
+
+	request := *makePollRequest(t, pollId, &userId)
+
+	alternatives := []PollAlternative{
+		{Id: 0, Name: "A", Cost: 1.},
+		{Id: 1, Name: "B", Cost: 1.},
+		{Id: 2, Name: "C", Cost: 1.},
+	}
+	answer := func(previous, current AlternativeSet, previousBlank, currentBlank bool) *ApprovalBallotAnswer {
+		return &ApprovalBallotAnswer{
+			listBallots: listBallots{
+				Previous:        previous,
+				PreviousIsBlank: previousBlank,
+				Current:         current,
+				CurrentIsBlank:  currentBlank,
+			},
+			MaxBallotCost:     2,
+			BallotCostIsCount: true,
+			Alternatives:      alternatives,
+		}
+	}
+
+	vote := func(round uint8, alternatives ...uint8) func(t *testing.T) {
+		return func(t *testing.T) {
+			for _, alt := range alternatives {
+				env.Vote(pollId, round, userId, alt)
+			}
+			env.Must(t)
+		}
+	}
+
+	const qBlankVote = `DELETE FROM Ballots WHERE User = ? AND Round = ?`
+	blank := func(round uint8) func(t *testing.T) {
+		return func(t *testing.T) {
+			_, err := db.DB.Exec(qBlankVote, userId, round)
+			mustt(t, err)
+		}
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "No Ballot",
+			Request: request,
+			Checker: srvt.CheckJSON{Body: answer(nil, nil, false, false)},
+		},
+		&srvt.T{
+			Name:    "Current ballot",
+			Update:  vote(0, 0, 2),
+			Request: request,
+			Checker: srvt.CheckJSON{Body: answer(nil, AlternativeSet{0, 2}, false, false)},
+		},
+		&srvt.T{
+			Name: "Previous ballot",
+			Update: func(t *testing.T) {
+				env.NextRound(pollId)
+				env.Must(t)
+			},
+			Request: request,
+			Checker: srvt.CheckJSON{Body: answer(AlternativeSet{0, 2}, nil, false, false)},
+		},
+		&srvt.T{
+			Name:    "Both ballots",
+			Update:  vote(1, 1),
+			Request: request,
+			Checker: srvt.CheckJSON{Body: answer(AlternativeSet{0, 2}, AlternativeSet{1}, false, false)},
+		},
+		&srvt.T{
+			Name:    "Blank current",
+			Update:  blank(1),
+			Request: request,
+			Checker: srvt.CheckJSON{Body: answer(AlternativeSet{0, 2}, nil, false, true)},
+		},
+		&srvt.T{
+			Name:    "Blank previous",
+			Update:  blank(0),
+			Request: request,
+			Checker: srvt.CheckJSON{Body: answer(nil, nil, true, true)},
+		},
+	}
+	srvt.RunFunc(t, tests, ApprovalBallotHandler)
+}
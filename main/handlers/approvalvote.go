@@ -0,0 +1,115 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/JBoudou/Itero/main/services"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/events"
+)
+
+// ApprovalVoteQuery is the body of requests sent to ApprovalVoteHandler.
+// An empty set of alternatives is a blank vote.
+type ApprovalVoteQuery struct {
+	Alternatives AlternativeSet
+	Round        uint8
+}
+
+// costEpsilon is the tolerance used when comparing sums of costs.
+const costEpsilon = 1e-6
+
+// checkApprovalBallot verifies that ballot is valid for the poll.
+// Each alternative must exist and appear only once, and the cost (or the cardinality, depending on
+// BallotCostIsCount) of the ballot must not exceed MaxBallotCost. The cost of alternative i is
+// alternatives[i].Cost.
+func checkApprovalBallot(poll PollInfo, ballot AlternativeSet, alternatives []PollAlternative) error {
+	if err := checkAlternatives(poll, ballot); err != nil {
+		return err
+	}
+	var cost float64
+	for _, alt := range ballot {
+		if int(alt) >= len(alternatives) {
+			return server.NewHttpError(http.StatusBadRequest, "Invalid ballot", "Unknown alternative")
+		}
+		if poll.BallotCostIsCount {
+			cost += 1
+		} else {
+			cost += alternatives[alt].Cost
+		}
+	}
+	if cost > poll.MaxBallotCost+costEpsilon {
+		return server.NewHttpError(http.StatusBadRequest, "Invalid ballot", "Ballot is too expensive")
+	}
+	return nil
+}
+
+type approvalVoteHandler struct {
+	evtManager events.Manager
+}
+
+// ApprovalVoteHandler votes for a set of alternatives. Blank votes are also permitted.
+func ApprovalVoteHandler(evtManager events.Manager) approvalVoteHandler {
+	return approvalVoteHandler{evtManager: evtManager}
+}
+
+func (self approvalVoteHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, sendUnloggedCookie := checkVoteRequest(ctx, request, BallotTypeApproval)
+
+	// Get query
+	var voteQuery ApprovalVoteQuery
+	if err := request.UnmarshalJSONBody(&voteQuery); err != nil {
+		err = server.WrapError(http.StatusBadRequest, "Wrong request", err)
+		response.SendError(ctx, err)
+		return
+	}
+	if err := checkVoteRound(pollInfo, voteQuery.Round); err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+	var alternatives []PollAlternative
+	allAlternatives(ctx, pollInfo, &alternatives)
+	if err := checkApprovalBallot(pollInfo, voteQuery.Alternatives, alternatives); err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+
+	const qInsertBallot = `INSERT INTO Ballots (User, Poll, Alternative, Round) VALUE (?, ?, ?, ?)`
+
+	var insert func(tx *sql.Tx)
+	if len(voteQuery.Alternatives) > 0 {
+		insert = func(tx *sql.Tx) {
+			stmt, err := tx.PrepareContext(ctx, qInsertBallot)
+			must(err)
+			defer stmt.Close()
+			for _, alt := range voteQuery.Alternatives {
+				_, err = stmt.ExecContext(ctx, request.User.Id, pollInfo.Id, alt, pollInfo.CurrentRound)
+				must(err)
+			}
+		}
+	}
+	replaceBallot(ctx, pollInfo, request.User.Id, insert)
+
+	if sendUnloggedCookie {
+		response.SendUnloggedId(ctx, *request.User, request)
+	}
+	response.SendJSON(ctx, "Ok")
+	self.evtManager.Send(services.VoteEvent{pollInfo.Id})
+}
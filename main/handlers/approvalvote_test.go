@@ -0,0 +1,187 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/server"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+func TestCheckApprovalBallot(t *testing.T) {
+	alternatives := []PollAlternative{
+		{Id: 0, Name: "A", Cost: 1.},
+		{Id: 1, Name: "B", Cost: 2.5},
+		{Id: 2, Name: "C", Cost: 0.5},
+	}
+	countPoll := PollInfo{NbChoices: 3, MaxBallotCost: 2, BallotCostIsCount: true}
+	costPoll := PollInfo{NbChoices: 3, MaxBallotCost: 3, BallotCostIsCount: false}
+
+	tests := []struct {
+		name   string
+		poll   PollInfo
+		ballot AlternativeSet
+		ok     bool
+	}{
+		{name: "Blank", poll: countPoll, ballot: AlternativeSet{}, ok: true},
+		{name: "Count ok", poll: countPoll, ballot: AlternativeSet{2, 0}, ok: true},
+		{name: "Count too many", poll: countPoll, ballot: AlternativeSet{0, 1, 2}},
+		{name: "Unknown alternative", poll: countPoll, ballot: AlternativeSet{3}},
+		{name: "Duplicate", poll: countPoll, ballot: AlternativeSet{1, 1}},
+		{name: "Cost ok", poll: costPoll, ballot: AlternativeSet{1, 2}, ok: true},
+		{name: "Cost too high", poll: costPoll, ballot: AlternativeSet{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkApprovalBallot(tt.poll, tt.ballot, alternatives)
+			if tt.ok {
+				if err != nil {
+					t.Errorf("Unexpected error %v.", err)
+				}
+				return
+			}
+			httpError, ok := err.(server.HttpError)
+			if !ok {
+				t.Fatalf("Expect an HttpError. Got %v.", err)
+			}
+			if httpError.Code != http.StatusBadRequest {
+				t.Errorf("Wrong code. Got %d. Expect %d.", httpError.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+type approvalVoteChecker struct {
+	poll  uint32
+	user  uint32
+	round uint8
+}
+
+func (self *approvalVoteChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	var query ApprovalVoteQuery
+	mustt(t, request.UnmarshalJSONBody(&query))
+
+	const qCheck = `
+		SELECT b.Alternative
+		  FROM Participants AS p LEFT OUTER JOIN Ballots AS b
+			  ON (p.Poll, p.User, p.Round) = (b.Poll, b.User, b.Round)
+		 WHERE p.Poll = ? AND p.User = ? AND p.Round = ? AND b.Alternative IS NOT NULL
+		 ORDER BY b.Alternative ASC`
+
+	rows, err := db.DB.Query(qCheck, self.poll, self.user, self.round)
+	mustt(t, err)
+	defer rows.Close()
+	got := AlternativeSet{}
+	for rows.Next() {
+		var alt uint8
+		mustt(t, rows.Scan(&alt))
+		got = append(got, alt)
+	}
+	expect := AlternativeSet{}
+	for i := uint8(0); i < 3; i++ {
+		for _, alt := range query.Alternatives {
+			if alt == i {
+				expect = append(expect, alt)
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got %v. Expect %v.", got, expect)
+	}
+}
+
+func TestApprovalVoteHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	userId := env.CreateUser()
+	pollId := env.CreatePollWith("Test", userId, db.ElectorateLogged, []string{"A", "B", "C"})
+	env.QuietExec(`UPDATE Polls SET MaxBallotCost = 2 WHERE Id = ?`, pollId)
+	uniPollId := env.CreatePoll("Uninominal", userId, db.ElectorateLogged)
+	otherId := env.CreateUserWith("ApprovalVoteOther")
+	seqPollId := env.CreatePollWith("Sequential", userId, db.ElectorateLogged, []string{"A", "B", "C"})
+	env.QuietExec(`UPDATE Polls SET MaxBallotCost = 2, RoundType = ?, CurrentMover = ? WHERE Id = ?`,
+		db.RoundTypeSequential, otherId, seqPollId)
+	env.Must(t)
+
+	makeRequest := func(pollId uint32, vote ApprovalVoteQuery) srvt.Request {
+		req := *makePollRequest(t, pollId, &userId)
+		b, err := json.Marshal(vote)
+		mustt(t, err)
+		req.Body = string(b)
+		req.Method = "POST"
+		return req
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "First vote",
+			Request: makeRequest(pollId, ApprovalVoteQuery{Alternatives: AlternativeSet{0, 2}}),
+			Checker: &approvalVoteChecker{poll: pollId, user: userId, round: 0},
+		},
+		&srvt.T{
+			Name:    "Change vote",
+			Request: makeRequest(pollId, ApprovalVoteQuery{Alternatives: AlternativeSet{1}}),
+			Checker: &approvalVoteChecker{poll: pollId, user: userId, round: 0},
+		},
+		&srvt.T{
+			Name:    "Blank vote",
+			Request: makeRequest(pollId, ApprovalVoteQuery{Alternatives: AlternativeSet{}}),
+			Checker: &approvalVoteChecker{poll: pollId, user: userId, round: 0},
+		},
+		&srvt.T{
+			Name:    "Too many alternatives",
+			Request: makeRequest(pollId, ApprovalVoteQuery{Alternatives: AlternativeSet{0, 1, 2}}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Invalid ballot"},
+		},
+		&srvt.T{
+			Name:    "Duplicated alternative",
+			Request: makeRequest(pollId, ApprovalVoteQuery{Alternatives: AlternativeSet{1, 1}}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Invalid ballot"},
+		},
+		&srvt.T{
+			Name:    "Unknown alternative",
+			Request: makeRequest(pollId, ApprovalVoteQuery{Alternatives: AlternativeSet{3}}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Invalid ballot"},
+		},
+		&srvt.T{
+			Name:    "Next round",
+			Request: makeRequest(pollId, ApprovalVoteQuery{Round: 1}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Wrong round"},
+		},
+		&srvt.T{
+			Name:    "Uninominal poll",
+			Request: makeRequest(uniPollId, ApprovalVoteQuery{Alternatives: AlternativeSet{0}}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Wrong poll"},
+		},
+		&srvt.T{
+			Name:    "Not your turn",
+			Request: makeRequest(seqPollId, ApprovalVoteQuery{Alternatives: AlternativeSet{0}}),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Not your turn"},
+		},
+	}
+	srvt.Run(t, tests, ApprovalVoteHandler)
+}
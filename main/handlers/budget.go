@@ -0,0 +1,76 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+)
+
+// BudgetInfoAnswer is the response sent by BudgetInfoHandler.
+// Funded lists the funded alternatives, in the order they have been selected by the budget rule.
+type BudgetInfoAnswer struct {
+	Budget float64
+	Spent  float64
+	Funded []PollAlternative
+}
+
+// BudgetInfoHandler sends the alternatives funded at the end of a previous round.
+// The budget is the field MaxOutcomeCost of the poll. The result is only available for polls whose
+// rule is a budget rule, and is sent only if the poll gives full counts.
+func BudgetInfoHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+	if pollInfo.Rule != db.PollRuleGreedyApproval && pollInfo.Rule != db.PollRuleEqualShares {
+		panic(server.NewHttpError(http.StatusBadRequest, "Wrong poll", "Not a budget poll"))
+	}
+	if err = pollInfo.checkInformation(db.InformationCounts); err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+
+	// Get the round to return results of.
+	round, err := getInfoRound(request, pollInfo)
+	if err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+
+	profile, err := db.LoadProfile(ctx, pollInfo.Id, round)
+	must(err)
+	var alternatives []PollAlternative
+	allAlternatives(ctx, pollInfo, &alternatives)
+	costs := make([]float64, len(alternatives))
+	for i, alt := range alternatives {
+		costs[i] = alt.Cost
+	}
+
+	funded := db.BudgetRuleFromDB(pollInfo.Rule).Funded(profile, costs, pollInfo.MaxOutcomeCost)
+	answer := BudgetInfoAnswer{
+		Budget: pollInfo.MaxOutcomeCost,
+		Funded: make([]PollAlternative, len(funded)),
+	}
+	for i, alt := range funded {
+		answer.Funded[i] = alternatives[alt]
+		answer.Spent += alternatives[alt].Cost
+	}
+
+	response.SendJSON(ctx, answer)
+}
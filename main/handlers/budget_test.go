@@ -0,0 +1,121 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+func TestBudgetInfoHandler(t *testing.T) {
+	precheck(t)
+
+	const (
+		qBudget = `UPDATE Polls SET MaxOutcomeCost = 100, MaxBallotCost = 3, Rule = ? WHERE Id = ?`
+		qCost   = `UPDATE Alternatives SET Cost = ? WHERE Poll = ? AND Id = ?`
+		qWinner = `UPDATE Polls SET Information = ? WHERE Id = ?`
+	)
+
+	var env dbt.Env
+	defer env.Close()
+	var users [10]uint32
+	for i := range users {
+		users[i] = env.CreateUserWith(strconv.FormatInt(int64(i), 10))
+	}
+
+	alternatives := []PollAlternative{
+		{Id: 0, Name: "Park", Cost: 60},
+		{Id: 1, Name: "Bench", Cost: 40},
+		{Id: 2, Name: "Library", Cost: 40},
+	}
+	names := make([]string, len(alternatives))
+	for i, alt := range alternatives {
+		names[i] = alt.Name
+	}
+
+	// Six users approve Park and Bench. Four users approve Library.
+	createPoll := func(rule uint8) uint32 {
+		pollId := env.CreatePollWith("Test", users[0], db.ElectorateAll, names)
+		env.QuietExec(qBudget, rule, pollId)
+		for _, alt := range alternatives {
+			env.QuietExec(qCost, alt.Cost, pollId, alt.Id)
+		}
+		for i, user := range users {
+			if i < 6 {
+				env.Vote(pollId, 0, user, 0)
+				env.Vote(pollId, 0, user, 1)
+			} else {
+				env.Vote(pollId, 0, user, 2)
+			}
+		}
+		return pollId
+	}
+	greedyPoll := createPoll(db.PollRuleGreedyApproval)
+	sharesPoll := createPoll(db.PollRuleEqualShares)
+	pluralityPoll := createPoll(db.PollRulePlurality)
+	winnerPoll := createPoll(db.PollRuleGreedyApproval)
+	env.QuietExec(qWinner, db.InformationWinner, winnerPoll)
+	env.Must(t)
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Round zero",
+			Request: *makePollRequest(t, greedyPoll, &users[0]),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		&srvt.T{
+			Name: "Greedy approval",
+			Update: func(t *testing.T) {
+				for _, pollId := range []uint32{greedyPoll, sharesPoll, pluralityPoll, winnerPoll} {
+					env.NextRound(pollId)
+				}
+				env.Must(t)
+			},
+			Request: *makePollRequest(t, greedyPoll, &users[0]),
+			Checker: srvt.CheckJSON{Body: BudgetInfoAnswer{
+				Budget: 100,
+				Spent:  100,
+				Funded: []PollAlternative{alternatives[0], alternatives[1]},
+			}},
+		},
+		&srvt.T{
+			Name:    "Equal shares",
+			Request: *makePollRequest(t, sharesPoll, &users[0]),
+			Checker: srvt.CheckJSON{Body: BudgetInfoAnswer{
+				Budget: 100,
+				Spent:  80,
+				Funded: []PollAlternative{alternatives[1], alternatives[2]},
+			}},
+		},
+		&srvt.T{
+			Name:    "Not budget",
+			Request: *makePollRequest(t, pluralityPoll, &users[0]),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Wrong poll"},
+		},
+		&srvt.T{
+			Name:    "Winner only",
+			Request: *makePollRequest(t, winnerPoll, &users[0]),
+			Checker: srvt.CheckStatus{http.StatusForbidden},
+		},
+	}
+	srvt.RunFunc(t, tests, BudgetInfoHandler)
+}
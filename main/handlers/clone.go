@@ -0,0 +1,195 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/JBoudou/Itero/main/services"
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/salted"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/events"
+)
+
+// CloneQuery is the body of clone requests. All fields are optional. A zero Start means that the
+// new poll starts immediately, without proposal phase. A zero Deadline means that the new poll lasts
+// as long as the original one.
+type CloneQuery struct {
+	Start    time.Time
+	Deadline time.Time
+	ShortURL string
+}
+
+type cloneHandler struct {
+	evtManager events.Manager
+}
+
+// CloneHandler creates a new poll with the same configuration and alternatives as an existing
+// one. Only the administrator of the existing poll can clone it, whatever its state. The query is a
+// CloneQuery and the answer is the segment of the new poll, as for CreateHandler.
+func CloneHandler(evtManager events.Manager) cloneHandler {
+	return cloneHandler{evtManager: evtManager}
+}
+
+func (self cloneHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
+	if request.User == nil || !request.User.Logged {
+		must(request.SessionError)
+		panic(server.UnauthorizedHttpError("Unlogged user"))
+	}
+	must(request.CheckPOST(ctx))
+	segment, err := salted.FromRequest(request)
+	must(err)
+
+	var clone CloneQuery
+	must(request.UnmarshalJSONBody(&clone))
+
+	query, duration := loadCreateQuery(ctx, segment, request.User.Id)
+	query.Start = clone.Start
+	if query.Start.IsZero() {
+		query.Proposals = false
+	}
+	query.ShortURL = clone.ShortURL
+	query.Deadline = clone.Deadline
+	if query.Deadline.IsZero() && duration > 0 {
+		from := clone.Start
+		if from.IsZero() {
+			from = time.Now()
+		}
+		query.Deadline = from.Add(duration)
+	}
+	if query.Deadline.IsZero() {
+		query.Deadline = defaultCreateQuery().Deadline
+	}
+
+	def := checkCreateQuery(ctx, query, request.User.Id)
+
+	pollSegment := def.insert(ctx, request.User.Id)
+	encoded, err := pollSegment.Encode()
+	must(err)
+	response.SendJSON(ctx, encoded)
+	self.evtManager.Send(services.CreatePollEvent{pollSegment.Id})
+}
+
+// loadCreateQuery retrieves the definition of a poll administrated by user. Start, Deadline and
+// ShortURL are left empty. The returned duration is the time between the start of the poll and
+// its deadline, or zero if the poll has no deadline.
+// Errors are sent by panic.
+func loadCreateQuery(ctx context.Context, segment salted.Segment, user uint32) (query CreateQuery, duration time.Duration) {
+	const (
+		qPoll = `
+		  SELECT Title, Description, Electorate, Hidden, ReportVote, MinNbRounds, MaxNbRounds,
+		         TIME_TO_SEC(MaxRoundDuration) * 1000, RoundThreshold, Type, Rule, MaxOutcomeCost,
+		         MaxBallotCost, BallotCostIsCount, Information, InformationTop, RoundType, MinCellSize,
+		         StopStableProfile, StopStableWinner, ThresholdOnWeight, LockComments,
+		         Proposals,
+		         TIMESTAMPDIFF(SECOND, COALESCE(Start, Created), Deadline)
+		    FROM Polls
+		   WHERE Id = ? AND Salt = ? AND Admin = ?`
+		qAlternatives = `SELECT Name, Cost FROM Alternatives WHERE Poll = ? ORDER BY Id ASC`
+		qGrades       = `SELECT Name FROM Grades WHERE Poll = ? ORDER BY Id ASC`
+	)
+
+	var (
+		description      sql.NullString
+		electorate       db.Electorate
+		maxNbRounds      sql.NullInt32
+		maxRoundDuration sql.NullInt64
+		pollType         uint8
+		rule             uint8
+		information      db.Information
+		roundType        uint8
+		seconds          sql.NullInt64
+	)
+	row := db.DB.QueryRowContext(ctx, qPoll, segment.Id, segment.Salt, user)
+	err := row.Scan(&query.Title, &description, &electorate, &query.Hidden, &query.ReportVote,
+		&query.MinNbRounds, &maxNbRounds, &maxRoundDuration, &query.RoundThreshold, &pollType, &rule,
+		&query.MaxOutcomeCost, &query.MaxBallotCost, &query.BallotCostIsCount, &information,
+		&query.InformationTop, &roundType, &query.MinCellSize, &query.StopStableProfile,
+		&query.StopStableWinner, &query.ThresholdOnWeight, &query.LockComments,
+		&query.Proposals, &seconds)
+	if err == sql.ErrNoRows {
+		panic(server.NewHttpError(http.StatusNotFound, "Not found", "No poll administrated by the user"))
+	}
+	must(err)
+
+	query.Description = description.String
+	if maxNbRounds.Valid {
+		query.MaxNbRounds = uint8(maxNbRounds.Int32)
+	}
+	query.MaxRoundDuration = uint64(maxRoundDuration.Int64)
+	if seconds.Valid && seconds.Int64 > 0 {
+		duration = time.Duration(seconds.Int64) * time.Second
+	}
+
+	for value := CreatePollElectorateAll; value <= CreatePollElectorateInvited; value++ {
+		if value.ToDB() == electorate {
+			query.Electorate = value
+		}
+	}
+	for value := CreatePollRulePlurality; value <= CreatePollRuleRangeVoting; value++ {
+		if value.ToDB() == rule {
+			query.Rule = value
+		}
+	}
+	for value := CreatePollInformationCounts; value <= CreatePollInformationNone; value++ {
+		if value.ToDB() == information {
+			query.Information = value
+		}
+	}
+	for value := CreatePollRoundTypeFreelyAsynchronous; value <= CreatePollRoundTypeSynchronous; value++ {
+		if value.ToDB() == roundType {
+			query.RoundType = value
+		}
+	}
+
+	switch {
+	case pollType == db.PollTypeRanked:
+		query.Ballot = BallotTypeRanked
+	case pollType == db.PollTypeGrading:
+		query.Ballot = BallotTypeGrading
+	case query.BallotCostIsCount && query.MaxBallotCost < 2:
+		query.Ballot = BallotTypeUninominal
+	default:
+		query.Ballot = BallotTypeApproval
+	}
+
+	rows, err := db.DB.QueryContext(ctx, qAlternatives, segment.Id)
+	must(err)
+	for rows.Next() {
+		var alt SimpleAlternative
+		must(rows.Scan(&alt.Name, &alt.Cost))
+		query.Alternatives = append(query.Alternatives, alt)
+	}
+	must(rows.Close())
+
+	if query.Ballot == BallotTypeGrading {
+		rows, err = db.DB.QueryContext(ctx, qGrades, segment.Id)
+		must(err)
+		for rows.Next() {
+			var grade string
+			must(rows.Scan(&grade))
+			query.Grades = append(query.Grades, grade)
+		}
+		must(rows.Close())
+	}
+
+	return
+}
@@ -0,0 +1,130 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/salted"
+	"github.com/JBoudou/Itero/mid/server"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+type cloneChecker struct {
+	title    string
+	state    string
+	shortURL string
+}
+
+func (self cloneChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	const (
+		qPoll         = `SELECT Title, State, ShortURL FROM Polls WHERE Id = ? AND Salt = ?`
+		qAlternatives = `SELECT Name FROM Alternatives WHERE Poll = ? ORDER BY Id`
+		qCleanUp      = `DELETE FROM Polls WHERE Id = ?`
+	)
+
+	var answer string
+	mustt(t, json.NewDecoder(response.Body).Decode(&answer))
+	pollSegment, err := salted.Decode(answer)
+	mustt(t, err)
+	defer func() {
+		db.DB.Exec(qCleanUp, pollSegment.Id)
+	}()
+
+	var title, state string
+	var shortURL sql.NullString
+	mustt(t, db.DB.QueryRow(qPoll, pollSegment.Id, pollSegment.Salt).Scan(&title, &state, &shortURL))
+	if title != self.title {
+		t.Errorf("Wrong title. Got %s. Expect %s.", title, self.title)
+	}
+	if state != self.state {
+		t.Errorf("Wrong state. Got %s. Expect %s.", state, self.state)
+	}
+	if shortURL.String != self.shortURL {
+		t.Errorf("Wrong ShortURL. Got %s. Expect %s.", shortURL.String, self.shortURL)
+	}
+
+	rows, err := db.DB.Query(qAlternatives, pollSegment.Id)
+	mustt(t, err)
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var name string
+		mustt(t, rows.Scan(&name))
+		got = append(got, name)
+	}
+	expect := []string{"No", "Yes"}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Wrong alternatives. Got %v. Expect %v.", got, expect)
+	}
+}
+
+func TestCloneHandler(t *testing.T) {
+	precheck(t)
+
+	const qTerminate = `UPDATE Polls SET State = 'Terminated' WHERE Id = ?`
+
+	var env dbt.Env
+	defer env.Close()
+	userId := env.CreateUserWith("Admin")
+	otherId := env.CreateUserWith("Other")
+	pollId := env.CreatePoll("Clone", userId, db.ElectorateLogged)
+	terminatedPollId := env.CreatePoll("Terminated", userId, db.ElectorateLogged)
+	env.QuietExec(qTerminate, terminatedPollId)
+	env.Must(t)
+
+	makeRequest := func(pollId uint32, user *uint32, query CloneQuery) srvt.Request {
+		req := *makePollRequest(t, pollId, user)
+		b, err := json.Marshal(query)
+		mustt(t, err)
+		req.Body = string(b)
+		req.Method = "POST"
+		return req
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Clone",
+			Request: makeRequest(pollId, &userId, CloneQuery{}),
+			Checker: cloneChecker{title: "Clone", state: "Active"},
+		},
+		&srvt.T{
+			Name:    "Terminated",
+			Request: makeRequest(terminatedPollId, &userId, CloneQuery{ShortURL: "clonedTerminated"}),
+			Checker: cloneChecker{title: "Terminated", state: "Active", shortURL: "clonedTerminated"},
+		},
+		&srvt.T{
+			Name:    "Not admin",
+			Request: makeRequest(pollId, &otherId, CloneQuery{}),
+			Checker: srvt.CheckStatus{http.StatusNotFound},
+		},
+		&srvt.T{
+			Name:    "No user",
+			Request: makeRequest(pollId, nil, CloneQuery{}),
+			Checker: srvt.CheckStatus{http.StatusUnauthorized},
+		},
+	}
+	srvt.Run(t, tests, CloneHandler)
+}
@@ -0,0 +1,174 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/slog"
+)
+
+const (
+	commentsPageSize = 20
+	maxCommentLength = 1000
+)
+
+// CommentQuery is the body of comment requests.
+type CommentQuery struct {
+	Round   uint8
+	Content string
+}
+
+// CommentDeleteQuery is the body of comment deletion requests.
+type CommentDeleteQuery struct {
+	Id uint32
+}
+
+type CommentEntry struct {
+	Id      uint32
+	Author  string
+	Posted  time.Time
+	Content string
+}
+
+// CommentsAnswer is a page of comments, from the oldest to the most recent. More tells whether
+// there are more recent comments on the next page.
+type CommentsAnswer struct {
+	Comments []CommentEntry
+	More     bool
+}
+
+// getCommentsPage returns the page of comments requested. Pages start at 0.
+func getCommentsPage(request *server.Request) int {
+	if len(request.RemainingPath) >= 3 {
+		page, err := strconv.Atoi(request.RemainingPath[1])
+		if err == nil && page >= 0 {
+			return page
+		}
+	}
+	return 0
+}
+
+// CommentsHandler lists the comments about a round of a poll, by pages of commentsPageSize
+// comments. The round and the page are given in the path, before the segment of the poll. They
+// default to the current round and to the first page.
+func CommentsHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+	round := getPollRoundFromRequest(request, pollInfo.CurrentRound)
+	if round > pollInfo.CurrentRound {
+		panic(server.NewHttpError(http.StatusBadRequest, "Protocol error", "Future round"))
+	}
+	page := getCommentsPage(request)
+
+	const qList = `
+	  SELECT c.Id, u.Name, c.Posted, c.Content
+	    FROM Comments AS c, Users AS u
+	   WHERE c.Poll = ? AND c.Round = ? AND u.Id = c.User
+	   ORDER BY c.Id ASC
+	   LIMIT ? OFFSET ?`
+	rows, err := db.DB.QueryContext(ctx, qList, pollInfo.Id, round, commentsPageSize+1,
+		page*commentsPageSize)
+	must(err)
+	defer rows.Close()
+
+	answer := CommentsAnswer{Comments: []CommentEntry{}}
+	for rows.Next() {
+		if len(answer.Comments) == commentsPageSize {
+			answer.More = true
+			break
+		}
+		var entry CommentEntry
+		must(rows.Scan(&entry.Id, &entry.Author, &entry.Posted, &entry.Content))
+		answer.Comments = append(answer.Comments, entry)
+	}
+	must(rows.Err())
+
+	response.SendJSON(ctx, answer)
+}
+
+// CommentHandler posts a comment about a round of a poll. The query is a CommentQuery. Only logged
+// users can comment. If the poll locks comments, only the current round of an unterminated poll can
+// be commented. The answer is the id of the new comment.
+func CommentHandler(ctx context.Context, response server.Response, request *server.Request) {
+	if request.User == nil || !request.User.Logged {
+		must(request.SessionError)
+		panic(server.UnauthorizedHttpError("Unlogged user"))
+	}
+	must(request.CheckPOST(ctx))
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+
+	var query CommentQuery
+	must(request.UnmarshalJSONBody(&query))
+	length := utf8.RuneCountInString(query.Content)
+	if length == 0 || length > maxCommentLength {
+		panic(server.NewHttpError(http.StatusBadRequest, "Wrong length", "Wrong comment length"))
+	}
+	if query.Round > pollInfo.CurrentRound {
+		panic(server.NewHttpError(http.StatusBadRequest, "Protocol error", "Future round"))
+	}
+
+	const (
+		qLock   = `SELECT LockComments FROM Polls WHERE Id = ?`
+		qInsert = `INSERT INTO Comments (Poll, Round, User, Content) VALUE (?, ?, ?, ?)`
+	)
+
+	var id uint32
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		var lock bool
+		must(tx.QueryRowContext(ctx, qLock, pollInfo.Id).Scan(&lock))
+		if lock && (pollInfo.Terminated || query.Round < pollInfo.CurrentRound) {
+			panic(server.NewHttpError(http.StatusLocked, "Read only", "The round is over"))
+		}
+		result, err := tx.ExecContext(ctx, qInsert, pollInfo.Id, query.Round, request.User.Id,
+			query.Content)
+		must(err)
+		id, err = db.IdFromResult(result)
+		must(err)
+	})
+
+	response.SendJSON(ctx, id)
+}
+
+// DeleteCommentHandler deletes a comment about a poll. The query is a CommentDeleteQuery. Only the
+// administrator can delete comments.
+func DeleteCommentHandler(ctx context.Context, response server.Response, request *server.Request) {
+	must(request.CheckPOST(ctx))
+	poll := checkPollAdmin(ctx, request)
+
+	var query CommentDeleteQuery
+	must(request.UnmarshalJSONBody(&query))
+
+	const qDelete = `DELETE FROM Comments WHERE Id = ? AND Poll = ?`
+	result, err := db.DB.ExecContext(ctx, qDelete, query.Id, poll.Id)
+	must(err)
+	affected, err := result.RowsAffected()
+	must(err)
+	if affected == 0 {
+		panic(server.NewHttpError(http.StatusNotFound, "Not found", "No such comment"))
+	}
+
+	response.SendJSON(ctx, "Ok")
+}
@@ -0,0 +1,205 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/salted"
+	"github.com/JBoudou/Itero/mid/server"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+type commentsChecker struct {
+	poll     uint32
+	round    uint8
+	comments int
+}
+
+func (self commentsChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	const qCount = `SELECT COUNT(*) FROM Comments WHERE Poll = ? AND Round = ?`
+	var count int
+	mustt(t, db.DB.QueryRow(qCount, self.poll, self.round).Scan(&count))
+	if count != self.comments {
+		t.Errorf("Wrong number of comments. Got %d. Expect %d.", count, self.comments)
+	}
+}
+
+type commentsPageChecker struct {
+	comments int
+	more     bool
+}
+
+func (self commentsPageChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	var answer CommentsAnswer
+	mustt(t, json.NewDecoder(response.Body).Decode(&answer))
+	if len(answer.Comments) != self.comments {
+		t.Errorf("Wrong number of comments. Got %d. Expect %d.", len(answer.Comments), self.comments)
+	}
+	if answer.More != self.more {
+		t.Errorf("Wrong More. Got %t. Expect %t.", answer.More, self.more)
+	}
+}
+
+func TestCommentHandler(t *testing.T) {
+	precheck(t)
+
+	const qLock = `UPDATE Polls SET LockComments = TRUE WHERE Id = ?`
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	userId := env.CreateUserWith("User")
+	pollId := env.CreatePoll("Comment", adminId, db.ElectorateAll)
+	lockedId := env.CreatePoll("Locked", adminId, db.ElectorateAll)
+	env.QuietExec(qLock, lockedId)
+	env.NextRound(pollId)
+	env.NextRound(lockedId)
+	env.Must(t)
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Post",
+			Request: makePollPOSTRequest(t, pollId, &userId, CommentQuery{Round: 1, Content: "Hello"}),
+			Checker: commentsChecker{poll: pollId, round: 1, comments: 1},
+		},
+		&srvt.T{
+			Name:    "Previous round",
+			Request: makePollPOSTRequest(t, pollId, &adminId, CommentQuery{Round: 0, Content: "Late"}),
+			Checker: commentsChecker{poll: pollId, round: 0, comments: 1},
+		},
+		&srvt.T{
+			Name:    "Empty",
+			Request: makePollPOSTRequest(t, pollId, &userId, CommentQuery{Round: 1}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Wrong length"},
+		},
+		&srvt.T{
+			Name: "Too long",
+			Request: makePollPOSTRequest(t, pollId, &userId,
+				CommentQuery{Round: 1, Content: strings.Repeat("a", maxCommentLength+1)}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Wrong length"},
+		},
+		&srvt.T{
+			Name:    "Future round",
+			Request: makePollPOSTRequest(t, pollId, &userId, CommentQuery{Round: 2, Content: "Soon"}),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		&srvt.T{
+			Name:    "Unlogged",
+			Request: makePollPOSTRequest(t, pollId, nil, CommentQuery{Round: 1, Content: "Anonymous"}),
+			Checker: srvt.CheckStatus{http.StatusUnauthorized},
+		},
+		&srvt.T{
+			Name:    "Locked current round",
+			Request: makePollPOSTRequest(t, lockedId, &userId, CommentQuery{Round: 1, Content: "Now"}),
+			Checker: commentsChecker{poll: lockedId, round: 1, comments: 1},
+		},
+		&srvt.T{
+			Name:    "Locked previous round",
+			Request: makePollPOSTRequest(t, lockedId, &userId, CommentQuery{Round: 0, Content: "Late"}),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Read only"},
+		},
+	}
+	srvt.RunFunc(t, tests, CommentHandler)
+}
+
+func TestCommentsHandler(t *testing.T) {
+	precheck(t)
+
+	const qComment = `INSERT INTO Comments (Poll, Round, User, Content) VALUE (?, 0, ?, ?)`
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	pollId := env.CreatePoll("Comments", adminId, db.ElectorateAll)
+	for i := 0; i < commentsPageSize+5; i++ {
+		env.QuietExec(qComment, pollId, adminId, "Comment "+strconv.Itoa(i))
+	}
+	env.Must(t)
+
+	pageRequest := func(page int) srvt.Request {
+		encoded, err := salted.Segment{Salt: dbt.PollSalt, Id: pollId}.Encode()
+		mustt(t, err)
+		target := "/a/test/0/" + strconv.Itoa(page) + "/" + encoded
+		return srvt.Request{Target: &target, UserId: &adminId}
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Default",
+			Request: *makePollRequest(t, pollId, &adminId),
+			Checker: commentsPageChecker{comments: commentsPageSize, more: true},
+		},
+		&srvt.T{
+			Name:    "Second page",
+			Request: pageRequest(1),
+			Checker: commentsPageChecker{comments: 5},
+		},
+		&srvt.T{
+			Name:    "Empty page",
+			Request: pageRequest(2),
+			Checker: commentsPageChecker{},
+		},
+	}
+	srvt.RunFunc(t, tests, CommentsHandler)
+}
+
+func TestDeleteCommentHandler(t *testing.T) {
+	precheck(t)
+
+	const qComment = `INSERT INTO Comments (Poll, Round, User, Content) VALUE (?, 0, ?, 'Spam')`
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	userId := env.CreateUserWith("User")
+	pollId := env.CreatePoll("Delete", adminId, db.ElectorateAll)
+	env.Must(t)
+	result, err := db.DB.Exec(qComment, pollId, userId)
+	mustt(t, err)
+	commentId, err := db.IdFromResult(result)
+	mustt(t, err)
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Not admin",
+			Request: makePollPOSTRequest(t, pollId, &userId, CommentDeleteQuery{Id: commentId}),
+			Checker: srvt.CheckStatus{http.StatusNotFound},
+		},
+		&srvt.T{
+			Name:    "Delete",
+			Request: makePollPOSTRequest(t, pollId, &adminId, CommentDeleteQuery{Id: commentId}),
+			Checker: commentsChecker{poll: pollId, round: 0, comments: 0},
+		},
+		&srvt.T{
+			Name:    "Already deleted",
+			Request: makePollPOSTRequest(t, pollId, &adminId, CommentDeleteQuery{Id: commentId}),
+			Checker: srvt.CheckError{Code: http.StatusNotFound, Body: "Not found"},
+		},
+	}
+	srvt.RunFunc(t, tests, DeleteCommentHandler)
+}
@@ -23,15 +23,23 @@ import (
 
 	"github.com/JBoudou/Itero/mid/db"
 	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/rules"
 )
 
+// CountInfoEntry is the result of an alternative in a round. Count is the total weight of the
+// ballots in which the alternative has the best rank, and Score is the score given by the rule of
+// the poll.
 type CountInfoEntry struct {
 	Alternative PollAlternative
 	Count       uint32
+	Score       float64
 }
 
+// CountInfoAnswer is the result of a round. Delegated is the number of ballots cast through
+// delegation.
 type CountInfoAnswer struct {
-	Result     []CountInfoEntry
+	Result    []CountInfoEntry
+	Delegated uint32
 }
 
 func getPollRoundFromRequest(request *server.Request, fallback uint8) uint8 {
@@ -45,75 +53,64 @@ func getPollRoundFromRequest(request *server.Request, fallback uint8) uint8 {
 	return fallback
 }
 
-// CountInfoEntry sends the plurality result of a previous round.
-func CountInfoHandler(ctx context.Context, response server.Response, request *server.Request) {
-	pollInfo, err := checkPollAccess(ctx, request)
-	must(err)
-
-	// Get the round to return results of.
-	round := getPollRoundFromRequest(request, pollInfo.CurrentRound - 1)
+// getInfoRound returns the round whose result is requested. It defaults to the previous round.
+// An error is returned if the round is not finished.
+func getInfoRound(request *server.Request, pollInfo PollInfo) (round uint8, err error) {
+	round = getPollRoundFromRequest(request, pollInfo.CurrentRound-1)
 	if round >= pollInfo.CurrentRound {
 		err = server.NewHttpError(http.StatusBadRequest, "Protocol error", "No result for this round")
-		response.SendError(ctx, err)
-		return
 	}
+	return
+}
 
-	var answer CountInfoAnswer
-	answer.Result = make([]CountInfoEntry, pollInfo.NbChoices)
-
-	const (
-		qReport = `SELECT ReportVote FROM Polls WHERE Id = ?`
-		qCountAbstain = `
-		  SELECT a.Id, a.Name, a.Cost, IFNULL(b.Count,0)
-		    FROM (
-		          SELECT *
-		            FROM Alternatives
-		           WHERE Poll = ?
-		         ) AS a LEFT JOIN (
-		           SELECT Poll, Alternative as Id, COUNT(*) as Count
-		             FROM Ballots
-		            WHERE Round = ?
-		            GROUP BY Poll, Alternative
-		         ) AS b ON (a.Poll, a.Id) = (b.Poll, b.Id)
-		   ORDER BY b.Count DESC, a.Id ASC`
-		qCountReport = `
-		  SELECT a.Id, a.Name, a.Cost, IFNULL(b.Count,0)
-		    FROM (
-		          SELECT *
-		            FROM Alternatives
-		           WHERE Poll = ?
-		         ) AS a LEFT JOIN (
-		           SELECT b.Poll, b.Alternative as Id, COUNT(*) as Count
-		             FROM Ballots AS b JOIN (
-		                    SELECT User, Poll, MAX(Round) as M
-		                      FROM Participants
-		                     WHERE Round <= ?
-		                     GROUP BY User, Poll
-		             ) AS c ON (b.User, b.Poll, b.Round) = (c.User, c.Poll, c.M)
-		            GROUP BY b.Poll, b.Alternative
-		         ) AS b ON (a.Poll, a.Id) = (b.Poll, b.Id)
-		   ORDER BY b.Count DESC, a.Id ASC`
-	)
+// countEntries computes the result of a round from its profile, sorted according to the rule of
+// the poll. Errors are sent by panic.
+func countEntries(ctx context.Context, pollInfo PollInfo, profile roundProfile) []CountInfoEntry {
+	outcome := pollOutcome(pollInfo, profile)
+	counts := make([]float64, pollInfo.NbChoices)
+	if profile.ranks != nil {
+		for _, result := range (rules.Plurality{}).Outcome(profile.ranks) {
+			counts[result.Alternative] = result.Score
+		}
+	}
+	var alternatives []PollAlternative
+	allAlternatives(ctx, pollInfo, &alternatives)
 
-	row := db.DB.QueryRowContext(ctx, qReport, pollInfo.Id)
-	var reportVote bool
-	must(row.Scan(&reportVote))
-	var query string
-	if reportVote {
-		query = qCountReport
-	} else {
-		query = qCountAbstain
+	ret := make([]CountInfoEntry, len(outcome))
+	for i, result := range outcome {
+		ret[i] = CountInfoEntry{
+			Alternative: alternatives[result.Alternative],
+			Count:       uint32(counts[result.Alternative]),
+			Score:       result.Score,
+		}
 	}
+	return ret
+}
 
-	rows, err := db.DB.QueryContext(ctx, query, pollInfo.Id, round)
+// CountInfoHandler sends the result of a previous round.
+// Alternatives are sorted according to the rule of the poll. The field Score is the score given by
+// that rule, while the field Count is the total weight of the ballots in which the alternative has
+// the best rank. When votes are reported, the whole last ballot of each participant is reported.
+// The result is sent only if the poll gives full counts.
+func CountInfoHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
 	must(err)
-	for i := 0; rows.Next(); i++ {
-		must(rows.Scan(&answer.Result[i].Alternative.Id,
-			&answer.Result[i].Alternative.Name,
-			&answer.Result[i].Alternative.Cost,
-			&answer.Result[i].Count))
+	if err = pollInfo.checkInformation(db.InformationCounts); err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+
+	// Get the round to return results of.
+	round, err := getInfoRound(request, pollInfo)
+	if err != nil {
+		response.SendError(ctx, err)
+		return
 	}
 
-	response.SendJSON(ctx, answer)
+	profile := loadRoundProfile(ctx, pollInfo, round)
+	response.SendJSON(ctx, CountInfoAnswer{
+		Result:    countEntries(ctx, pollInfo, profile),
+		Delegated: profile.delegated,
+	})
 	return
 }
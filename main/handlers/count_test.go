@@ -73,6 +73,7 @@ func TestCountInfoHandler(t *testing.T) {
 		for i, val := range result {
 			entries[i].Alternative = altAns[val[0]]
 			entries[i].Count = val[1]
+			entries[i].Score = float64(val[1])
 		}
 		return srvt.CheckJSON{Body: CountInfoAnswer{Result: entries}}
 	}
@@ -254,3 +255,110 @@ func TestCountInfoHandler(t *testing.T) {
 	}
 	srvt.RunFunc(t, tests, CountInfoHandler)
 }
+
+func TestCountInfoHandler_Ranked(t *testing.T) {
+	precheck(t)
+
+	const (
+		qRanked = `UPDATE Polls SET Type = ?, ReportVote = TRUE WHERE Id = ?`
+		qRank   = `
+		  INSERT INTO Ballots (Poll, Round, User, Alternative, Rank) VALUE (?, 0, ?, ?, ?)`
+	)
+
+	var env dbt.Env
+	defer env.Close()
+	var users [2]uint32
+	for i := range users {
+		users[i] = env.CreateUserWith(strconv.FormatInt(int64(i), 10))
+	}
+
+	pollId := env.CreatePollWith("Test", users[0], db.ElectorateAll, []string{"Ham", "Stram", "Gram"})
+	env.QuietExec(qRanked, db.PollTypeRanked, pollId)
+	// User 0 ranks Gram > Ham > Stram. User 1 ranks Stram > Gram.
+	env.Vote(pollId, 0, users[0], 2)
+	env.QuietExec(qRank, pollId, users[0], 0, 2)
+	env.QuietExec(qRank, pollId, users[0], 1, 3)
+	env.Vote(pollId, 0, users[1], 1)
+	env.QuietExec(qRank, pollId, users[1], 2, 2)
+	env.NextRound(pollId)
+	env.NextRound(pollId)
+	env.Must(t)
+
+	expect := CountInfoAnswer{Result: []CountInfoEntry{
+		{Alternative: PollAlternative{Id: 1, Name: "Stram", Cost: 1}, Count: 1, Score: 1},
+		{Alternative: PollAlternative{Id: 2, Name: "Gram", Cost: 1}, Count: 1, Score: 1},
+		{Alternative: PollAlternative{Id: 0, Name: "Ham", Cost: 1}, Count: 0, Score: 0},
+	}}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Carry forward rankings",
+			Request: *makePollRequest(t, pollId, &users[0]),
+			Checker: srvt.CheckJSON{Body: expect},
+		},
+	}
+	srvt.RunFunc(t, tests, CountInfoHandler)
+}
+
+func TestCountInfoHandler_Rule(t *testing.T) {
+	precheck(t)
+
+	const (
+		qRule = `UPDATE Polls SET Type = ?, Rule = ? WHERE Id = ?`
+		qRank = `
+		  INSERT INTO Ballots (Poll, Round, User, Alternative, Rank) VALUE (?, 0, ?, ?, ?)`
+	)
+
+	var env dbt.Env
+	defer env.Close()
+	var users [3]uint32
+	for i := range users {
+		users[i] = env.CreateUserWith(strconv.FormatInt(int64(i), 10))
+	}
+
+	pollId := env.CreatePollWith("Test", users[0], db.ElectorateAll, []string{"Ham", "Stram", "Gram"})
+	env.QuietExec(qRule, db.PollTypeRanked, db.PollRuleBorda, pollId)
+	// Ham > Stram > Gram, Stram > Gram > Ham, Gram > Stram > Ham
+	rankings := [3][3]uint8{{0, 1, 2}, {1, 2, 0}, {2, 1, 0}}
+	for i, ranking := range rankings {
+		env.Vote(pollId, 0, users[i], ranking[0])
+		env.QuietExec(qRank, pollId, users[i], ranking[1], 2)
+		env.QuietExec(qRank, pollId, users[i], ranking[2], 3)
+	}
+	env.NextRound(pollId)
+	env.Must(t)
+
+	expect := CountInfoAnswer{Result: []CountInfoEntry{
+		{Alternative: PollAlternative{Id: 1, Name: "Stram", Cost: 1}, Count: 1, Score: 4},
+		{Alternative: PollAlternative{Id: 2, Name: "Gram", Cost: 1}, Count: 1, Score: 3},
+		{Alternative: PollAlternative{Id: 0, Name: "Ham", Cost: 1}, Count: 1, Score: 2},
+	}}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Borda",
+			Request: *makePollRequest(t, pollId, &users[0]),
+			Checker: srvt.CheckJSON{Body: expect},
+		},
+	}
+	srvt.RunFunc(t, tests, CountInfoHandler)
+}
+
+func TestCountInfoHandler_Information(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	create, userId := createInformationPolls(&env)
+	topPoll := create(db.InformationTop, 3)
+	env.Must(t)
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Top",
+			Request: *makePollRequest(t, topPoll, &userId),
+			Checker: srvt.CheckError{Code: http.StatusForbidden, Body: "Hidden information"},
+		},
+	}
+	srvt.RunFunc(t, tests, CountInfoHandler)
+}
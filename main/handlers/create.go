@@ -38,6 +38,7 @@ const (
 	CreatePollElectorateAll CreatePollElectorate = iota - 1
 	CreatePollElectorateLogged
 	CreatePollElectorateVerified
+	CreatePollElectorateInvited
 )
 
 func (self CreatePollElectorate) ToDB() db.Electorate {
@@ -46,11 +47,103 @@ func (self CreatePollElectorate) ToDB() db.Electorate {
 		return db.ElectorateAll
 	case CreatePollElectorateVerified:
 		return db.ElectorateVerified
+	case CreatePollElectorateInvited:
+		return db.ElectorateInvited
 	default:
 		return db.ElectorateLogged
 	}
 }
 
+type CreatePollInformation uint8
+
+const (
+	CreatePollInformationCounts CreatePollInformation = iota
+	CreatePollInformationRanking
+	CreatePollInformationWinner
+	CreatePollInformationTop
+	CreatePollInformationNone
+)
+
+func (self CreatePollInformation) ToDB() db.Information {
+	switch self {
+	case CreatePollInformationRanking:
+		return db.InformationRanking
+	case CreatePollInformationWinner:
+		return db.InformationWinner
+	case CreatePollInformationTop:
+		return db.InformationTop
+	case CreatePollInformationNone:
+		return db.InformationNone
+	default:
+		return db.InformationCounts
+	}
+}
+
+type CreatePollRoundType uint8
+
+const (
+	CreatePollRoundTypeFreelyAsynchronous CreatePollRoundType = iota
+	CreatePollRoundTypeSequential
+	CreatePollRoundTypeRandomSequential
+	CreatePollRoundTypeSynchronous
+)
+
+func (self CreatePollRoundType) ToDB() uint8 {
+	switch self {
+	case CreatePollRoundTypeSequential:
+		return db.RoundTypeSequential
+	case CreatePollRoundTypeRandomSequential:
+		return db.RoundTypeRandomSequential
+	case CreatePollRoundTypeSynchronous:
+		return db.RoundTypeSynchronous
+	default:
+		return db.RoundTypeFreelyAsynchronous
+	}
+}
+
+type CreatePollRule uint8
+
+const (
+	CreatePollRulePlurality CreatePollRule = iota
+	CreatePollRuleBorda
+	CreatePollRuleCopeland
+	CreatePollRuleSchulze
+	CreatePollRuleInstantRunoff
+	CreatePollRuleGreedyApproval
+	CreatePollRuleEqualShares
+	CreatePollRuleMajorityJudgment
+	CreatePollRuleRangeVoting
+)
+
+// IsGrading returns whether the rule is for grading polls.
+func (self CreatePollRule) IsGrading() bool {
+	return self == CreatePollRuleMajorityJudgment || self == CreatePollRuleRangeVoting
+}
+
+func (self CreatePollRule) ToDB() uint8 {
+	switch self {
+	case CreatePollRuleBorda:
+		return db.PollRuleBorda
+	case CreatePollRuleCopeland:
+		return db.PollRuleCopeland
+	case CreatePollRuleSchulze:
+		return db.PollRuleSchulze
+	case CreatePollRuleInstantRunoff:
+		return db.PollRuleInstantRunoff
+	case CreatePollRuleGreedyApproval:
+		return db.PollRuleGreedyApproval
+	case CreatePollRuleEqualShares:
+		return db.PollRuleEqualShares
+	case CreatePollRuleMajorityJudgment:
+		return db.PollRuleMajorityJudgment
+	case CreatePollRuleRangeVoting:
+		return db.PollRuleRangeVoting
+	default:
+		return db.PollRulePlurality
+	}
+}
+
+// SimpleAlternative is an alternative in a CreateQuery. A zero Cost means a cost of 1.
 type SimpleAlternative struct {
 	Name string
 	Cost float64
@@ -70,8 +163,53 @@ type CreateQuery struct {
 	MaxRoundDuration uint64 // milliseconds
 	RoundThreshold   float64
 	ShortURL         string
+
+	// If ThresholdOnWeight is true, RoundThreshold is a proportion of the total weight of the
+	// participants rather than a proportion of their number.
+	ThresholdOnWeight bool
+
+	// Ballot must be either BallotTypeUninominal, BallotTypeApproval, BallotTypeRanked or
+	// BallotTypeGrading. For approval polls, MaxBallotCost defaults to the number of alternatives if
+	// BallotCostIsCount is true, and to MaxOutcomeCost otherwise. For grading polls, Grades is the
+	// scale, from the worst grade to the best one. It defaults to defaultGrades. Rule defaults to
+	// majority judgment for grading polls, and must not be a grading rule for other polls.
+	Ballot            BallotType
+	MaxBallotCost     float64
+	BallotCostIsCount bool
+	Rule              CreatePollRule
+	Grades            []string
+
+	// MaxOutcomeCost is the budget of the poll. It defaults to the highest cost of the alternatives.
+	MaxOutcomeCost float64
+
+	// Information is what participants are told about previous rounds. For
+	// CreatePollInformationTop, InformationTop is the number of alternatives given.
+	Information    CreatePollInformation
+	InformationTop uint8
+
+	// RoundType tells whether participants move all together, one at a time, or all together with
+	// sealed ballots.
+	RoundType CreatePollRoundType
+
+	// MinCellSize is the smallest number of participants disclosed in transition matrices.
+	MinCellSize uint8
+
+	// If StopStableProfile is true, the poll is terminated as soon as all ballots are unchanged
+	// during a round. If StopStableWinner is positive, the poll is terminated as soon as the winner
+	// did not change during StopStableWinner rounds.
+	StopStableProfile bool
+	StopStableWinner  uint8
+
+	// If LockComments is true, comments on a round cannot be posted once the round is over.
+	LockComments bool
+
+	// If Proposals is true, participants can propose alternatives until the poll starts. Start must
+	// then be in the future.
+	Proposals bool
 }
 
+var defaultGrades = []string{"Reject", "Poor", "Acceptable", "Good", "Very good", "Excellent"}
+
 func defaultCreateQuery() CreateQuery {
 	return CreateQuery{
 		ReportVote:       true,
@@ -80,27 +218,30 @@ func defaultCreateQuery() CreateQuery {
 		Deadline:         time.Now().Add(7 * 24 * time.Hour),
 		MaxRoundDuration: 24 * 3600 * 1000,
 		RoundThreshold:   1.,
-	}
-}
 
-type createHandler struct {
-	evtManager events.Manager
-}
+		Ballot:            BallotTypeUninominal,
+		BallotCostIsCount: true,
 
-// CreateHandler creates a new poll.
-func CreateHandler(evtManager events.Manager) createHandler {
-	return createHandler{evtManager: evtManager}
+		InformationTop: 3,
+	}
 }
 
-func (self createHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
-	if request.User == nil || !request.User.Logged {
-		must(request.SessionError)
-		panic(server.UnauthorizedHttpError("Unlogged user"))
-	}
-	must(request.CheckPOST(ctx))
+// pollDefinition is a CreateQuery that passed all the verifications, together with the values
+// derived from it.
+type pollDefinition struct {
+	CreateQuery
+	pollType   uint8
+	state      string
+	start      sql.NullTime
+	electorate db.Electorate
+	shortURL   sql.NullString
+}
 
-	query := defaultCreateQuery()
-	must(request.UnmarshalJSONBody(&query))
+// checkCreateQuery verifies the query and fills default values, for a poll administrated by user.
+// Errors are sent by panic.
+func checkCreateQuery(ctx context.Context, raw CreateQuery, user uint32) (def pollDefinition) {
+	def.CreateQuery = raw
+	query := &def.CreateQuery
 
 	if len(query.Title) < 1 {
 		must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Missing title"))
@@ -109,6 +250,99 @@ func (self createHandler) Handle(ctx context.Context, response server.Response,
 		must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Too few alternatives"))
 	}
 
+	// Costs
+	var maxCost float64
+	for i := range query.Alternatives {
+		cost := &query.Alternatives[i].Cost
+		if *cost == 0 {
+			*cost = 1
+		}
+		if *cost < 0 {
+			must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Negative cost"))
+		}
+		if *cost > maxCost {
+			maxCost = *cost
+		}
+	}
+	if query.MaxOutcomeCost == 0 {
+		query.MaxOutcomeCost = maxCost
+	}
+	if query.MaxOutcomeCost < maxCost {
+		must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Cost exceeds MaxOutcomeCost"))
+	}
+
+	// Ballot
+	pollType := db.PollTypeAcceptanceSet
+	switch query.Ballot {
+	case BallotTypeRanked:
+		pollType = db.PollTypeRanked
+		query.MaxBallotCost = float64(len(query.Alternatives))
+		query.BallotCostIsCount = true
+	case BallotTypeUninominal:
+		query.MaxBallotCost = 1
+		query.BallotCostIsCount = true
+	case BallotTypeApproval:
+		if query.MaxBallotCost == 0 {
+			if query.BallotCostIsCount {
+				query.MaxBallotCost = float64(len(query.Alternatives))
+			} else {
+				query.MaxBallotCost = query.MaxOutcomeCost
+			}
+		}
+		if query.MaxBallotCost < 0 {
+			must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Negative MaxBallotCost"))
+		}
+		if !query.BallotCostIsCount && query.MaxBallotCost < maxCost {
+			must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Cost exceeds MaxBallotCost"))
+		}
+	case BallotTypeGrading:
+		pollType = db.PollTypeGrading
+		query.MaxBallotCost = float64(len(query.Alternatives))
+		query.BallotCostIsCount = true
+		if query.Rule == CreatePollRulePlurality {
+			query.Rule = CreatePollRuleMajorityJudgment
+		}
+		if query.Grades == nil {
+			query.Grades = defaultGrades
+		}
+		if len(query.Grades) < 2 {
+			must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Too few grades"))
+		}
+		if len(query.Grades) > 255 {
+			must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Too many grades"))
+		}
+		seen := make(map[string]bool, len(query.Grades))
+		for _, grade := range query.Grades {
+			if grade == "" {
+				must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Empty grade"))
+			}
+			if seen[grade] {
+				must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Duplicated grade"))
+			}
+			seen[grade] = true
+		}
+	default:
+		must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Unsupported ballot type"))
+	}
+	if query.Rule > CreatePollRuleRangeVoting {
+		must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Unknown rule"))
+	}
+	if query.Rule.IsGrading() != (query.Ballot == BallotTypeGrading) {
+		must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Rule incompatible with ballot"))
+	}
+
+	// Information
+	if query.Information > CreatePollInformationNone {
+		must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Unknown information mode"))
+	}
+	if query.Information == CreatePollInformationTop && query.InformationTop < 1 {
+		must(server.NewHttpError(http.StatusBadRequest, "Bad request", "InformationTop must be positive"))
+	}
+
+	if query.RoundType > CreatePollRoundTypeSynchronous {
+		must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Unknown round type"))
+	}
+
 	// Start
 	var start sql.NullTime
 	var state string
@@ -122,12 +356,15 @@ func (self createHandler) Handle(ctx context.Context, response server.Response,
 		}
 		state = "Active"
 	}
+	if query.Proposals && !start.Valid {
+		must(server.NewHttpError(http.StatusBadRequest, "Bad request", "Proposals need a future start"))
+	}
 
 	// Electorate
 	electorate := query.Electorate.ToDB()
 	const qVerified = `SELECT 1 FROM Users WHERE Id = ? AND Verified`
 	if electorate == db.ElectorateVerified {
-		rows, err := db.DB.QueryContext(ctx, qVerified, request.User.Id)
+		rows, err := db.DB.QueryContext(ctx, qVerified, user)
 		must(err)
 		defer rows.Close()
 		if !rows.Next() {
@@ -146,6 +383,51 @@ func (self createHandler) Handle(ctx context.Context, response server.Response,
 		shortURL.Valid = true
 	}
 
+	def.pollType = pollType
+	def.state = state
+	def.start = start
+	def.electorate = electorate
+	def.shortURL = shortURL
+	return
+}
+
+// insertAlternatives adds the alternatives and the grades of the poll.
+func (self *pollDefinition) insertAlternatives(ctx context.Context, tx *sql.Tx, pollId uint32) {
+	const (
+		qAlternative = `INSERT INTO Alternatives (Poll, Id, Name, Cost) VALUE (?, ?, ?, ?)`
+		qGrade       = `INSERT INTO Grades (Poll, Id, Name) VALUE (?, ?, ?)`
+	)
+	for id, alt := range self.Alternatives {
+		_, err := tx.ExecContext(ctx, qAlternative, pollId, id, alt.Name, alt.Cost)
+		must(err)
+	}
+	if self.pollType == db.PollTypeGrading {
+		for id, grade := range self.Grades {
+			_, err := tx.ExecContext(ctx, qGrade, pollId, id, grade)
+			must(err)
+		}
+	}
+}
+
+// wrapSQLError returns a Conflict error if err is due to an already existing ShortURL.
+// Otherwise err is returned.
+func (self *pollDefinition) wrapSQLError(ctx context.Context, tx *sql.Tx, err error) error {
+	const qCheckShortURL = `SELECT 1 FROM Polls WHERE ShortURL = ?`
+	sqlError, ok := err.(*mysql.MySQLError)
+	if ok && sqlError.Number == 1062 && self.shortURL.Valid {
+		rows, tmpErr := tx.QueryContext(ctx, qCheckShortURL, self.shortURL)
+		must(tmpErr)
+		defer rows.Close()
+		if rows.Next() {
+			return server.NewHttpError(http.StatusConflict, "ShortURL already exists", self.shortURL.String)
+		}
+	}
+	return err
+}
+
+// insert adds the poll to the database, with the given administrator.
+// Errors are sent by panic.
+func (self *pollDefinition) insert(ctx context.Context, admin uint32) (pollSegment salted.Segment) {
 	pollSegment, err := salted.New(0)
 	must(err)
 
@@ -153,52 +435,79 @@ func (self createHandler) Handle(ctx context.Context, response server.Response,
 		qPoll = `
 			INSERT INTO Polls (Title, Description, Admin, State, Start, ShortURL, Salt, Electorate, Hidden,
 			                   NbChoices, ReportVote, MinNbRounds, MaxNbRounds, Deadline, MaxRoundDuration,
-												 RoundThreshold)
-				  	 VALUE (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-		qAlternative = `INSERT INTO Alternatives (Poll, Id, Name) VALUE (?, ?, ?)`
-		qCheckShortURL = `SELECT 1 FROM Polls WHERE ShortURL = ?`
+												 RoundThreshold, Type, Rule, MaxOutcomeCost, MaxBallotCost, BallotCostIsCount,
+			                   Information, InformationTop, RoundType, MinCellSize, StopStableProfile,
+			                   StopStableWinner, ThresholdOnWeight, LockComments, Proposals)
+				  	 VALUE (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	)
 
 	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
 		result, err := tx.ExecContext(ctx, qPoll,
-			query.Title,
-			query.Description,
-			request.User.Id,
-			state,
-			start,
-			shortURL,
+			self.Title,
+			self.Description,
+			admin,
+			self.state,
+			self.start,
+			self.shortURL,
 			pollSegment.Salt,
-			electorate,
-			query.Hidden,
-			len(query.Alternatives),
-			query.ReportVote,
-			query.MinNbRounds,
-			query.MaxNbRounds,
-			query.Deadline,
-			db.DurationToTime(time.Duration(query.MaxRoundDuration)*time.Millisecond),
-			query.RoundThreshold,
+			self.electorate,
+			self.Hidden,
+			len(self.Alternatives),
+			self.ReportVote,
+			self.MinNbRounds,
+			self.MaxNbRounds,
+			self.Deadline,
+			db.DurationToTime(time.Duration(self.MaxRoundDuration)*time.Millisecond),
+			self.RoundThreshold,
+			self.pollType,
+			self.Rule.ToDB(),
+			self.MaxOutcomeCost,
+			self.MaxBallotCost,
+			self.BallotCostIsCount,
+			self.Information.ToDB(),
+			self.InformationTop,
+			self.RoundType.ToDB(),
+			self.MinCellSize,
+			self.StopStableProfile,
+			self.StopStableWinner,
+			self.ThresholdOnWeight,
+			self.LockComments,
+			self.Proposals,
 		)
 		if err != nil {
-			sqlError, ok := err.(*mysql.MySQLError)
-			if ok && sqlError.Number == 1062 && shortURL.Valid {
-				rows, tmpErr := tx.QueryContext(ctx, qCheckShortURL, shortURL)
-				must(tmpErr)
-				defer rows.Close()
-				if rows.Next() {
-					err = server.NewHttpError(http.StatusConflict, "ShortURL already exists", shortURL.String)
-				}
-			}
-			panic(err)
+			panic(self.wrapSQLError(ctx, tx, err))
 		}
 		tmp, err := result.LastInsertId()
 		must(err)
 		pollSegment.Id = uint32(tmp)
-		for id, alt := range query.Alternatives {
-			_, err = tx.ExecContext(ctx, qAlternative, pollSegment.Id, id, alt.Name)
-			must(err)
-		}
+		self.insertAlternatives(ctx, tx, pollSegment.Id)
 	})
 
+	return
+}
+
+type createHandler struct {
+	evtManager events.Manager
+}
+
+// CreateHandler creates a new poll.
+func CreateHandler(evtManager events.Manager) createHandler {
+	return createHandler{evtManager: evtManager}
+}
+
+func (self createHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
+	if request.User == nil || !request.User.Logged {
+		must(request.SessionError)
+		panic(server.UnauthorizedHttpError("Unlogged user"))
+	}
+	must(request.CheckPOST(ctx))
+
+	query := defaultCreateQuery()
+	must(request.UnmarshalJSONBody(&query))
+
+	def := checkCreateQuery(ctx, query, request.User.Id)
+
+	pollSegment := def.insert(ctx, request.User.Id)
 	segment, err := pollSegment.Encode()
 	must(err)
 	response.SendJSON(ctx, segment)
@@ -17,6 +17,7 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
@@ -107,7 +108,7 @@ func (self *createPollTest) Check(t *testing.T, response *http.Response, request
 						 ADDTIME(CurrentRoundStart, MaxRoundDuration), RoundThreshold
 			  FROM Polls
 			 WHERE Id = ?`
-		qCheckAlternative = `SELECT Name FROM Alternatives WHERE Poll = ? ORDER BY Id ASC`
+		qCheckAlternative = `SELECT Name, Cost FROM Alternatives WHERE Poll = ? ORDER BY Id ASC`
 		qCleanUp          = `DELETE FROM Polls WHERE Id = ?`
 	)
 
@@ -193,16 +194,52 @@ func (self *createPollTest) Check(t *testing.T, response *http.Response, request
 			break
 		}
 		var name string
-		mustt(t, rows.Scan(&name))
+		var cost float64
+		mustt(t, rows.Scan(&name, &cost))
 		if name != alt.Name {
 			t.Errorf("Wrong alternative %d. Got %s. Expect %s.", id, name, alt.Name)
 		}
+		expectCost := alt.Cost
+		if expectCost == 0 {
+			expectCost = 1
+		}
+		if cost != expectCost {
+			t.Errorf("Wrong cost for alternative %d. Got %v. Expect %v.", id, cost, expectCost)
+		}
 	}
 	if rows.Next() {
 		t.Errorf("Unexpected alternatives.")
 		rows.Close()
 	}
 
+	// Check Information
+	const qCheckInformation = `
+		SELECT Information, InformationTop, RoundType FROM Polls WHERE Id = ?`
+	var information db.Information
+	var informationTop, roundType uint8
+	row = db.DB.QueryRow(qCheckInformation, pollSegment.Id)
+	mustt(t, row.Scan(&information, &informationTop, &roundType))
+	if information != query.Information.ToDB() || informationTop != query.InformationTop {
+		t.Errorf("Wrong information. Got %s %d. Expect %s %d.", information, informationTop,
+			query.Information.ToDB(), query.InformationTop)
+	}
+	if roundType != query.RoundType.ToDB() {
+		t.Errorf("Wrong round type. Got %d. Expect %d.", roundType, query.RoundType.ToDB())
+	}
+
+	// Check Grades
+	if query.Ballot == BallotTypeGrading {
+		expect := query.Grades
+		if expect == nil {
+			expect = defaultGrades
+		}
+		var grades []string
+		allGrades(context.Background(), PollInfo{Id: pollSegment.Id}, &grades)
+		if !reflect.DeepEqual(grades, expect) {
+			t.Errorf("Wrong grades. Got %v. Expect %v.", grades, expect)
+		}
+	}
+
 	// Check events
 	countEvent := self.CountRecorderEvents(func(evt events.Event) bool {
 		converted, ok := evt.(services.CreatePollEvent)
@@ -219,6 +256,8 @@ func electorateFromDB(electorate db.Electorate) CreatePollElectorate {
 		return CreatePollElectorateAll
 	case db.ElectorateVerified:
 		return CreatePollElectorateVerified
+	case db.ElectorateInvited:
+		return CreatePollElectorateInvited
 	default:
 		return CreatePollElectorateLogged
 	}
@@ -313,6 +352,101 @@ func TestCreateHandler(t *testing.T) {
 			RequestFct:        RFPostSession(makeBody(`"ShortURL": "CreatePollTest_DuplicateShortURL",`, []string{"First", "Second"})),
 			Checker:           srvt.CheckError{Code: http.StatusConflict, Body: "ShortURL already exists"},
 		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Approval",
+			RequestFct: RFPostSession(makeBody(`"Ballot": 2, "MaxBallotCost": 2,`, []string{"A", "B", "C"})),
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Ranked",
+			RequestFct: RFPostSession(makeBody(`"Ballot": 3, "Rule": 3,`, []string{"A", "B", "C"})),
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Wrong ballot",
+			RequestFct: RFPostSession(makeBody(`"Ballot": 0,`, []string{"A", "B"})),
+			Checker:    srvt.CheckStatus{http.StatusBadRequest},
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Grading",
+			RequestFct: RFPostSession(makeBody(`"Ballot": 4, "Grades": ["Bad", "Good"],`, []string{"A", "B"})),
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Grading default",
+			RequestFct: RFPostSession(makeBody(`"Ballot": 4, "Rule": 8,`, []string{"A", "B"})),
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Too few grades",
+			RequestFct: RFPostSession(makeBody(`"Ballot": 4, "Grades": ["Good"],`, []string{"A", "B"})),
+			Checker:    srvt.CheckStatus{http.StatusBadRequest},
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Duplicated grade",
+			RequestFct: RFPostSession(makeBody(`"Ballot": 4, "Grades": ["Bad", "Bad"],`, []string{"A", "B"})),
+			Checker:    srvt.CheckStatus{http.StatusBadRequest},
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Grading rule for ranked",
+			RequestFct: RFPostSession(makeBody(`"Ballot": 3, "Rule": 7,`, []string{"A", "B"})),
+			Checker:    srvt.CheckStatus{http.StatusBadRequest},
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Information top",
+			RequestFct: RFPostSession(makeBody(`"Information": 3, "InformationTop": 1,`, []string{"A", "B"})),
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Sequential",
+			RequestFct: RFPostSession(makeBody(`"RoundType": 1,`, []string{"A", "B"})),
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Synchronous",
+			RequestFct: RFPostSession(makeBody(`"RoundType": 3,`, []string{"A", "B"})),
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Unknown round type",
+			RequestFct: RFPostSession(makeBody(`"RoundType": 4,`, []string{"A", "B"})),
+			Checker:    srvt.CheckStatus{http.StatusBadRequest},
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Unknown information",
+			RequestFct: RFPostSession(makeBody(`"Information": 5,`, []string{"A", "B"})),
+			Checker:    srvt.CheckStatus{http.StatusBadRequest},
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Empty top",
+			RequestFct: RFPostSession(makeBody(`"Information": 3, "InformationTop": 0,`, []string{"A", "B"})),
+			Checker:    srvt.CheckStatus{http.StatusBadRequest},
+		}),
+		CreatePollTest(createPollTest_{
+			Name: "Budget",
+			RequestFct: RFPostSession(`{
+					"Title": "Test",
+					"Ballot": 2,
+					"Rule": 6,
+					"MaxOutcomeCost": 100,
+					"Alternatives": [{"Name":"Park", "Cost":60}, {"Name":"Bench", "Cost":0}]
+				}`),
+		}),
+		CreatePollTest(createPollTest_{
+			Name: "Negative cost",
+			RequestFct: RFPostSession(`{
+					"Title": "Test",
+					"Alternatives": [{"Name":"Park", "Cost":-1}, {"Name":"Bench", "Cost":1}]
+				}`),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		}),
+		CreatePollTest(createPollTest_{
+			Name: "Cost over budget",
+			RequestFct: RFPostSession(`{
+					"Title": "Test",
+					"MaxOutcomeCost": 10,
+					"Alternatives": [{"Name":"Park", "Cost":60}, {"Name":"Bench", "Cost":1}]
+				}`),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		}),
+		CreatePollTest(createPollTest_{
+			Name:       "Unknown rule",
+			RequestFct: RFPostSession(makeBody(`"Rule": 9,`, []string{"A", "B"})),
+			Checker:    srvt.CheckStatus{http.StatusBadRequest},
+		}),
 	}
 
 	srvt.Run(t, tests, CreateHandler)
@@ -0,0 +1,125 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/JBoudou/Itero/main/services"
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/events"
+	"github.com/JBoudou/Itero/pkg/slog"
+)
+
+// DelegateQuery is the body of delegation requests. Delegate is the name of the user to delegate
+// to. An empty Delegate cancels the delegation of the user for the round.
+type DelegateQuery struct {
+	Delegate string
+	Round    uint8
+}
+
+type delegateHandler struct {
+	evtManager events.Manager
+}
+
+// DelegateHandler records that the ballot of the delegate is to be used instead of the ballot of
+// the logged user, for the current round. Delegations are followed transitively when the ballots
+// are counted. Delegating replaces the ballot of the user for the current round, and voting
+// directly cancels the delegation. The delegate must be allowed to vote in the poll. Delegation is
+// not possible in sequential polls.
+func DelegateHandler(evtManager events.Manager) delegateHandler {
+	return delegateHandler{evtManager: evtManager}
+}
+
+func (self delegateHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
+	if request.User == nil || !request.User.Logged {
+		must(request.SessionError)
+		panic(server.UnauthorizedHttpError("Unlogged user"))
+	}
+	must(request.CheckPOST(ctx))
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+	if !pollInfo.Active {
+		panic(server.NewHttpError(http.StatusLocked, "Inactive poll", "Poll is currently not active"))
+	}
+	if pollInfo.RoundType == db.RoundTypeSequential ||
+		pollInfo.RoundType == db.RoundTypeRandomSequential {
+		panic(server.NewHttpError(http.StatusBadRequest, "Wrong poll", "No delegation in sequential polls"))
+	}
+
+	var query DelegateQuery
+	must(request.UnmarshalJSONBody(&query))
+	must(checkVoteRound(pollInfo, query.Round))
+
+	const (
+		qParticipate = `SELECT 1 FROM Participants WHERE User = ? AND Poll = ? AND Round = ?`
+		qUnvote      = `DELETE FROM Participants WHERE User = ? AND Poll = ? AND Round = ?`
+		qCancel      = `DELETE FROM Delegations WHERE User = ? AND Poll = ? AND Round = ?`
+		qDelegate    = `REPLACE INTO Delegations (Poll, Round, User, Delegate) VALUE (?, ?, ?, ?)`
+		qEligible    = `
+		  SELECT 1 FROM Polls AS p, Users AS u
+		   WHERE p.Id = ? AND u.Id = ?
+		     AND (p.Electorate <> 'Verified' OR u.Verified)
+		     AND (p.Electorate <> 'Invited' OR u.Id = p.Admin
+		          OR EXISTS (SELECT 1 FROM Invitations AS i WHERE i.Poll = p.Id AND i.User = u.Id))
+		     AND NOT EXISTS (SELECT 1 FROM Blocked AS b WHERE b.Poll = p.Id AND b.User = u.Id)`
+	)
+
+	user := request.User.Id
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		if query.Delegate == "" {
+			_, err := tx.ExecContext(ctx, qCancel, user, pollInfo.Id, pollInfo.CurrentRound)
+			must(err)
+			return
+		}
+
+		delegate := userByName(ctx, tx, query.Delegate)
+		if delegate == user {
+			panic(server.NewHttpError(http.StatusBadRequest, "Bad request", "Cannot delegate to oneself"))
+		}
+		rows, err := tx.QueryContext(ctx, qEligible, pollInfo.Id, delegate)
+		must(err)
+		eligible := rows.Next()
+		must(rows.Close())
+		if !eligible {
+			panic(server.NewHttpError(http.StatusBadRequest, "Bad request",
+				"The delegate cannot vote in this poll"))
+		}
+
+		if pollInfo.RoundType == db.RoundTypeSynchronous {
+			rows, err := tx.QueryContext(ctx, qParticipate, user, pollInfo.Id, pollInfo.CurrentRound)
+			must(err)
+			voted := rows.Next()
+			must(rows.Close())
+			if voted {
+				panic(server.NewHttpError(http.StatusConflict, "Sealed ballot",
+					"The ballot for this round has already been submitted"))
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, qUnvote, user, pollInfo.Id, pollInfo.CurrentRound)
+		must(err)
+		_, err = tx.ExecContext(ctx, qDelegate, pollInfo.Id, pollInfo.CurrentRound, user, delegate)
+		must(err)
+	})
+
+	response.SendJSON(ctx, "Ok")
+	self.evtManager.Send(services.VoteEvent{pollInfo.Id})
+}
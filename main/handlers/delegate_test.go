@@ -0,0 +1,151 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+func TestDelegateHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	voterId := env.CreateUserWith("Voter")
+	delegatorId := env.CreateUserWith("Delegator")
+	chainId := env.CreateUserWith("Chain")
+	pollId := env.CreatePoll("Delegation", adminId, db.ElectorateAll)
+	env.Vote(pollId, 0, voterId, 1)
+	env.Must(t)
+
+	makeRequest := func(user *uint32, delegate string, round uint8) srvt.Request {
+		req := *makePollRequest(t, pollId, user)
+		b, err := json.Marshal(DelegateQuery{Delegate: delegate, Round: round})
+		mustt(t, err)
+		req.Body = string(b)
+		req.Method = "POST"
+		return req
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Delegate",
+			Request: makeRequest(&delegatorId, dbt.UserNameWith("Voter"), 0),
+			Checker: srvt.CheckStatus{http.StatusOK},
+		},
+		&srvt.T{
+			Name:    "Chain",
+			Request: makeRequest(&chainId, dbt.UserNameWith("Delegator"), 0),
+			Checker: srvt.CheckStatus{http.StatusOK},
+		},
+		&srvt.T{
+			Name:    "Oneself",
+			Request: makeRequest(&voterId, dbt.UserNameWith("Voter"), 0),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		&srvt.T{
+			Name:    "Unknown delegate",
+			Request: makeRequest(&voterId, "nobody", 0),
+			Checker: srvt.CheckError{Code: http.StatusNotFound, Body: "Unknown user"},
+		},
+		&srvt.T{
+			Name:    "Wrong round",
+			Request: makeRequest(&delegatorId, dbt.UserNameWith("Voter"), 2),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		&srvt.T{
+			Name:    "No user",
+			Request: makeRequest(nil, dbt.UserNameWith("Voter"), 0),
+			Checker: srvt.CheckStatus{http.StatusUnauthorized},
+		},
+	}
+	srvt.Run(t, tests, DelegateHandler)
+
+	env.NextRound(pollId)
+	env.Must(t)
+
+	tests = []srvt.Test{
+		&srvt.T{
+			Name:    "Count",
+			Request: *makePollRequest(t, pollId, &adminId),
+			Checker: srvt.CheckJSON{Body: CountInfoAnswer{
+				Result: []CountInfoEntry{
+					{Alternative: PollAlternative{Id: 1, Name: "Yes", Cost: 1}, Count: 3, Score: 3},
+					{Alternative: PollAlternative{Id: 0, Name: "No", Cost: 1}, Count: 0, Score: 0},
+				},
+				Delegated: 2,
+			}},
+		},
+	}
+	srvt.RunFunc(t, tests, CountInfoHandler)
+}
+
+func TestDelegateHandler_Eligibility(t *testing.T) {
+	precheck(t)
+
+	const (
+		qBlock  = `INSERT INTO Blocked (Poll, User) VALUE (?, ?)`
+		qInvite = `INSERT INTO Invitations (Salt, Poll, Email, User) VALUE (42, ?, ?, ?)`
+	)
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	delegatorId := env.CreateUserWith("Delegator")
+	blockedId := env.CreateUserWith("Blocked")
+	env.CreateUserWith("Stranger")
+	publicPollId := env.CreatePoll("Public", adminId, db.ElectorateAll)
+	env.QuietExec(qBlock, publicPollId, blockedId)
+	invitedPollId := env.CreatePoll("Invited", adminId, db.ElectorateInvited)
+	env.QuietExec(qInvite, invitedPollId, "delegator@example.com", delegatorId)
+	env.Must(t)
+
+	makeRequest := func(pollId uint32, delegate string) srvt.Request {
+		req := *makePollRequest(t, pollId, &delegatorId)
+		b, err := json.Marshal(DelegateQuery{Delegate: delegate})
+		mustt(t, err)
+		req.Body = string(b)
+		req.Method = "POST"
+		return req
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Blocked",
+			Request: makeRequest(publicPollId, dbt.UserNameWith("Blocked")),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		&srvt.T{
+			Name:    "Not invited",
+			Request: makeRequest(invitedPollId, dbt.UserNameWith("Stranger")),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		&srvt.T{
+			Name:    "Admin",
+			Request: makeRequest(invitedPollId, dbt.UserNameWith("Admin")),
+			Checker: srvt.CheckStatus{http.StatusOK},
+		},
+	}
+	srvt.Run(t, tests, DelegateHandler)
+}
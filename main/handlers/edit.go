@@ -0,0 +1,164 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/JBoudou/Itero/main/services"
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/salted"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/events"
+	"github.com/JBoudou/Itero/pkg/slog"
+)
+
+type editHandler struct {
+	evtManager events.Manager
+}
+
+// EditHandler modifies a poll. The query is a CreateQuery, subject to the same verifications as
+// for CreateHandler, and replacing the whole definition of the poll. Only the administrator of the
+// poll can edit it, and only while the poll is waiting, or is in its first round without any
+// participant. The short URL of the poll is kept if the query does not give any. Approved and
+// merged proposals stay linked to their alternative, found by name, and
+// become pending again if that alternative has been removed.
+func EditHandler(evtManager events.Manager) editHandler {
+	return editHandler{evtManager: evtManager}
+}
+
+func (self editHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
+	const (
+		ImpossibleStatus  = http.StatusLocked
+		ImpossibleMessage = "Not editable"
+	)
+
+	if request.User == nil || !request.User.Logged {
+		must(request.SessionError)
+		panic(server.UnauthorizedHttpError("Unlogged user"))
+	}
+	must(request.CheckPOST(ctx))
+	segment, err := salted.FromRequest(request)
+	must(err)
+
+	query := defaultCreateQuery()
+	must(request.UnmarshalJSONBody(&query))
+	def := checkCreateQuery(ctx, query, request.User.Id)
+
+	const (
+		qCheck = `
+		  SELECT 1 FROM Polls AS p
+		   WHERE p.Id = ? AND p.Salt = ? AND p.Admin = ?
+		     AND ( p.State = 'Waiting' OR (p.State = 'Active' AND p.CurrentRound = 0) )
+		     AND NOT EXISTS (SELECT 1 FROM Participants AS a WHERE a.Poll = p.Id)
+		     FOR UPDATE`
+		qUpdate = `
+		  UPDATE Polls
+		     SET Title = ?, Description = ?, State = ?, Start = ?, ShortURL = COALESCE(?, ShortURL),
+		         Electorate = ?, Hidden = ?, NbChoices = ?, ReportVote = ?, MinNbRounds = ?, MaxNbRounds = ?,
+		         Deadline = ?, MaxRoundDuration = ?, RoundThreshold = ?, Type = ?, Rule = ?,
+		         MaxOutcomeCost = ?, MaxBallotCost = ?, BallotCostIsCount = ?, Information = ?,
+		         InformationTop = ?, RoundType = ?, MinCellSize = ?, StopStableProfile = ?,
+		         StopStableWinner = ?, ThresholdOnWeight = ?, LockComments = ?,
+		         Proposals = ?
+		   WHERE Id = ?`
+		qDeleteAlternatives = `DELETE FROM Alternatives WHERE Poll = ?`
+		qDeleteGrades       = `DELETE FROM Grades WHERE Poll = ?`
+		qLinkedProposals    = `
+		  SELECT p.Id, a.Name
+		    FROM Proposals AS p, Alternatives AS a
+		   WHERE p.Poll = ? AND (a.Poll, a.Id) = (p.Poll, p.Alternative)`
+		qRelinkProposal = `
+		  UPDATE Proposals
+		     SET Alternative = (SELECT Id FROM Alternatives WHERE Poll = ? AND Name = ?)
+		   WHERE Id = ?`
+	)
+
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		rows, err := tx.QueryContext(ctx, qCheck, segment.Id, segment.Salt, request.User.Id)
+		must(err)
+		found := rows.Next()
+		must(rows.Close())
+		if !found {
+			panic(server.NewHttpError(ImpossibleStatus, ImpossibleMessage, ""))
+		}
+
+		_, err = tx.ExecContext(ctx, qUpdate,
+			def.Title,
+			def.Description,
+			def.state,
+			def.start,
+			def.shortURL,
+			def.electorate,
+			def.Hidden,
+			len(def.Alternatives),
+			def.ReportVote,
+			def.MinNbRounds,
+			def.MaxNbRounds,
+			def.Deadline,
+			db.DurationToTime(time.Duration(def.MaxRoundDuration)*time.Millisecond),
+			def.RoundThreshold,
+			def.pollType,
+			def.Rule.ToDB(),
+			def.MaxOutcomeCost,
+			def.MaxBallotCost,
+			def.BallotCostIsCount,
+			def.Information.ToDB(),
+			def.InformationTop,
+			def.RoundType.ToDB(),
+			def.MinCellSize,
+			def.StopStableProfile,
+			def.StopStableWinner,
+			def.ThresholdOnWeight,
+			def.LockComments,
+			def.Proposals,
+			segment.Id,
+		)
+		if err != nil {
+			panic(def.wrapSQLError(ctx, tx, err))
+		}
+
+		// Alternatives are renumbered, hence proposals are linked by name.
+		linked := make(map[uint32]string)
+		rows, err = tx.QueryContext(ctx, qLinkedProposals, segment.Id)
+		must(err)
+		for rows.Next() {
+			var id uint32
+			var name string
+			must(rows.Scan(&id, &name))
+			linked[id] = name
+		}
+		must(rows.Err())
+		must(rows.Close())
+
+		_, err = tx.ExecContext(ctx, qDeleteGrades, segment.Id)
+		must(err)
+		_, err = tx.ExecContext(ctx, qDeleteAlternatives, segment.Id)
+		must(err)
+		def.insertAlternatives(ctx, tx, segment.Id)
+		for id, name := range linked {
+			_, err = tx.ExecContext(ctx, qRelinkProposal, segment.Id, name, id)
+			must(err)
+		}
+	})
+
+	response.SendJSON(ctx, "Ok")
+	self.evtManager.Send(services.EditPollEvent{segment.Id})
+}
@@ -0,0 +1,239 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/server"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+type editChecker struct {
+	poll uint32
+}
+
+func (self editChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	var query CreateQuery
+	mustt(t, request.UnmarshalJSONBody(&query))
+
+	const (
+		qPoll         = `SELECT Title, NbChoices FROM Polls WHERE Id = ?`
+		qAlternatives = `SELECT Name FROM Alternatives WHERE Poll = ? ORDER BY Id`
+	)
+	var title string
+	var nbChoices int
+	mustt(t, db.DB.QueryRow(qPoll, self.poll).Scan(&title, &nbChoices))
+	if title != query.Title {
+		t.Errorf("Wrong title. Got %s. Expect %s.", title, query.Title)
+	}
+	if nbChoices != len(query.Alternatives) {
+		t.Errorf("Wrong NbChoices. Got %d. Expect %d.", nbChoices, len(query.Alternatives))
+	}
+
+	rows, err := db.DB.Query(qAlternatives, self.poll)
+	mustt(t, err)
+	defer rows.Close()
+	var got, expect []string
+	for rows.Next() {
+		var name string
+		mustt(t, rows.Scan(&name))
+		got = append(got, name)
+	}
+	for _, alt := range query.Alternatives {
+		expect = append(expect, alt.Name)
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Wrong alternatives. Got %v. Expect %v.", got, expect)
+	}
+}
+
+func TestEditHandler(t *testing.T) {
+	precheck(t)
+
+	const qTerminate = `UPDATE Polls SET State = 'Terminated' WHERE Id = ?`
+
+	var env dbt.Env
+	defer env.Close()
+	userId := env.CreateUserWith("Admin")
+	otherId := env.CreateUserWith("Other")
+	pollId := env.CreatePoll("Edit", userId, db.ElectorateLogged)
+	votedPollId := env.CreatePoll("Voted", userId, db.ElectorateLogged)
+	env.Vote(votedPollId, 0, otherId, 1)
+	terminatedPollId := env.CreatePoll("Terminated", userId, db.ElectorateLogged)
+	env.QuietExec(qTerminate, terminatedPollId)
+	env.Must(t)
+
+	makeRequest := func(pollId uint32, user *uint32, alternatives ...string) srvt.Request {
+		query := CreateQuery{Title: "Edited poll", MaxNbRounds: 4}
+		for _, name := range alternatives {
+			query.Alternatives = append(query.Alternatives, SimpleAlternative{Name: name})
+		}
+		req := *makePollRequest(t, pollId, user)
+		b, err := json.Marshal(query)
+		mustt(t, err)
+		req.Body = string(b)
+		req.Method = "POST"
+		return req
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Edit",
+			Request: makeRequest(pollId, &userId, "A", "B", "C"),
+			Checker: editChecker{poll: pollId},
+		},
+		&srvt.T{
+			Name:    "Edit again",
+			Request: makeRequest(pollId, &userId, "D", "E"),
+			Checker: editChecker{poll: pollId},
+		},
+		&srvt.T{
+			Name:    "Too few alternatives",
+			Request: makeRequest(pollId, &userId, "A"),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		&srvt.T{
+			Name:    "Not admin",
+			Request: makeRequest(pollId, &otherId, "A", "B"),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Not editable"},
+		},
+		&srvt.T{
+			Name:    "With ballots",
+			Request: makeRequest(votedPollId, &userId, "A", "B"),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Not editable"},
+		},
+		&srvt.T{
+			Name:    "Terminated",
+			Request: makeRequest(terminatedPollId, &userId, "A", "B"),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Not editable"},
+		},
+		&srvt.T{
+			Name:    "No user",
+			Request: makeRequest(pollId, nil, "A", "B"),
+			Checker: srvt.CheckStatus{http.StatusUnauthorized},
+		},
+	}
+	srvt.Run(t, tests, EditHandler)
+}
+
+func TestEditHandler_ShortURL(t *testing.T) {
+	precheck(t)
+
+	const (
+		qShortURL = `UPDATE Polls SET ShortURL = ? WHERE Id = ?`
+		qGet      = `SELECT ShortURL FROM Polls WHERE Id = ?`
+		shortURL  = "TestEditHandler_ShortURL"
+	)
+
+	var env dbt.Env
+	defer env.Close()
+	userId := env.CreateUserWith("Admin")
+	pollId := env.CreatePoll("Edit", userId, db.ElectorateLogged)
+	env.QuietExec(qShortURL, shortURL, pollId)
+	env.Must(t)
+
+	query := CreateQuery{
+		Title:        "Edited poll",
+		MaxNbRounds:  4,
+		Alternatives: []SimpleAlternative{{Name: "A"}, {Name: "B"}},
+	}
+	req := *makePollRequest(t, pollId, &userId)
+	b, err := json.Marshal(query)
+	mustt(t, err)
+	req.Body = string(b)
+	req.Method = "POST"
+	srvt.Run(t, []srvt.Test{&srvt.T{Name: "Edit", Request: req, Checker: editChecker{poll: pollId}}},
+		EditHandler)
+
+	var got sql.NullString
+	mustt(t, db.DB.QueryRow(qGet, pollId).Scan(&got))
+	if !got.Valid || got.String != shortURL {
+		t.Errorf("Wrong short URL. Got %v. Expect %s.", got, shortURL)
+	}
+}
+
+func TestEditHandler_Proposals(t *testing.T) {
+	precheck(t)
+
+	const (
+		qWaiting  = `UPDATE Polls SET State = 'Waiting', Start = ?, Proposals = TRUE WHERE Id = ?`
+		qProposal = `INSERT INTO Proposals (Poll, User, Name, Alternative) VALUE (?, ?, ?, ?)`
+		qLinked   = `SELECT Alternative FROM Proposals WHERE Id = ?`
+	)
+
+	var env dbt.Env
+	defer env.Close()
+	userId := env.CreateUserWith("Admin")
+	pollId := env.CreatePoll("Edit", userId, db.ElectorateLogged)
+	start := time.Now().Add(time.Hour)
+	env.QuietExec(qWaiting, start, pollId)
+	env.Must(t)
+
+	propose := func(name string, alternative sql.NullInt32) uint32 {
+		result, err := db.DB.Exec(qProposal, pollId, userId, name, alternative)
+		mustt(t, err)
+		id, err := db.IdFromResult(result)
+		mustt(t, err)
+		return id
+	}
+	// Alternatives are No (0) and Yes (1).
+	kept := propose("Yeah", sql.NullInt32{Int32: 1, Valid: true})
+	removed := propose("Nope", sql.NullInt32{Int32: 0, Valid: true})
+	pending := propose("Later", sql.NullInt32{})
+
+	query := CreateQuery{
+		Title:        "Edited poll",
+		MaxNbRounds:  4,
+		Start:        start,
+		Proposals:    true,
+		Alternatives: []SimpleAlternative{{Name: "Yes"}, {Name: "Maybe"}},
+	}
+	req := *makePollRequest(t, pollId, &userId)
+	b, err := json.Marshal(query)
+	mustt(t, err)
+	req.Body = string(b)
+	req.Method = "POST"
+	srvt.Run(t, []srvt.Test{&srvt.T{Name: "Edit", Request: req, Checker: editChecker{poll: pollId}}},
+		EditHandler)
+
+	tests := []struct {
+		name     string
+		proposal uint32
+		expect   sql.NullInt32
+	}{
+		{name: "Kept", proposal: kept, expect: sql.NullInt32{Int32: 0, Valid: true}},
+		{name: "Removed", proposal: removed},
+		{name: "Pending", proposal: pending},
+	}
+	for _, tt := range tests {
+		var got sql.NullInt32
+		mustt(t, db.DB.QueryRow(qLinked, tt.proposal).Scan(&got))
+		if got != tt.expect {
+			t.Errorf("%s: wrong alternative. Got %v. Expect %v.", tt.name, got, tt.expect)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/JBoudou/Itero/main/services"
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/slog"
+)
+
+// EmailPrefs tells which notifications the user wants to receive by email, and how often the user
+// wants to receive digests. It is both the answer of EmailPrefsHandler and the query of
+// SetEmailPrefsHandler.
+type EmailPrefs struct {
+	Start  bool
+	Next   bool
+	Term   bool
+	Delete bool
+	Digest services.DigestPeriod
+}
+
+func (self *EmailPrefs) field(action services.PollNotifAction) *bool {
+	switch action {
+	case services.PollNotifStart:
+		return &self.Start
+	case services.PollNotifNext:
+		return &self.Next
+	case services.PollNotifTerm:
+		return &self.Term
+	case services.PollNotifDelete:
+		return &self.Delete
+	}
+	return nil
+}
+
+// EmailPrefsHandler sends the email preferences of the logged user.
+func EmailPrefsHandler(ctx context.Context, response server.Response, request *server.Request) {
+	if request.User == nil || !request.User.Logged {
+		must(request.SessionError)
+		panic(server.UnauthorizedHttpError("Unlogged user"))
+	}
+
+	const (
+		qSelect = `SELECT Action FROM EmailPreferences WHERE User = ?`
+		qDigest = `SELECT Period FROM Digests WHERE User = ?`
+	)
+	rows, err := db.DB.QueryContext(ctx, qSelect, request.User.Id)
+	must(err)
+	defer rows.Close()
+
+	var answer EmailPrefs
+	for rows.Next() {
+		var action services.PollNotifAction
+		must(rows.Scan(&action))
+		if field := answer.field(action); field != nil {
+			*field = true
+		}
+	}
+	must(rows.Err())
+
+	err = db.DB.QueryRowContext(ctx, qDigest, request.User.Id).Scan(&answer.Digest)
+	if err != sql.ErrNoRows {
+		must(err)
+	}
+
+	response.SendJSON(ctx, answer)
+}
+
+// SetEmailPrefsHandler replaces the email preferences of the logged user. The query is an
+// EmailPrefs.
+func SetEmailPrefsHandler(ctx context.Context, response server.Response, request *server.Request) {
+	if request.User == nil || !request.User.Logged {
+		must(request.SessionError)
+		panic(server.UnauthorizedHttpError("Unlogged user"))
+	}
+	must(request.CheckPOST(ctx))
+
+	var query EmailPrefs
+	must(request.UnmarshalJSONBody(&query))
+	if query.Digest > services.DigestWeekly {
+		panic(server.NewHttpError(http.StatusBadRequest, "Protocol error", "Wrong digest period"))
+	}
+
+	const (
+		qDelete       = `DELETE FROM EmailPreferences WHERE User = ?`
+		qInsert       = `INSERT INTO EmailPreferences (User, Action) VALUE (?, ?)`
+		qDeleteDigest = `DELETE FROM Digests WHERE User = ?`
+		qDigest       = `
+		  INSERT INTO Digests (User, Period, NextSend) VALUE (?, ?, ?)
+		      ON DUPLICATE KEY UPDATE NextSend = IF(Period = VALUES(Period), NextSend, VALUES(NextSend)),
+		                              Period = VALUES(Period)`
+	)
+	now := time.Now()
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		_, err := tx.ExecContext(ctx, qDelete, request.User.Id)
+		must(err)
+		for _, action := range services.PollEmailActions {
+			if *query.field(action) {
+				_, err = tx.ExecContext(ctx, qInsert, request.User.Id, action)
+				must(err)
+			}
+		}
+		if query.Digest == services.DigestNone {
+			_, err = tx.ExecContext(ctx, qDeleteDigest, request.User.Id)
+		} else {
+			_, err = tx.ExecContext(ctx, qDigest, request.User.Id, query.Digest,
+				query.Digest.Next(now, now))
+		}
+		must(err)
+	})
+
+	response.SendJSON(ctx, "Ok")
+}
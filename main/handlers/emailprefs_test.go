@@ -0,0 +1,75 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/JBoudou/Itero/main/services"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+func TestEmailPrefsHandlers(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	userId := env.CreateUserWith("EmailPrefs")
+	env.Must(t)
+
+	getTest := func(name string, expect EmailPrefs) srvt.Test {
+		return &srvt.T{
+			Name:    name,
+			Request: srvt.Request{UserId: &userId},
+			Checker: srvt.CheckJSON{Body: expect},
+		}
+	}
+	setTest := func(name string, body string) srvt.Test {
+		return &srvt.T{
+			Name:    name,
+			Request: srvt.Request{Method: "POST", UserId: &userId, Body: body},
+			Checker: srvt.CheckStatus{http.StatusOK},
+		}
+	}
+
+	srvt.RunFunc(t, []srvt.Test{
+		&srvt.T{
+			Name:    "Unlogged",
+			Checker: srvt.CheckStatus{http.StatusUnauthorized},
+		},
+		getTest("Default", EmailPrefs{}),
+	}, EmailPrefsHandler)
+	srvt.RunFunc(t, []srvt.Test{
+		&srvt.T{
+			Name:    "Unlogged",
+			Request: srvt.Request{Method: "POST", Body: `{"Start":true}`},
+			Checker: srvt.CheckStatus{http.StatusUnauthorized},
+		},
+		&srvt.T{
+			Name:    "Wrong digest",
+			Request: srvt.Request{Method: "POST", UserId: &userId, Body: `{"Digest":3}`},
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		setTest("Start, Term and Daily", `{"Start":true,"Term":true,"Digest":1}`),
+	}, SetEmailPrefsHandler)
+	srvt.RunFunc(t, []srvt.Test{getTest("After set",
+		EmailPrefs{Start: true, Term: true, Digest: services.DigestDaily})}, EmailPrefsHandler)
+	srvt.RunFunc(t, []srvt.Test{setTest("Next", `{"Next":true}`)}, SetEmailPrefsHandler)
+	srvt.RunFunc(t, []srvt.Test{getTest("After replace", EmailPrefs{Next: true})}, EmailPrefsHandler)
+}
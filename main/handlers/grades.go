@@ -0,0 +1,85 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+)
+
+// GradesInfoEntry is the distribution of grades of an alternative.
+// Distribution[g] is the total weight of the ballots giving grade g to the alternative. Since
+// ballots usually have weight 1, it is usually the number of participants giving that grade.
+type GradesInfoEntry struct {
+	Alternative  PollAlternative
+	Distribution []float64
+	Score        float64
+}
+
+// GradesInfoAnswer is the response sent by GradesInfoHandler.
+// Grades are sorted from the worst to the best one. Result is sorted from the best alternative to
+// the worst one, according to the rule of the poll.
+type GradesInfoAnswer struct {
+	Grades []string
+	Result []GradesInfoEntry
+}
+
+// GradesInfoHandler sends the distribution of grades and the outcome of a previous round of a
+// grading poll. The rule is the one of the poll if it is a grading rule, and majority judgment
+// otherwise. The result is sent only if the poll gives full counts.
+func GradesInfoHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+	if pollInfo.Type != db.PollTypeGrading {
+		err = server.NewHttpError(http.StatusBadRequest, "Wrong poll", "Not a grading poll")
+		response.SendError(ctx, err)
+		return
+	}
+	if err = pollInfo.checkInformation(db.InformationCounts); err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+
+	// Get the round to return results of.
+	round, err := getInfoRound(request, pollInfo)
+	if err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+
+	profile, err := db.LoadGradeProfile(ctx, pollInfo.Id, round)
+	must(err)
+	distribution := profile.Distribution()
+	outcome := db.GradeRuleFromDB(pollInfo.Rule).Outcome(profile)
+
+	var alternatives []PollAlternative
+	allAlternatives(ctx, pollInfo, &alternatives)
+	answer := GradesInfoAnswer{Result: make([]GradesInfoEntry, len(outcome))}
+	allGrades(ctx, pollInfo, &answer.Grades)
+	for i, result := range outcome {
+		answer.Result[i] = GradesInfoEntry{
+			Alternative:  alternatives[result.Alternative],
+			Distribution: distribution[result.Alternative],
+			Score:        result.Score,
+		}
+	}
+
+	response.SendJSON(ctx, answer)
+}
@@ -0,0 +1,81 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+func TestGradesInfoHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	var users [5]uint32
+	for i := range users {
+		users[i] = env.CreateUserWith(strconv.FormatInt(int64(i), 10))
+	}
+	grades := []string{"Bad", "Fair", "Good"}
+	pollId := createGradingPoll(&env, users[0], []string{"A", "B"}, grades)
+	gradeVote(&env, pollId, 0, users[0], 2, 1)
+	gradeVote(&env, pollId, 0, users[1], 2, 1)
+	gradeVote(&env, pollId, 0, users[2], 1, 1)
+	gradeVote(&env, pollId, 0, users[3], 1, 2)
+	gradeVote(&env, pollId, 0, users[4])
+	uniPollId := env.CreatePoll("Uninominal", users[0], db.ElectorateAll)
+	env.Must(t)
+
+	alternatives := []PollAlternative{
+		{Id: 0, Name: "A", Cost: 1.},
+		{Id: 1, Name: "B", Cost: 1.},
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Round zero",
+			Request: *makePollRequest(t, pollId, &users[0]),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		&srvt.T{
+			Name:    "Not grading",
+			Request: *makePollRequest(t, uniPollId, &users[0]),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Wrong poll"},
+		},
+		&srvt.T{
+			Name: "Majority judgment",
+			Update: func(t *testing.T) {
+				env.NextRound(pollId)
+				env.Must(t)
+			},
+			Request: *makePollRequest(t, pollId, &users[0]),
+			Checker: srvt.CheckJSON{Body: GradesInfoAnswer{
+				Grades: grades,
+				Result: []GradesInfoEntry{
+					{Alternative: alternatives[0], Distribution: []float64{0, 2, 2}, Score: 1.25},
+					{Alternative: alternatives[1], Distribution: []float64{0, 3, 1}, Score: 1.125},
+				},
+			}},
+		},
+	}
+	srvt.RunFunc(t, tests, GradesInfoHandler)
+}
@@ -0,0 +1,143 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/mid/unlogged"
+)
+
+// GradeList is a grading ballot. The grade of alternative i is at position i.
+// Like AlternativeSet, it is represented in JSON by an array of numbers.
+type GradeList []uint8
+
+// MarshalJSON implements json.Marshaler.
+func (self GradeList) MarshalJSON() ([]byte, error) {
+	return AlternativeSet(self).MarshalJSON()
+}
+
+// allGrades retrieves the names of the grades of a poll, from the worst to the best one.
+// Errors are sent by panic.
+func allGrades(ctx context.Context, poll PollInfo, out *[]string) {
+	const qSelect = `SELECT Name FROM Grades WHERE Poll = ? ORDER BY Id ASC`
+	rows, err := db.DB.QueryContext(ctx, qSelect, poll.Id)
+	must(err)
+	defer rows.Close()
+	*out = []string{}
+	for rows.Next() {
+		var name string
+		must(rows.Scan(&name))
+		*out = append(*out, name)
+	}
+	must(rows.Err())
+}
+
+// gradeBallots contains the previous and the current grading ballots of a user.
+// Fields are represented in JSON as for listBallots.
+type gradeBallots struct {
+	Previous        GradeList `json:",omitempty"`
+	PreviousIsBlank bool      `json:",omitempty"`
+	Current         GradeList `json:",omitempty"`
+	CurrentIsBlank  bool      `json:",omitempty"`
+}
+
+// getGradeBallots retrieves the grading ballots of the user for the current and the previous
+// rounds. Errors are sent by panic.
+func getGradeBallots(ctx context.Context, pollInfo PollInfo, user uint32) (ret gradeBallots) {
+	const qGetBallots = `
+		SELECT p.Round, b.Alternative, b.Grade
+		  FROM Participants AS p
+			LEFT OUTER JOIN GradeBallots AS b ON (p.User, p.Poll, p.Round) = (b.User, b.Poll, b.Round)
+		 WHERE p.User = ? AND p.Poll = ? AND p.Round IN (?, ?)
+		 ORDER BY p.Round, b.Alternative`
+
+	var previousRound uint8
+	if pollInfo.CurrentRound > 0 {
+		// Round is unsigned
+		previousRound = pollInfo.CurrentRound - 1
+	}
+	rows, err := db.DB.QueryContext(ctx, qGetBallots, user, pollInfo.Id, previousRound,
+		pollInfo.CurrentRound)
+	must(err)
+	defer rows.Close()
+	for rows.Next() {
+		var round uint8
+		var alternative, grade sql.NullInt32
+		must(rows.Scan(&round, &alternative, &grade))
+		setBallot := func(field *GradeList, blank *bool) {
+			if *blank {
+				must(errors.New("Duplicated ballot"))
+			}
+			if !alternative.Valid {
+				if *field != nil {
+					must(errors.New("Duplicated ballot"))
+				}
+				*blank = true
+				return
+			}
+			if *field == nil {
+				*field = make(GradeList, pollInfo.NbChoices)
+			}
+			if int(alternative.Int32) >= len(*field) {
+				must(errors.New("Unknown alternative"))
+			}
+			(*field)[alternative.Int32] = uint8(grade.Int32)
+		}
+		switch round {
+		case pollInfo.CurrentRound:
+			setBallot(&ret.Current, &ret.CurrentIsBlank)
+		case previousRound:
+			setBallot(&ret.Previous, &ret.PreviousIsBlank)
+		default:
+			must(errors.New("Impossible round"))
+		}
+	}
+	must(rows.Err())
+	return
+}
+
+// GradingBallotAnswer represents the response sent by GradingBallotHandler.
+// Grades are sorted from the worst to the best one.
+type GradingBallotAnswer struct {
+	gradeBallots
+	Grades       []string
+	Alternatives []PollAlternative
+}
+
+// GradingBallotHandler sends the previous ballot (if any), the current one (if any), the grades and
+// all the alternatives.
+func GradingBallotHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+
+	if request.User == nil {
+		var user server.User
+		user, err = unlogged.FromAddr(ctx, request.RemoteAddr())
+		must(err)
+		request.User = &user
+	}
+
+	answer := GradingBallotAnswer{gradeBallots: getGradeBallots(ctx, pollInfo, request.User.Id)}
+	allGrades(ctx, pollInfo, &answer.Grades)
+	allAlternatives(ctx, pollInfo, &answer.Alternatives)
+	response.SendJSON(ctx, answer)
+}
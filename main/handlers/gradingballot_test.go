@@ -0,0 +1,119 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+// createGradingPoll creates a grading poll with the given alternatives and grades.
+func createGradingPoll(env *dbt.Env, admin uint32, alternatives, grades []string) uint32 {
+	const (
+		qType  = `UPDATE Polls SET Type = ?, Rule = ? WHERE Id = ?`
+		qGrade = `INSERT INTO Grades (Poll, Id, Name) VALUE (?, ?, ?)`
+	)
+	pollId := env.CreatePollWith("Grading", admin, db.ElectorateAll, alternatives)
+	env.QuietExec(qType, db.PollTypeGrading, db.PollRuleMajorityJudgment, pollId)
+	for i, grade := range grades {
+		env.QuietExec(qGrade, pollId, i, grade)
+	}
+	return pollId
+}
+
+// gradeVote inserts a grading ballot.
+func gradeVote(env *dbt.Env, pollId uint32, round uint8, userId uint32, grades ...uint8) {
+	const (
+		qParticipate = `INSERT IGNORE INTO Participants (User, Poll, Round) VALUE (?, ?, ?)`
+		qVote        = `
+			INSERT INTO GradeBallots (User, Poll, Alternative, Round, Grade) VALUE (?, ?, ?, ?, ?)`
+	)
+	env.QuietExec(qParticipate, userId, pollId, round)
+	for alt, grade := range grades {
+		env.QuietExec(qVote, userId, pollId, alt, round, grade)
+	}
+}
+
+func TestGradeList_JSON(t *testing.T) {
+	got, err := json.Marshal(GradeList{0, 3, 1})
+	mustt(t, err)
+	if string(got) != `[0,3,1]` {
+		t.Errorf("Got %s. Expect [0,3,1].", got)
+	}
+}
+
+func TestGradingBallotHandler(t *testing.T) {
+	precheck(t)
+
+	env := new(dbt.Env)
+	defer env.Close()
+
+	userId := env.CreateUser()
+	pollId := createGradingPoll(env, userId, []string{"A", "B"}, []string{"Bad", "Fair", "Good"})
+	env.Must(t)
+
+	request := *makePollRequest(t, pollId, &userId)
+
+	alternatives := []PollAlternative{
+		{Id: 0, Name: "A", Cost: 1.},
+		{Id: 1, Name: "B", Cost: 1.},
+	}
+	answer := func(previous, current GradeList, previousBlank, currentBlank bool) *GradingBallotAnswer {
+		return &GradingBallotAnswer{
+			gradeBallots: gradeBallots{
+				Previous:        previous,
+				PreviousIsBlank: previousBlank,
+				Current:         current,
+				CurrentIsBlank:  currentBlank,
+			},
+			Grades:       []string{"Bad", "Fair", "Good"},
+			Alternatives: alternatives,
+		}
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "No Ballot",
+			Request: request,
+			Checker: srvt.CheckJSON{Body: answer(nil, nil, false, false)},
+		},
+		&srvt.T{
+			Name: "Current ballot",
+			Update: func(t *testing.T) {
+				gradeVote(env, pollId, 0, userId, 2, 0)
+				env.Must(t)
+			},
+			Request: request,
+			Checker: srvt.CheckJSON{Body: answer(nil, GradeList{2, 0}, false, false)},
+		},
+		&srvt.T{
+			Name: "Blank current",
+			Update: func(t *testing.T) {
+				env.NextRound(pollId)
+				gradeVote(env, pollId, 1, userId)
+				env.Must(t)
+			},
+			Request: request,
+			Checker: srvt.CheckJSON{Body: answer(GradeList{2, 0}, nil, false, true)},
+		},
+	}
+	srvt.RunFunc(t, tests, GradingBallotHandler)
+}
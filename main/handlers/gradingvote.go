@@ -0,0 +1,106 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/JBoudou/Itero/main/services"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/events"
+)
+
+// GradingVoteQuery is the body of requests sent to GradingVoteHandler.
+// The grade of alternative i is Grades[i]. An empty list of grades is a blank vote.
+type GradingVoteQuery struct {
+	Grades GradeList
+	Round  uint8
+}
+
+// checkGradingBallot verifies that ballot is a valid grading ballot for a poll having nbGrades
+// grades. The ballot must be either empty or contain exactly one grade for each alternative.
+func checkGradingBallot(poll PollInfo, ballot GradeList, nbGrades int) error {
+	if len(ballot) == 0 {
+		return nil
+	}
+	if len(ballot) != int(poll.NbChoices) {
+		return server.NewHttpError(http.StatusBadRequest, "Invalid ballot", "Wrong number of grades")
+	}
+	for _, grade := range ballot {
+		if int(grade) >= nbGrades {
+			return server.NewHttpError(http.StatusBadRequest, "Invalid ballot", "Unknown grade")
+		}
+	}
+	return nil
+}
+
+type gradingVoteHandler struct {
+	evtManager events.Manager
+}
+
+// GradingVoteHandler votes by giving a grade to each alternative. Blank votes are also permitted.
+func GradingVoteHandler(evtManager events.Manager) gradingVoteHandler {
+	return gradingVoteHandler{evtManager: evtManager}
+}
+
+func (self gradingVoteHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, sendUnloggedCookie := checkVoteRequest(ctx, request, BallotTypeGrading)
+
+	// Get query
+	var voteQuery GradingVoteQuery
+	if err := request.UnmarshalJSONBody(&voteQuery); err != nil {
+		err = server.WrapError(http.StatusBadRequest, "Wrong request", err)
+		response.SendError(ctx, err)
+		return
+	}
+	if err := checkVoteRound(pollInfo, voteQuery.Round); err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+	var grades []string
+	allGrades(ctx, pollInfo, &grades)
+	if err := checkGradingBallot(pollInfo, voteQuery.Grades, len(grades)); err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+
+	const qInsertBallot = `
+		INSERT INTO GradeBallots (User, Poll, Alternative, Round, Grade) VALUE (?, ?, ?, ?, ?)`
+
+	var insert func(tx *sql.Tx)
+	if len(voteQuery.Grades) > 0 {
+		insert = func(tx *sql.Tx) {
+			stmt, err := tx.PrepareContext(ctx, qInsertBallot)
+			must(err)
+			defer stmt.Close()
+			for alt, grade := range voteQuery.Grades {
+				_, err = stmt.ExecContext(ctx, request.User.Id, pollInfo.Id, alt, pollInfo.CurrentRound,
+					grade)
+				must(err)
+			}
+		}
+	}
+	replaceBallot(ctx, pollInfo, request.User.Id, insert)
+
+	if sendUnloggedCookie {
+		response.SendUnloggedId(ctx, *request.User, request)
+	}
+	response.SendJSON(ctx, "Ok")
+	self.evtManager.Send(services.VoteEvent{pollInfo.Id})
+}
@@ -0,0 +1,152 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/server"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+func TestCheckGradingBallot(t *testing.T) {
+	poll := PollInfo{NbChoices: 3}
+	tests := []struct {
+		name   string
+		ballot GradeList
+		ok     bool
+	}{
+		{name: "Blank", ballot: GradeList{}, ok: true},
+		{name: "Ok", ballot: GradeList{0, 2, 1}, ok: true},
+		{name: "Too few grades", ballot: GradeList{0, 2}},
+		{name: "Too many grades", ballot: GradeList{0, 2, 1, 1}},
+		{name: "Unknown grade", ballot: GradeList{0, 3, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkGradingBallot(poll, tt.ballot, 3)
+			if tt.ok {
+				if err != nil {
+					t.Errorf("Unexpected error %v.", err)
+				}
+				return
+			}
+			httpError, ok := err.(server.HttpError)
+			if !ok {
+				t.Fatalf("Expect an HttpError. Got %v.", err)
+			}
+			if httpError.Code != http.StatusBadRequest {
+				t.Errorf("Wrong code. Got %d. Expect %d.", httpError.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+type gradingVoteChecker struct {
+	poll uint32
+	user uint32
+}
+
+func (self *gradingVoteChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	var query GradingVoteQuery
+	mustt(t, request.UnmarshalJSONBody(&query))
+
+	const qCheck = `
+		SELECT b.Grade
+		  FROM Participants AS p LEFT OUTER JOIN GradeBallots AS b
+			  ON (p.Poll, p.User, p.Round) = (b.Poll, b.User, b.Round)
+		 WHERE p.Poll = ? AND p.User = ? AND p.Round = 0 AND b.Grade IS NOT NULL
+		 ORDER BY b.Alternative ASC`
+
+	rows, err := db.DB.Query(qCheck, self.poll, self.user)
+	mustt(t, err)
+	defer rows.Close()
+	got := GradeList{}
+	for rows.Next() {
+		var grade uint8
+		mustt(t, rows.Scan(&grade))
+		got = append(got, grade)
+	}
+	expect := query.Grades
+	if expect == nil {
+		expect = GradeList{}
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got %v. Expect %v.", got, expect)
+	}
+}
+
+func TestGradingVoteHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	userId := env.CreateUser()
+	pollId := createGradingPoll(&env, userId, []string{"A", "B"}, []string{"Bad", "Fair", "Good"})
+	uniPollId := env.CreatePoll("Uninominal", userId, db.ElectorateLogged)
+	env.Must(t)
+
+	makeRequest := func(pollId uint32, vote GradingVoteQuery) srvt.Request {
+		req := *makePollRequest(t, pollId, &userId)
+		b, err := json.Marshal(vote)
+		mustt(t, err)
+		req.Body = string(b)
+		req.Method = "POST"
+		return req
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "First vote",
+			Request: makeRequest(pollId, GradingVoteQuery{Grades: GradeList{2, 0}}),
+			Checker: &gradingVoteChecker{poll: pollId, user: userId},
+		},
+		&srvt.T{
+			Name:    "Change vote",
+			Request: makeRequest(pollId, GradingVoteQuery{Grades: GradeList{1, 1}}),
+			Checker: &gradingVoteChecker{poll: pollId, user: userId},
+		},
+		&srvt.T{
+			Name:    "Blank vote",
+			Request: makeRequest(pollId, GradingVoteQuery{}),
+			Checker: &gradingVoteChecker{poll: pollId, user: userId},
+		},
+		&srvt.T{
+			Name:    "Unknown grade",
+			Request: makeRequest(pollId, GradingVoteQuery{Grades: GradeList{3, 0}}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Invalid ballot"},
+		},
+		&srvt.T{
+			Name:    "Missing grade",
+			Request: makeRequest(pollId, GradingVoteQuery{Grades: GradeList{1}}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Invalid ballot"},
+		},
+		&srvt.T{
+			Name:    "Uninominal poll",
+			Request: makeRequest(uniPollId, GradingVoteQuery{Grades: GradeList{0}}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Wrong poll"},
+		},
+	}
+	srvt.Run(t, tests, GradingVoteHandler)
+}
@@ -18,6 +18,7 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/JBoudou/Itero/mid/db"
@@ -55,6 +56,16 @@ func makePollRequest(t *testing.T, pollId uint32, userId *uint32) *srvt.Request
 	return &srvt.Request{Target: &target, UserId: userId}
 }
 
+// makePollPOSTRequest returns a POST request for the poll, with query encoded in JSON as body.
+func makePollPOSTRequest(t *testing.T, pollId uint32, userId *uint32, query interface{}) srvt.Request {
+	req := *makePollRequest(t, pollId, userId)
+	b, err := json.Marshal(query)
+	mustt(t, err)
+	req.Body = string(b)
+	req.Method = "POST"
+	return req
+}
+
 //
 // WithUser //
 //
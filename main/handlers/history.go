@@ -0,0 +1,90 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+)
+
+// HistoryInfoRound contains the result of a completed round, as sent by HistoryInfoHandler.
+// Turnout is the total weight of the ballots taken into account for the round, including blank
+// ballots. When votes are reported, it includes the ballots reported from previous rounds. Ballots
+// cast through delegation are included in Turnout and Blank, and counted in Delegated.
+type HistoryInfoRound struct {
+	Round     uint8
+	Turnout   uint32
+	Blank     uint32
+	Delegated uint32
+	Result    []CountInfoEntry
+}
+
+// HistoryInfoAnswer is the response sent by HistoryInfoHandler.
+// Rounds are sorted from the first one to the last completed one.
+type HistoryInfoAnswer struct {
+	Rounds []HistoryInfoRound
+}
+
+// turnout returns the total weight of the ballots in the profile and the weight of the blank ones.
+func (self roundProfile) turnout() (turnout, blank uint32) {
+	if self.grades != nil {
+		for _, ballot := range self.grades.Ballots {
+			turnout += uint32(ballot.Weight)
+			if len(ballot.Grades) == 0 {
+				blank += uint32(ballot.Weight)
+			}
+		}
+		return
+	}
+	for _, ballot := range self.ranks.Ballots {
+		turnout += uint32(ballot.Weight)
+		if len(ballot.Ranks) == 0 {
+			blank += uint32(ballot.Weight)
+		}
+	}
+	return
+}
+
+// HistoryInfoHandler sends the results of all completed rounds of a poll.
+// Each round is described as by CountInfoHandler, with its turnout and number of blank ballots.
+// Since completed rounds never change, the response can be cached until the next round completes.
+// The history is sent only if the poll gives full counts.
+func HistoryInfoHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+	if err = pollInfo.checkInformation(db.InformationCounts); err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+
+	answer := HistoryInfoAnswer{Rounds: make([]HistoryInfoRound, pollInfo.CurrentRound)}
+	for round := uint8(0); round < pollInfo.CurrentRound; round++ {
+		entry := &answer.Rounds[round]
+		entry.Round = round
+		profile := loadRoundProfile(ctx, pollInfo, round)
+		entry.Turnout, entry.Blank = profile.turnout()
+		entry.Delegated = profile.delegated
+		entry.Result = countEntries(ctx, pollInfo, profile)
+	}
+
+	etag := strconv.FormatUint(uint64(pollInfo.Id), 10) + "-" +
+		strconv.FormatUint(uint64(pollInfo.CurrentRound), 10)
+	response.SendCacheableJSON(ctx, request, etag, answer)
+}
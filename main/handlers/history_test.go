@@ -0,0 +1,106 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+func TestHistoryInfoHandler(t *testing.T) {
+	precheck(t)
+
+	const (
+		qParticipate = `INSERT INTO Participants (Poll, User, Round) VALUE (?, ?, ?)`
+		qReport      = `UPDATE Polls SET ReportVote = TRUE WHERE Id = ?`
+	)
+
+	var env dbt.Env
+	defer env.Close()
+	create, userId := createInformationPolls(&env)
+	countsPoll := create(db.InformationCounts, 3)
+	reportPoll := create(db.InformationCounts, 3)
+	rankingPoll := create(db.InformationRanking, 3)
+	env.QuietExec(qReport, reportPoll)
+	env.Must(t)
+
+	users := informationPollUsers(t, countsPoll)
+	env.Vote(countsPoll, 1, users[0], 2)
+	env.Vote(countsPoll, 1, users[1], 2)
+	env.QuietExec(qParticipate, countsPoll, users[2], 1)
+	env.NextRound(countsPoll)
+	env.Vote(reportPoll, 1, users[0], 2)
+	env.NextRound(reportPoll)
+	env.Must(t)
+
+	alts := informationTestAlternatives
+	firstRound := HistoryInfoRound{
+		Round:   0,
+		Turnout: 3,
+		Result: []CountInfoEntry{
+			{Alternative: alts[1], Count: 2, Score: 2},
+			{Alternative: alts[2], Count: 1, Score: 1},
+			{Alternative: alts[0], Count: 0, Score: 0},
+		},
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Counts",
+			Request: *makePollRequest(t, countsPoll, &userId),
+			Checker: srvt.CheckJSON{Body: HistoryInfoAnswer{Rounds: []HistoryInfoRound{
+				firstRound,
+				{
+					Round:   1,
+					Turnout: 3,
+					Blank:   1,
+					Result: []CountInfoEntry{
+						{Alternative: alts[2], Count: 2, Score: 2},
+						{Alternative: alts[0], Count: 0, Score: 0},
+						{Alternative: alts[1], Count: 0, Score: 0},
+					},
+				},
+			}}},
+		},
+		&srvt.T{
+			Name:    "Report",
+			Request: *makePollRequest(t, reportPoll, &userId),
+			Checker: srvt.CheckJSON{Body: HistoryInfoAnswer{Rounds: []HistoryInfoRound{
+				firstRound,
+				{
+					Round:   1,
+					Turnout: 3,
+					Result: []CountInfoEntry{
+						{Alternative: alts[2], Count: 2, Score: 2},
+						{Alternative: alts[1], Count: 1, Score: 1},
+						{Alternative: alts[0], Count: 0, Score: 0},
+					},
+				},
+			}}},
+		},
+		&srvt.T{
+			Name:    "Ranking",
+			Request: *makePollRequest(t, rankingPoll, &userId),
+			Checker: srvt.CheckError{Code: http.StatusForbidden, Body: "Hidden information"},
+		},
+	}
+	srvt.RunFunc(t, tests, HistoryInfoHandler)
+}
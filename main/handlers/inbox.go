@@ -0,0 +1,147 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JBoudou/Itero/main/services"
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/salted"
+	"github.com/JBoudou/Itero/mid/server"
+)
+
+const inboxPageSize = 20
+
+// InboxEntry is a notification stored in the inbox of a user. Segment is empty when the poll has
+// been deleted.
+type InboxEntry struct {
+	Id        uint32
+	Timestamp time.Time
+	Segment   string
+	Title     string
+	Round     uint8
+	Action    services.PollNotifAction
+	Seen      bool
+}
+
+// InboxAnswer is a page of notifications, from the most recent to the oldest. More tells whether
+// there are older notifications on the next page.
+type InboxAnswer struct {
+	Entries []InboxEntry
+	More    bool
+}
+
+// InboxReadQuery is the body of requests marking notifications as seen. If All is true, Ids is
+// ignored and all notifications of the user are marked.
+type InboxReadQuery struct {
+	Ids []uint32
+	All bool
+}
+
+func checkInboxUser(request *server.Request) uint32 {
+	if request.User == nil || !request.User.Logged {
+		must(request.SessionError)
+		panic(server.UnauthorizedHttpError("Unlogged user"))
+	}
+	return request.User.Id
+}
+
+// InboxHandler lists the notifications of the logged user, by pages of inboxPageSize entries. The
+// page may be given in the path. It defaults to the first page.
+func InboxHandler(ctx context.Context, response server.Response, request *server.Request) {
+	user := checkInboxUser(request)
+	page := 0
+	if len(request.RemainingPath) > 0 {
+		var err error
+		page, err = strconv.Atoi(request.RemainingPath[0])
+		if err != nil || page < 0 {
+			page = 0
+		}
+	}
+
+	const qList = `
+	  SELECT n.Id, n.Created, n.Poll, p.Salt, n.Title, n.Round, n.Action, n.Seen
+	    FROM Notifications AS n LEFT OUTER JOIN Polls AS p ON n.Poll = p.Id
+	   WHERE n.User = ?
+	   ORDER BY n.Created DESC, n.Id DESC
+	   LIMIT ? OFFSET ?`
+	rows, err := db.DB.QueryContext(ctx, qList, user, inboxPageSize+1, page*inboxPageSize)
+	must(err)
+	defer rows.Close()
+
+	answer := InboxAnswer{Entries: []InboxEntry{}}
+	for rows.Next() {
+		if len(answer.Entries) == inboxPageSize {
+			answer.More = true
+			break
+		}
+		var entry InboxEntry
+		var poll, salt sql.NullInt64
+		must(rows.Scan(&entry.Id, &entry.Timestamp, &poll, &salt, &entry.Title, &entry.Round,
+			&entry.Action, &entry.Seen))
+		if poll.Valid && salt.Valid {
+			entry.Segment, err = salted.Segment{Id: uint32(poll.Int64), Salt: uint32(salt.Int64)}.Encode()
+			must(err)
+		}
+		answer.Entries = append(answer.Entries, entry)
+	}
+	must(rows.Err())
+
+	response.SendJSON(ctx, answer)
+}
+
+// InboxCountHandler sends the number of notifications the logged user has not seen yet.
+func InboxCountHandler(ctx context.Context, response server.Response, request *server.Request) {
+	user := checkInboxUser(request)
+
+	const qCount = `SELECT COUNT(*) FROM Notifications WHERE User = ? AND NOT Seen`
+	var count uint32
+	must(db.DB.QueryRowContext(ctx, qCount, user).Scan(&count))
+
+	response.SendJSON(ctx, count)
+}
+
+// InboxReadHandler marks notifications of the logged user as seen. The query is an InboxReadQuery.
+func InboxReadHandler(ctx context.Context, response server.Response, request *server.Request) {
+	user := checkInboxUser(request)
+	must(request.CheckPOST(ctx))
+
+	var query InboxReadQuery
+	must(request.UnmarshalJSONBody(&query))
+
+	const qAll = `UPDATE Notifications SET Seen = TRUE WHERE User = ?`
+	if query.All {
+		_, err := db.DB.ExecContext(ctx, qAll, user)
+		must(err)
+	} else if len(query.Ids) > 0 {
+		args := make([]interface{}, 0, len(query.Ids)+1)
+		args = append(args, user)
+		for _, id := range query.Ids {
+			args = append(args, id)
+		}
+		qSome := qAll + ` AND Id IN (?` + strings.Repeat(`, ?`, len(query.Ids)-1) + `)`
+		_, err := db.DB.ExecContext(ctx, qSome, args...)
+		must(err)
+	}
+
+	response.SendJSON(ctx, "Ok")
+}
@@ -0,0 +1,150 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/JBoudou/Itero/main/services"
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/server"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+type inboxPageChecker struct {
+	entries int
+	more    bool
+	deleted int
+}
+
+func (self inboxPageChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	var answer InboxAnswer
+	mustt(t, json.NewDecoder(response.Body).Decode(&answer))
+	if len(answer.Entries) != self.entries {
+		t.Errorf("Wrong number of entries. Got %d. Expect %d.", len(answer.Entries), self.entries)
+	}
+	if answer.More != self.more {
+		t.Errorf("Wrong More. Got %t. Expect %t.", answer.More, self.more)
+	}
+	deleted := 0
+	for _, entry := range answer.Entries {
+		if entry.Segment == "" {
+			deleted += 1
+		}
+	}
+	if deleted != self.deleted {
+		t.Errorf("Wrong number of deleted polls. Got %d. Expect %d.", deleted, self.deleted)
+	}
+}
+
+func TestInboxHandler(t *testing.T) {
+	precheck(t)
+
+	const (
+		qPoll    = `INSERT INTO Notifications (User, Poll, Title, Action) VALUE (?, ?, 'Inbox', ?)`
+		qDeleted = `INSERT INTO Notifications (User, Title, Action) VALUE (?, 'Deleted', ?)`
+	)
+
+	var env dbt.Env
+	defer env.Close()
+	userId := env.CreateUserWith("Inbox")
+	pollId := env.CreatePoll("Inbox", userId, db.ElectorateAll)
+	for i := 0; i < inboxPageSize; i++ {
+		env.QuietExec(qPoll, userId, pollId, services.PollNotifNext)
+	}
+	env.QuietExec(qDeleted, userId, services.PollNotifDelete)
+	env.Must(t)
+
+	target := "/a/test/1"
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Unlogged",
+			Checker: srvt.CheckStatus{http.StatusUnauthorized},
+		},
+		&srvt.T{
+			Name:    "First page",
+			Request: srvt.Request{UserId: &userId},
+			Checker: inboxPageChecker{entries: inboxPageSize, more: true},
+		},
+		&srvt.T{
+			Name:    "Second page",
+			Request: srvt.Request{Target: &target, UserId: &userId},
+			Checker: inboxPageChecker{entries: 1, deleted: 1},
+		},
+	}
+	srvt.RunFunc(t, tests, InboxHandler)
+}
+
+func TestInboxReadHandler(t *testing.T) {
+	precheck(t)
+
+	const qNotif = `INSERT INTO Notifications (User, Title, Action) VALUE (?, 'Read', ?)`
+
+	var env dbt.Env
+	defer env.Close()
+	userId := env.CreateUserWith("Read")
+	var ids []uint32
+	for i := 0; i < 3; i++ {
+		result, err := db.DB.Exec(qNotif, userId, services.PollNotifDelete)
+		mustt(t, err)
+		id, err := db.IdFromResult(result)
+		mustt(t, err)
+		ids = append(ids, id)
+	}
+
+	readRequest := func(query InboxReadQuery) srvt.Request {
+		body, err := json.Marshal(query)
+		mustt(t, err)
+		return srvt.Request{Method: "POST", UserId: &userId, Body: string(body)}
+	}
+	countTest := func(name string, expect uint32) srvt.Test {
+		return &srvt.T{
+			Name:    name,
+			Request: srvt.Request{UserId: &userId},
+			Checker: srvt.CheckJSON{Body: expect},
+		}
+	}
+
+	srvt.RunFunc(t, []srvt.Test{countTest("Initial count", 3)}, InboxCountHandler)
+	srvt.RunFunc(t, []srvt.Test{
+		&srvt.T{
+			Name:    "Unlogged",
+			Request: srvt.Request{Method: "POST", Body: `{"All":true}`},
+			Checker: srvt.CheckStatus{http.StatusUnauthorized},
+		},
+		&srvt.T{
+			Name:    "Some",
+			Request: readRequest(InboxReadQuery{Ids: ids[:2]}),
+			Checker: srvt.CheckStatus{http.StatusOK},
+		},
+	}, InboxReadHandler)
+	srvt.RunFunc(t, []srvt.Test{countTest("Count after some", 1)}, InboxCountHandler)
+	srvt.RunFunc(t, []srvt.Test{
+		&srvt.T{
+			Name:    "All",
+			Request: readRequest(InboxReadQuery{All: true}),
+			Checker: srvt.CheckStatus{http.StatusOK},
+		},
+	}, InboxReadHandler)
+	srvt.RunFunc(t, []srvt.Test{countTest("Count after all", 0)}, InboxCountHandler)
+}
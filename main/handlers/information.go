@@ -0,0 +1,141 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/rules"
+)
+
+// roundProfile is the profile of a round of a poll. It is loaded once, then used to compute all
+// the results of the round. Exactly one of ranks and grades is set, depending on the type of the
+// poll. Delegated is the number of ballots cast through delegation.
+type roundProfile struct {
+	ranks     *rules.Profile
+	grades    *rules.GradeProfile
+	delegated uint32
+}
+
+// loadRoundProfile retrieves the profile of a round of the poll. Errors are sent by panic.
+func loadRoundProfile(ctx context.Context, pollInfo PollInfo, round uint8) (ret roundProfile) {
+	var err error
+	if pollInfo.Type == db.PollTypeGrading {
+		ret.grades, ret.delegated, err = db.LoadGradeProfileDelegated(ctx, pollInfo.Id, round)
+	} else {
+		ret.ranks, ret.delegated, err = db.LoadProfileDelegated(ctx, pollInfo.Id, round)
+	}
+	must(err)
+	return
+}
+
+// pollOutcome computes the outcome of a round of the poll, according to the rule of the poll.
+func pollOutcome(pollInfo PollInfo, profile roundProfile) []rules.Result {
+	if profile.grades != nil {
+		return db.GradeRuleFromDB(pollInfo.Rule).Outcome(profile.grades)
+	}
+	return db.RuleFromDB(pollInfo.Rule).Outcome(profile.ranks)
+}
+
+// RankingInfoAnswer is the response sent by RankingInfoHandler.
+// Ranking is sorted from the best alternative to the worst one.
+type RankingInfoAnswer struct {
+	Ranking []PollAlternative
+}
+
+// RankingInfoHandler sends the outcome of a previous round, without scores.
+// The outcome is sent only if the poll gives full counts or the ranking.
+func RankingInfoHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+	if err = pollInfo.checkInformation(db.InformationCounts, db.InformationRanking); err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+	round, err := getInfoRound(request, pollInfo)
+	if err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+
+	outcome := pollOutcome(pollInfo, loadRoundProfile(ctx, pollInfo, round))
+	var alternatives []PollAlternative
+	allAlternatives(ctx, pollInfo, &alternatives)
+	answer := RankingInfoAnswer{Ranking: make([]PollAlternative, len(outcome))}
+	for i, result := range outcome {
+		answer.Ranking[i] = alternatives[result.Alternative]
+	}
+	response.SendJSON(ctx, answer)
+}
+
+// WinnerInfoAnswer is the response sent by WinnerInfoHandler.
+type WinnerInfoAnswer struct {
+	Winner PollAlternative
+}
+
+// WinnerInfoHandler sends the best alternative of a previous round. Ties are broken as by the rule
+// of the poll. The winner is sent unless the poll gives no information.
+func WinnerInfoHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+	err = pollInfo.checkInformation(db.InformationCounts, db.InformationRanking, db.InformationWinner,
+		db.InformationTop)
+	if err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+	round, err := getInfoRound(request, pollInfo)
+	if err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+
+	outcome := pollOutcome(pollInfo, loadRoundProfile(ctx, pollInfo, round))
+	var alternatives []PollAlternative
+	allAlternatives(ctx, pollInfo, &alternatives)
+	response.SendJSON(ctx, WinnerInfoAnswer{Winner: alternatives[outcome[0].Alternative]})
+}
+
+// TopInfoAnswer is the response sent by TopInfoHandler.
+type TopInfoAnswer struct {
+	Result []CountInfoEntry
+}
+
+// TopInfoHandler sends the result of a previous round, as CountInfoHandler, but restricted to the
+// best alternatives. The number of alternatives is given by the field InformationTop of the poll.
+// The result is sent only if the poll gives full counts or the top alternatives.
+func TopInfoHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+	if err = pollInfo.checkInformation(db.InformationCounts, db.InformationTop); err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+	round, err := getInfoRound(request, pollInfo)
+	if err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+
+	entries := countEntries(ctx, pollInfo, loadRoundProfile(ctx, pollInfo, round))
+	if len(entries) > int(pollInfo.InformationTop) {
+		entries = entries[:pollInfo.InformationTop]
+	}
+	response.SendJSON(ctx, TopInfoAnswer{Result: entries})
+}
@@ -0,0 +1,174 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+var informationTestAlternatives = []PollAlternative{
+	{Id: 0, Name: "A", Cost: 1},
+	{Id: 1, Name: "B", Cost: 1},
+	{Id: 2, Name: "C", Cost: 1},
+}
+
+// createInformationPolls returns a function creating polls with the given information mode, in
+// which two users voted for B and one user voted for C during round 0. The current round of these
+// polls is 1. The returned user can access all these polls.
+func createInformationPolls(env *dbt.Env) (create func(db.Information, uint8) uint32, user uint32) {
+	const qInformation = `UPDATE Polls SET Information = ?, InformationTop = ? WHERE Id = ?`
+
+	var users [3]uint32
+	for i := range users {
+		users[i] = env.CreateUserWith(strconv.FormatInt(int64(i), 10))
+	}
+	create = func(information db.Information, top uint8) uint32 {
+		pollId := env.CreatePollWith("Test", users[0], db.ElectorateAll, []string{"A", "B", "C"})
+		env.QuietExec(qInformation, information, top, pollId)
+		env.Vote(pollId, 0, users[0], 1)
+		env.Vote(pollId, 0, users[1], 1)
+		env.Vote(pollId, 0, users[2], 2)
+		env.NextRound(pollId)
+		return pollId
+	}
+	return create, users[0]
+}
+
+// informationPollUsers returns the users who voted in a poll created by createInformationPolls,
+// in the order of their creation.
+func informationPollUsers(t *testing.T, pollId uint32) (users []uint32) {
+	const qUsers = `SELECT User FROM Participants WHERE Poll = ? AND Round = 0 ORDER BY User`
+	rows, err := db.DB.Query(qUsers, pollId)
+	mustt(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var user uint32
+		mustt(t, rows.Scan(&user))
+		users = append(users, user)
+	}
+	mustt(t, rows.Err())
+	return
+}
+
+func TestRankingInfoHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	create, userId := createInformationPolls(&env)
+	countsPoll := create(db.InformationCounts, 3)
+	rankingPoll := create(db.InformationRanking, 3)
+	winnerPoll := create(db.InformationWinner, 3)
+	terminatedPoll := create(db.InformationNone, 3)
+	env.QuietExec(`UPDATE Polls SET State = 'Terminated' WHERE Id = ?`, terminatedPoll)
+	env.Must(t)
+
+	alts := informationTestAlternatives
+	expect := RankingInfoAnswer{Ranking: []PollAlternative{alts[1], alts[2], alts[0]}}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Counts",
+			Request: *makePollRequest(t, countsPoll, &userId),
+			Checker: srvt.CheckJSON{Body: expect},
+		},
+		&srvt.T{
+			Name:    "Ranking",
+			Request: *makePollRequest(t, rankingPoll, &userId),
+			Checker: srvt.CheckJSON{Body: expect},
+		},
+		&srvt.T{
+			Name:    "Winner",
+			Request: *makePollRequest(t, winnerPoll, &userId),
+			Checker: srvt.CheckError{Code: http.StatusForbidden, Body: "Hidden information"},
+		},
+		&srvt.T{
+			Name:    "Terminated",
+			Request: *makePollRequest(t, terminatedPoll, &userId),
+			Checker: srvt.CheckJSON{Body: expect},
+		},
+	}
+	srvt.RunFunc(t, tests, RankingInfoHandler)
+}
+
+func TestWinnerInfoHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	create, userId := createInformationPolls(&env)
+	winnerPoll := create(db.InformationWinner, 3)
+	topPoll := create(db.InformationTop, 1)
+	nonePoll := create(db.InformationNone, 3)
+	env.Must(t)
+
+	expect := WinnerInfoAnswer{Winner: informationTestAlternatives[1]}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Winner",
+			Request: *makePollRequest(t, winnerPoll, &userId),
+			Checker: srvt.CheckJSON{Body: expect},
+		},
+		&srvt.T{
+			Name:    "Top",
+			Request: *makePollRequest(t, topPoll, &userId),
+			Checker: srvt.CheckJSON{Body: expect},
+		},
+		&srvt.T{
+			Name:    "None",
+			Request: *makePollRequest(t, nonePoll, &userId),
+			Checker: srvt.CheckError{Code: http.StatusForbidden, Body: "Hidden information"},
+		},
+	}
+	srvt.RunFunc(t, tests, WinnerInfoHandler)
+}
+
+func TestTopInfoHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	create, userId := createInformationPolls(&env)
+	topPoll := create(db.InformationTop, 2)
+	rankingPoll := create(db.InformationRanking, 2)
+	env.Must(t)
+
+	alts := informationTestAlternatives
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Top",
+			Request: *makePollRequest(t, topPoll, &userId),
+			Checker: srvt.CheckJSON{Body: TopInfoAnswer{Result: []CountInfoEntry{
+				{Alternative: alts[1], Count: 2, Score: 2},
+				{Alternative: alts[2], Count: 1, Score: 1},
+			}}},
+		},
+		&srvt.T{
+			Name:    "Ranking",
+			Request: *makePollRequest(t, rankingPoll, &userId),
+			Checker: srvt.CheckError{Code: http.StatusForbidden, Body: "Hidden information"},
+		},
+	}
+	srvt.RunFunc(t, tests, TopInfoHandler)
+}
@@ -0,0 +1,248 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/JBoudou/Itero/main/services"
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/salted"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/events"
+	"github.com/JBoudou/Itero/pkg/slog"
+)
+
+// InviteQuery is the body of invite and revoke requests. Weight is the weight given to the invited
+// users once they redeem their invitation. It is ignored by revoke requests, and zero means 1.
+type InviteQuery struct {
+	Emails []string
+	Weight uint32
+}
+
+type InvitationEntry struct {
+	Email    string
+	Weight   uint32
+	Redeemed bool
+	Sent     time.Time
+}
+
+var emailRegexp = regexp.MustCompile("^[^\\s@]+@[^\\s.]+\\.\\S\\S+$")
+
+type inviteHandler struct {
+	evtManager events.Manager
+}
+
+// InviteHandler invites people to a poll whose electorate is restricted to invited users. The
+// query is an InviteQuery. An invitation is emailed to each address not invited yet, and sent
+// again to each address whose invitation has not been redeemed yet. Only the administrator can
+// invite, and only before the end of the first round.
+func InviteHandler(evtManager events.Manager) inviteHandler {
+	return inviteHandler{evtManager: evtManager}
+}
+
+func (self inviteHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
+	must(request.CheckPOST(ctx))
+	poll := checkPollAdmin(ctx, request)
+	segment := poll.Segment
+	if poll.Electorate != db.ElectorateInvited || poll.Terminated || poll.CurrentRound > 0 {
+		panic(server.NewHttpError(http.StatusLocked, "Not invitable", "Invitations are closed"))
+	}
+
+	var query InviteQuery
+	must(request.UnmarshalJSONBody(&query))
+	if query.Weight == 0 {
+		query.Weight = 1
+	}
+	for _, email := range query.Emails {
+		if !emailRegexp.MatchString(email) {
+			panic(server.NewHttpError(http.StatusBadRequest, "Email invalid", "Wrong email format"))
+		}
+	}
+
+	const (
+		qSelect = `SELECT Id, User IS NOT NULL FROM Invitations WHERE Poll = ? AND Email = ?`
+		qInsert = `INSERT INTO Invitations (Salt, Poll, Email, Weight) VALUE (?, ?, ?, ?)`
+		qUpdate = `UPDATE Invitations SET Weight = ?, Sent = CURRENT_TIMESTAMP WHERE Id = ?`
+	)
+
+	var toSend []uint32
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		toSend = toSend[:0]
+		for _, email := range query.Emails {
+			var id uint32
+			var redeemed bool
+			err := tx.QueryRowContext(ctx, qSelect, segment.Id, email).Scan(&id, &redeemed)
+			switch {
+			case err == sql.ErrNoRows:
+				invitation, err := salted.New(0)
+				must(err)
+				result, err := tx.ExecContext(ctx, qInsert, invitation.Salt, segment.Id, email,
+					query.Weight)
+				must(err)
+				id, err = db.IdFromResult(result)
+				must(err)
+			case err != nil:
+				panic(err)
+			case redeemed:
+				continue
+			default:
+				_, err = tx.ExecContext(ctx, qUpdate, query.Weight, id)
+				must(err)
+			}
+			toSend = append(toSend, id)
+		}
+	})
+
+	response.SendJSON(ctx, "Ok")
+	for _, id := range toSend {
+		self.evtManager.Send(services.InvitationEvent{id})
+	}
+}
+
+type revokeHandler struct {
+	evtManager events.Manager
+}
+
+// RevokeHandler cancels invitations to a poll. The query is an InviteQuery. Users that already
+// redeemed a revoked invitation lose access to the poll, and are removed from its participants
+// together with their ballots and their weight. Hence redeemed invitations can only be revoked
+// during the first round. Only the administrator can revoke invitations.
+func RevokeHandler(evtManager events.Manager) revokeHandler {
+	return revokeHandler{evtManager: evtManager}
+}
+
+func (self revokeHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
+	must(request.CheckPOST(ctx))
+	poll := checkPollAdmin(ctx, request)
+
+	var query InviteQuery
+	must(request.UnmarshalJSONBody(&query))
+
+	const (
+		qSelect = `SELECT User FROM Invitations WHERE Poll = ? AND Email = ? FOR UPDATE`
+		qDelete = `DELETE FROM Invitations WHERE Poll = ? AND Email = ?`
+		qWeight = `DELETE FROM Weights WHERE Poll = ? AND User = ?`
+	)
+
+	var removed []uint32
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		removed = removed[:0]
+		for _, email := range query.Emails {
+			var user sql.NullInt64
+			err := tx.QueryRowContext(ctx, qSelect, poll.Segment.Id, email).Scan(&user)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			must(err)
+			if user.Valid {
+				if poll.Terminated || poll.CurrentRound > 0 {
+					panic(server.NewHttpError(http.StatusLocked, "Too late",
+						"Redeemed invitations can only be revoked during the first round"))
+				}
+				if removeParticipant(ctx, tx, poll.Segment.Id, uint32(user.Int64)) {
+					removed = append(removed, uint32(user.Int64))
+				}
+				_, err = tx.ExecContext(ctx, qWeight, poll.Segment.Id, user.Int64)
+				must(err)
+			}
+			_, err = tx.ExecContext(ctx, qDelete, poll.Segment.Id, email)
+			must(err)
+		}
+	})
+
+	response.SendJSON(ctx, "Ok")
+	for _, user := range removed {
+		self.evtManager.Send(services.RemoveParticipantEvent{Poll: poll.Segment.Id, User: user})
+	}
+}
+
+// InvitationsHandler lists the invitations to a poll. Only the administrator can list them.
+func InvitationsHandler(ctx context.Context, response server.Response, request *server.Request) {
+	segment := checkPollAdmin(ctx, request).Segment
+
+	const qList = `
+	  SELECT Email, Weight, User IS NOT NULL, Sent
+	    FROM Invitations
+	   WHERE Poll = ?
+	   ORDER BY Email ASC`
+	rows, err := db.DB.QueryContext(ctx, qList, segment.Id)
+	must(err)
+	defer rows.Close()
+	answer := []InvitationEntry{}
+	for rows.Next() {
+		var entry InvitationEntry
+		must(rows.Scan(&entry.Email, &entry.Weight, &entry.Redeemed, &entry.Sent))
+		answer = append(answer, entry)
+	}
+	must(rows.Err())
+
+	response.SendJSON(ctx, answer)
+}
+
+// RedeemHandler binds an invitation to the logged user, giving the user access to the poll with
+// the weight of the invitation. The invitation is identified by the token in the path. The answer is the segment of the poll.
+func RedeemHandler(ctx context.Context, response server.Response, request *server.Request) {
+	if request.User == nil || !request.User.Logged {
+		must(request.SessionError)
+		panic(server.UnauthorizedHttpError("Unlogged user"))
+	}
+	must(request.CheckPOST(ctx))
+	token, err := salted.FromRequest(request)
+	must(err)
+
+	const (
+		qSelect = `
+		  SELECT i.Salt, COALESCE(i.User, 0), p.Id, p.Salt
+		    FROM Invitations AS i, Polls AS p
+		   WHERE i.Id = ? AND p.Id = i.Poll
+		     FOR UPDATE`
+		qUpdate = `UPDATE Invitations SET User = ? WHERE Id = ?`
+		qWeight = `
+		  REPLACE INTO Weights (Poll, User, Weight)
+		  SELECT Poll, User, Weight FROM Invitations WHERE Id = ?`
+	)
+
+	var pollSegment salted.Segment
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		var salt, user uint32
+		err := tx.QueryRowContext(ctx, qSelect, token.Id).
+			Scan(&salt, &user, &pollSegment.Id, &pollSegment.Salt)
+		if err == sql.ErrNoRows || (err == nil && salt != token.Salt) {
+			panic(server.NewHttpError(http.StatusNotFound, "Not found", "No such invitation"))
+		}
+		must(err)
+		if user == request.User.Id {
+			return
+		}
+		if user != 0 {
+			panic(server.NewHttpError(http.StatusConflict, "Already redeemed", "Invitation already used"))
+		}
+		_, err = tx.ExecContext(ctx, qUpdate, request.User.Id, token.Id)
+		must(err)
+		_, err = tx.ExecContext(ctx, qWeight, token.Id)
+		must(err)
+	})
+
+	encoded, err := pollSegment.Encode()
+	must(err)
+	response.SendJSON(ctx, encoded)
+}
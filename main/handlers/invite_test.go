@@ -0,0 +1,249 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/salted"
+	"github.com/JBoudou/Itero/mid/server"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+type invitationsChecker struct {
+	poll   uint32
+	emails []string
+}
+
+func (self invitationsChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	const qList = `SELECT Email FROM Invitations WHERE Poll = ? ORDER BY Email ASC`
+	rows, err := db.DB.Query(qList, self.poll)
+	mustt(t, err)
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var email string
+		mustt(t, rows.Scan(&email))
+		got = append(got, email)
+	}
+	if !reflect.DeepEqual(got, self.emails) {
+		t.Errorf("Wrong invitations. Got %v. Expect %v.", got, self.emails)
+	}
+}
+
+func TestInviteHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	userId := env.CreateUserWith("Admin")
+	otherId := env.CreateUserWith("Other")
+	pollId := env.CreatePoll("Invited", userId, db.ElectorateInvited)
+	loggedPollId := env.CreatePoll("Logged", userId, db.ElectorateLogged)
+	env.Must(t)
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name: "Invite",
+			Request: makePollPOSTRequest(t, pollId, &userId,
+				InviteQuery{Emails: []string{"b@example.com", "a@example.com"}}),
+			Checker: invitationsChecker{poll: pollId, emails: []string{"a@example.com", "b@example.com"}},
+		},
+		&srvt.T{
+			Name: "Resend",
+			Request: makePollPOSTRequest(t, pollId, &userId,
+				InviteQuery{Emails: []string{"a@example.com", "c@example.com"}}),
+			Checker: invitationsChecker{
+				poll:   pollId,
+				emails: []string{"a@example.com", "b@example.com", "c@example.com"},
+			},
+		},
+		&srvt.T{
+			Name: "Wrong email",
+			Request: makePollPOSTRequest(t, pollId, &userId,
+				InviteQuery{Emails: []string{"nobody"}}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Email invalid"},
+		},
+		&srvt.T{
+			Name: "Not invitation poll",
+			Request: makePollPOSTRequest(t, loggedPollId, &userId,
+				InviteQuery{Emails: []string{"a@example.com"}}),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Not invitable"},
+		},
+		&srvt.T{
+			Name: "Not admin",
+			Request: makePollPOSTRequest(t, pollId, &otherId,
+				InviteQuery{Emails: []string{"d@example.com"}}),
+			Checker: srvt.CheckStatus{http.StatusNotFound},
+		},
+	}
+	srvt.Run(t, tests, InviteHandler)
+
+	tests = []srvt.Test{
+		&srvt.T{
+			Name: "Revoke",
+			Request: makePollPOSTRequest(t, pollId, &userId,
+				InviteQuery{Emails: []string{"b@example.com"}}),
+			Checker: invitationsChecker{poll: pollId, emails: []string{"a@example.com", "c@example.com"}},
+		},
+		&srvt.T{
+			Name: "Revoke not admin",
+			Request: makePollPOSTRequest(t, pollId, &otherId,
+				InviteQuery{Emails: []string{"a@example.com"}}),
+			Checker: srvt.CheckStatus{http.StatusNotFound},
+		},
+	}
+	srvt.Run(t, tests, RevokeHandler)
+}
+
+func TestRevokeHandler_Redeemed(t *testing.T) {
+	precheck(t)
+
+	const (
+		qInvite = `INSERT INTO Invitations (Salt, Poll, Email, User) VALUE (42, ?, ?, ?)`
+		qWeight = `INSERT INTO Weights (Poll, User, Weight) VALUE (?, ?, 2)`
+		qRemain = `
+		  SELECT (SELECT COUNT(*) FROM Participants WHERE Poll = ? AND User = ?)
+		       + (SELECT COUNT(*) FROM Weights WHERE Poll = ? AND User = ?)`
+		email = "redeemed@example.com"
+	)
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	userId := env.CreateUserWith("Invitee")
+	createPoll := func() uint32 {
+		pollId := env.CreatePoll("Invited", adminId, db.ElectorateInvited)
+		env.QuietExec(qInvite, pollId, email, userId)
+		env.QuietExec(qWeight, pollId, userId)
+		env.Vote(pollId, 0, userId, 1)
+		return pollId
+	}
+	pollId := createPoll()
+	latePollId := createPoll()
+	env.NextRound(latePollId)
+	env.Must(t)
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "First round",
+			Request: makePollPOSTRequest(t, pollId, &adminId, InviteQuery{Emails: []string{email}}),
+			Checker: invitationsChecker{poll: pollId},
+		},
+		&srvt.T{
+			Name: "Later round",
+			Request: makePollPOSTRequest(t, latePollId, &adminId,
+				InviteQuery{Emails: []string{email}}),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Too late"},
+		},
+	}
+	srvt.Run(t, tests, RevokeHandler)
+
+	var remain int
+	mustt(t, db.DB.QueryRow(qRemain, pollId, userId, pollId, userId).Scan(&remain))
+	if remain != 0 {
+		t.Errorf("Participation or weight kept after revocation.")
+	}
+}
+
+func TestRedeemHandler(t *testing.T) {
+	precheck(t)
+
+	const qInvite = `INSERT INTO Invitations (Salt, Poll, Email) VALUE (?, ?, ?)`
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	userId := env.CreateUserWith("Invitee")
+	otherId := env.CreateUserWith("Other")
+	pollId := env.CreatePoll("Invited", adminId, db.ElectorateInvited)
+	env.Must(t)
+
+	result, err := db.DB.Exec(qInvite, 42, pollId, "invitee@example.com")
+	mustt(t, err)
+	invitation := salted.Segment{Salt: 42}
+	invitation.Id, err = db.IdFromResult(result)
+	mustt(t, err)
+	encoded, err := invitation.Encode()
+	mustt(t, err)
+	target := "/a/test/" + encoded
+	wrong := salted.Segment{Id: invitation.Id, Salt: 43}
+	encoded, err = wrong.Encode()
+	mustt(t, err)
+	wrongTarget := "/a/test/" + encoded
+
+	pollSegment, err := salted.Segment{Id: pollId, Salt: dbt.PollSalt}.Encode()
+	mustt(t, err)
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Not invited yet",
+			Request: *makePollRequest(t, pollId, &userId),
+			Checker: srvt.CheckError{Code: http.StatusForbidden, Body: "Not invited"},
+		},
+		&srvt.T{
+			Name:    "Admin",
+			Request: *makePollRequest(t, pollId, &adminId),
+			Checker: srvt.CheckStatus{http.StatusOK},
+		},
+	}
+	srvt.RunFunc(t, tests, PollHandler)
+
+	tests = []srvt.Test{
+		&srvt.T{
+			Name:    "Wrong salt",
+			Request: srvt.Request{Target: &wrongTarget, UserId: &userId, Method: "POST"},
+			Checker: srvt.CheckStatus{http.StatusNotFound},
+		},
+		&srvt.T{
+			Name:    "Redeem",
+			Request: srvt.Request{Target: &target, UserId: &userId, Method: "POST"},
+			Checker: srvt.CheckJSON{Body: pollSegment},
+		},
+		&srvt.T{
+			Name:    "Redeem again",
+			Request: srvt.Request{Target: &target, UserId: &userId, Method: "POST"},
+			Checker: srvt.CheckJSON{Body: pollSegment},
+		},
+		&srvt.T{
+			Name:    "Other user",
+			Request: srvt.Request{Target: &target, UserId: &otherId, Method: "POST"},
+			Checker: srvt.CheckError{Code: http.StatusConflict, Body: "Already redeemed"},
+		},
+	}
+	srvt.RunFunc(t, tests, RedeemHandler)
+
+	tests = []srvt.Test{
+		&srvt.T{
+			Name:    "Invited",
+			Request: *makePollRequest(t, pollId, &userId),
+			Checker: srvt.CheckStatus{http.StatusOK},
+		},
+		&srvt.T{
+			Name:    "Not invited",
+			Request: *makePollRequest(t, pollId, &otherId),
+			Checker: srvt.CheckError{Code: http.StatusForbidden, Body: "Not invited"},
+		},
+	}
+	srvt.RunFunc(t, tests, PollHandler)
+}
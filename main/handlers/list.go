@@ -99,8 +99,11 @@ func ListHandler(ctx context.Context, response server.Response, request *server.
 	               GROUP BY Poll
 	           ) AS a ON p.Id = a.Poll, Users AS u
 	     WHERE ( (p.State != 'Waiting' AND p.CurrentRound = 0 AND NOT p.Hidden AND
-		 							(u.Verified OR p.Electorate != 'Verified'))
+		 							(u.Verified OR p.Electorate != 'Verified') AND p.Electorate != 'Invited')
+	              OR (p.State != 'Waiting' AND p.CurrentRound = 0 AND EXISTS (
+	                    SELECT 1 FROM Invitations AS i WHERE i.Poll = p.Id AND i.User = u.Id))
 	              OR a.Poll IS NOT NULL )
+	       AND NOT EXISTS (SELECT 1 FROM Blocked AS b WHERE b.Poll = p.Id AND b.User = u.Id)
 	       AND u.Id = ? AND p.Admin != u.Id
 	     ORDER BY Action ASC, Deadline ASC`
 		qOwn = `
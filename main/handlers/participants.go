@@ -0,0 +1,236 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/JBoudou/Itero/main/services"
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/events"
+	"github.com/JBoudou/Itero/pkg/slog"
+)
+
+type ParticipantEntry struct {
+	Name      string
+	LastRound uint8
+	Weight    uint32
+}
+
+// ParticipantsAnswer lists the participants of a poll. Unlogged participants are only counted.
+type ParticipantsAnswer struct {
+	Logged   []ParticipantEntry
+	Unlogged uint32
+	Blocked  []string
+}
+
+// ParticipantQuery is the body of remove and block requests.
+type ParticipantQuery struct {
+	Name string
+}
+
+// WeightQuery is the body of weight requests.
+type WeightQuery struct {
+	Name   string
+	Weight uint32
+}
+
+// ParticipantsHandler lists the participants of a poll. Only the administrator can list them.
+func ParticipantsHandler(ctx context.Context, response server.Response, request *server.Request) {
+	poll := checkPollAdmin(ctx, request)
+
+	const (
+		qLogged = `
+		  SELECT u.Name, MAX(a.Round), COALESCE(w.Weight, 1)
+		    FROM Participants AS a
+		    JOIN Users AS u ON u.Id = a.User
+		    LEFT OUTER JOIN Weights AS w ON (w.Poll, w.User) = (a.Poll, a.User)
+		   WHERE a.Poll = ? AND u.Name IS NOT NULL
+		   GROUP BY u.Id, u.Name, w.Weight
+		   ORDER BY u.Name ASC`
+		qUnlogged = `
+		  SELECT COUNT(DISTINCT a.User)
+		    FROM Participants AS a, Users AS u
+		   WHERE a.Poll = ? AND u.Id = a.User AND u.Name IS NULL`
+		qBlocked = `
+		  SELECT u.Name
+		    FROM Blocked AS b, Users AS u
+		   WHERE b.Poll = ? AND u.Id = b.User
+		   ORDER BY u.Name ASC`
+	)
+
+	answer := ParticipantsAnswer{Logged: []ParticipantEntry{}, Blocked: []string{}}
+
+	rows, err := db.DB.QueryContext(ctx, qLogged, poll.Id)
+	must(err)
+	defer rows.Close()
+	for rows.Next() {
+		var entry ParticipantEntry
+		must(rows.Scan(&entry.Name, &entry.LastRound, &entry.Weight))
+		answer.Logged = append(answer.Logged, entry)
+	}
+	must(rows.Err())
+
+	must(db.DB.QueryRowContext(ctx, qUnlogged, poll.Id).Scan(&answer.Unlogged))
+
+	rows, err = db.DB.QueryContext(ctx, qBlocked, poll.Id)
+	must(err)
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		must(rows.Scan(&name))
+		answer.Blocked = append(answer.Blocked, name)
+	}
+	must(rows.Err())
+
+	response.SendJSON(ctx, answer)
+}
+
+// userByName returns the id of the logged user with the given name.
+// Errors are sent by panic.
+func userByName(ctx context.Context, tx *sql.Tx, name string) (user uint32) {
+	const qUser = `SELECT Id FROM Users WHERE Name = ?`
+	err := tx.QueryRowContext(ctx, qUser, name).Scan(&user)
+	if err == sql.ErrNoRows {
+		panic(server.NewHttpError(http.StatusNotFound, "Unknown user", "No user with that name"))
+	}
+	must(err)
+	return
+}
+
+// removeParticipant deletes all the participations and delegations of user to poll, together with
+// the corresponding ballots. It returns whether the user was a participant.
+func removeParticipant(ctx context.Context, tx *sql.Tx, poll, user uint32) bool {
+	const (
+		qDelete     = `DELETE FROM Participants WHERE Poll = ? AND User = ?`
+		qDelegation = `DELETE FROM Delegations WHERE Poll = ? AND User = ?`
+		qMover      = `UPDATE Polls SET CurrentMover = NULL WHERE Id = ? AND CurrentMover = ?`
+	)
+	var affected int64
+	for _, query := range []string{qDelete, qDelegation} {
+		result, err := tx.ExecContext(ctx, query, poll, user)
+		must(err)
+		nb, err := result.RowsAffected()
+		must(err)
+		affected += nb
+	}
+	_, err := tx.ExecContext(ctx, qMover, poll, user)
+	must(err)
+	return affected > 0
+}
+
+type removeParticipantHandler struct {
+	evtManager events.Manager
+}
+
+// RemoveParticipantHandler removes a logged participant, and all its ballots, from a poll. The
+// query is a ParticipantQuery. Only the administrator can remove participants, and only during the
+// first round.
+func RemoveParticipantHandler(evtManager events.Manager) removeParticipantHandler {
+	return removeParticipantHandler{evtManager: evtManager}
+}
+
+func (self removeParticipantHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
+	must(request.CheckPOST(ctx))
+	poll := checkPollAdmin(ctx, request)
+	if poll.Terminated || poll.CurrentRound > 0 {
+		panic(server.NewHttpError(http.StatusLocked, "Too late",
+			"Participants can only be removed during the first round"))
+	}
+
+	var query ParticipantQuery
+	must(request.UnmarshalJSONBody(&query))
+
+	var user uint32
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		user = userByName(ctx, tx, query.Name)
+		if !removeParticipant(ctx, tx, poll.Id, user) {
+			panic(server.NewHttpError(http.StatusNotFound, "Not participant", "The user did not participate"))
+		}
+	})
+
+	response.SendJSON(ctx, "Ok")
+	self.evtManager.Send(services.RemoveParticipantEvent{Poll: poll.Id, User: user})
+}
+
+type blockHandler struct {
+	evtManager events.Manager
+}
+
+// BlockHandler prevents a logged user from participating in a poll. The query is a
+// ParticipantQuery. During the first round, the user is also removed from the participants, as
+// by RemoveParticipantHandler. Only the administrator can block users.
+func BlockHandler(evtManager events.Manager) blockHandler {
+	return blockHandler{evtManager: evtManager}
+}
+
+func (self blockHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
+	must(request.CheckPOST(ctx))
+	poll := checkPollAdmin(ctx, request)
+
+	var query ParticipantQuery
+	must(request.UnmarshalJSONBody(&query))
+
+	const qBlock = `INSERT IGNORE INTO Blocked (Poll, User) VALUE (?, ?)`
+
+	var user uint32
+	var removed bool
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		user = userByName(ctx, tx, query.Name)
+		if user == request.User.Id {
+			panic(server.NewHttpError(http.StatusBadRequest, "Bad request", "Cannot block the administrator"))
+		}
+		_, err := tx.ExecContext(ctx, qBlock, poll.Id, user)
+		must(err)
+		removed = !poll.Terminated && poll.CurrentRound == 0 && removeParticipant(ctx, tx, poll.Id, user)
+	})
+
+	response.SendJSON(ctx, "Ok")
+	if removed {
+		self.evtManager.Send(services.RemoveParticipantEvent{Poll: poll.Id, User: user})
+	}
+}
+
+// WeightHandler sets the weight of a logged user in a poll. The query is a WeightQuery. The user
+// does not need to be a participant yet. Only the administrator can set weights, and only during
+// the first round.
+func WeightHandler(ctx context.Context, response server.Response, request *server.Request) {
+	must(request.CheckPOST(ctx))
+	poll := checkPollAdmin(ctx, request)
+	if poll.Terminated || poll.CurrentRound > 0 {
+		panic(server.NewHttpError(http.StatusLocked, "Too late",
+			"Weights can only be changed during the first round"))
+	}
+
+	var query WeightQuery
+	must(request.UnmarshalJSONBody(&query))
+	if query.Weight < 1 {
+		panic(server.NewHttpError(http.StatusBadRequest, "Bad request", "Weight must be positive"))
+	}
+
+	const qWeight = `REPLACE INTO Weights (Poll, User, Weight) VALUE (?, ?, ?)`
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		user := userByName(ctx, tx, query.Name)
+		_, err := tx.ExecContext(ctx, qWeight, poll.Id, user, query.Weight)
+		must(err)
+	})
+
+	response.SendJSON(ctx, "Ok")
+}
@@ -0,0 +1,253 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/server"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+type participantsChecker struct {
+	poll         uint32
+	participants int
+	blocked      int
+}
+
+func (self participantsChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	const (
+		qParticipants = `SELECT COUNT(DISTINCT User) FROM Participants WHERE Poll = ?`
+		qBlocked      = `SELECT COUNT(*) FROM Blocked WHERE Poll = ?`
+	)
+	var participants, blocked int
+	mustt(t, db.DB.QueryRow(qParticipants, self.poll).Scan(&participants))
+	mustt(t, db.DB.QueryRow(qBlocked, self.poll).Scan(&blocked))
+	if participants != self.participants {
+		t.Errorf("Wrong number of participants. Got %d. Expect %d.", participants, self.participants)
+	}
+	if blocked != self.blocked {
+		t.Errorf("Wrong number of blocked users. Got %d. Expect %d.", blocked, self.blocked)
+	}
+}
+
+func TestParticipantsHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	firstId := env.CreateUserWith("First")
+	secondId := env.CreateUserWith("Second")
+	pollId := env.CreatePoll("Participants", adminId, db.ElectorateLogged)
+	env.Vote(pollId, 0, firstId, 0)
+	env.Vote(pollId, 0, secondId, 1)
+	env.Must(t)
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "List",
+			Request: *makePollRequest(t, pollId, &adminId),
+			Checker: srvt.CheckJSON{Body: ParticipantsAnswer{
+				Logged: []ParticipantEntry{
+					{Name: dbt.UserNameWith("First"), Weight: 1},
+					{Name: dbt.UserNameWith("Second"), Weight: 1},
+				},
+				Blocked: []string{},
+			}},
+		},
+		&srvt.T{
+			Name:    "Not admin",
+			Request: *makePollRequest(t, pollId, &firstId),
+			Checker: srvt.CheckStatus{http.StatusNotFound},
+		},
+	}
+	srvt.RunFunc(t, tests, ParticipantsHandler)
+}
+
+func TestRemoveParticipantHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	firstId := env.CreateUserWith("First")
+	secondId := env.CreateUserWith("Second")
+	pollId := env.CreatePoll("Remove", adminId, db.ElectorateLogged)
+	env.Vote(pollId, 0, firstId, 0)
+	env.Vote(pollId, 0, secondId, 1)
+	nextPollId := env.CreatePoll("Next", adminId, db.ElectorateLogged)
+	env.Vote(nextPollId, 0, firstId, 0)
+	env.NextRound(nextPollId)
+	env.Must(t)
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name: "Remove",
+			Request: makePollPOSTRequest(t, pollId, &adminId,
+				ParticipantQuery{Name: dbt.UserNameWith("First")}),
+			Checker: participantsChecker{poll: pollId, participants: 1},
+		},
+		&srvt.T{
+			Name: "Not participant",
+			Request: makePollPOSTRequest(t, pollId, &adminId,
+				ParticipantQuery{Name: dbt.UserNameWith("First")}),
+			Checker: srvt.CheckError{Code: http.StatusNotFound, Body: "Not participant"},
+		},
+		&srvt.T{
+			Name: "Not admin",
+			Request: makePollPOSTRequest(t, pollId, &secondId,
+				ParticipantQuery{Name: dbt.UserNameWith("Second")}),
+			Checker: srvt.CheckStatus{http.StatusNotFound},
+		},
+		&srvt.T{
+			Name: "Too late",
+			Request: makePollPOSTRequest(t, nextPollId, &adminId,
+				ParticipantQuery{Name: dbt.UserNameWith("First")}),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Too late"},
+		},
+	}
+	srvt.Run(t, tests, RemoveParticipantHandler)
+}
+
+func TestBlockHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	userId := env.CreateUserWith("Blocked")
+	pollId := env.CreatePoll("Block", adminId, db.ElectorateLogged)
+	env.Vote(pollId, 0, userId, 0)
+	env.Must(t)
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name: "Block",
+			Request: makePollPOSTRequest(t, pollId, &adminId,
+				ParticipantQuery{Name: dbt.UserNameWith("Blocked")}),
+			Checker: participantsChecker{poll: pollId, blocked: 1},
+		},
+		&srvt.T{
+			Name: "Block admin",
+			Request: makePollPOSTRequest(t, pollId, &adminId,
+				ParticipantQuery{Name: dbt.UserNameWith("Admin")}),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		&srvt.T{
+			Name:    "Unknown user",
+			Request: makePollPOSTRequest(t, pollId, &adminId, ParticipantQuery{Name: "nobody"}),
+			Checker: srvt.CheckError{Code: http.StatusNotFound, Body: "Unknown user"},
+		},
+	}
+	srvt.Run(t, tests, BlockHandler)
+
+	tests = []srvt.Test{
+		&srvt.T{
+			Name:    "Blocked access",
+			Request: *makePollRequest(t, pollId, &userId),
+			Checker: srvt.CheckError{Code: http.StatusForbidden, Body: "Blocked"},
+		},
+	}
+	srvt.RunFunc(t, tests, PollHandler)
+}
+
+type weightChecker struct {
+	poll   uint32
+	user   uint32
+	weight uint32
+}
+
+func (self weightChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	const qWeight = `SELECT Weight FROM Weights WHERE Poll = ? AND User = ?`
+	var weight uint32
+	mustt(t, db.DB.QueryRow(qWeight, self.poll, self.user).Scan(&weight))
+	if weight != self.weight {
+		t.Errorf("Wrong weight. Got %d. Expect %d.", weight, self.weight)
+	}
+}
+
+func TestWeightHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	firstId := env.CreateUserWith("First")
+	secondId := env.CreateUserWith("Second")
+	pollId := env.CreatePoll("Weight", adminId, db.ElectorateLogged)
+	env.Vote(pollId, 0, firstId, 0)
+	env.Vote(pollId, 0, secondId, 1)
+	nextPollId := env.CreatePoll("Next", adminId, db.ElectorateLogged)
+	env.NextRound(nextPollId)
+	env.Must(t)
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name: "Set",
+			Request: makePollPOSTRequest(t, pollId, &adminId,
+				WeightQuery{Name: dbt.UserNameWith("First"), Weight: 3}),
+			Checker: weightChecker{poll: pollId, user: firstId, weight: 3},
+		},
+		&srvt.T{
+			Name: "Change",
+			Request: makePollPOSTRequest(t, pollId, &adminId,
+				WeightQuery{Name: dbt.UserNameWith("First"), Weight: 2}),
+			Checker: weightChecker{poll: pollId, user: firstId, weight: 2},
+		},
+		&srvt.T{
+			Name: "Zero",
+			Request: makePollPOSTRequest(t, pollId, &adminId,
+				WeightQuery{Name: dbt.UserNameWith("First"), Weight: 0}),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		&srvt.T{
+			Name: "Not admin",
+			Request: makePollPOSTRequest(t, pollId, &secondId,
+				WeightQuery{Name: dbt.UserNameWith("Second"), Weight: 5}),
+			Checker: srvt.CheckStatus{http.StatusNotFound},
+		},
+		&srvt.T{
+			Name: "Too late",
+			Request: makePollPOSTRequest(t, nextPollId, &adminId,
+				WeightQuery{Name: dbt.UserNameWith("First"), Weight: 2}),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Too late"},
+		},
+	}
+	srvt.RunFunc(t, tests, WeightHandler)
+
+	env.NextRound(pollId)
+	env.Must(t)
+	tests = []srvt.Test{
+		&srvt.T{
+			Name:    "Weighted count",
+			Request: *makePollRequest(t, pollId, &firstId),
+			Checker: srvt.CheckJSON{Body: CountInfoAnswer{Result: []CountInfoEntry{
+				{Alternative: PollAlternative{Id: 0, Name: "No", Cost: 1}, Count: 2, Score: 2},
+				{Alternative: PollAlternative{Id: 1, Name: "Yes", Cost: 1}, Count: 1, Score: 1},
+			}}},
+		},
+	}
+	srvt.RunFunc(t, tests, CountInfoHandler)
+}
@@ -34,9 +34,20 @@ type PollInfo struct {
 	Id           uint32
 	NbChoices    uint8
 	Active       bool
+	Terminated   bool
 	CurrentRound uint8
 	Public       bool
 
+	Type              uint8
+	Rule              uint8
+	MaxOutcomeCost    float64
+	MaxBallotCost     float64
+	BallotCostIsCount bool
+	Information       db.Information
+	InformationTop    uint8
+	RoundType         uint8
+	CurrentMover      uint32 // Zero if any participant can move.
+
 	Logged      bool
 	Participate bool
 }
@@ -48,7 +59,8 @@ func noPollError(reason string) server.HttpError {
 // checkPollAccess ensure that the user can access the poll.
 //
 // It checks that the request has a session and a valid poll segment. It also check that the user
-// participates in the poll. If she doesn't, poll.Participate is set to false.
+// participates in the poll, directly or by delegation. If she doesn't, poll.Participate is set to
+// false.
 func checkPollAccess(ctx context.Context, request *server.Request) (poll PollInfo, err error) {
 	// Check user
 	if request.SessionError != nil {
@@ -66,9 +78,13 @@ func checkPollAccess(ctx context.Context, request *server.Request) (poll PollInf
 	poll.Id = segment.Id
 
 	// Check poll
-	var salt uint32
+	var salt, admin uint32
 	var electorate db.Electorate
-	const qPoll = `SELECT Salt, Electorate, NbChoices, State = 'Active', CurrentRound FROM Polls WHERE Id = ?`
+	const qPoll = `
+	  SELECT Salt, Admin, Electorate, NbChoices, State = 'Active', State = 'Terminated', CurrentRound,
+	         Type, Rule, MaxOutcomeCost, MaxBallotCost, BallotCostIsCount, Information, InformationTop,
+	         RoundType, COALESCE(CurrentMover, 0)
+	    FROM Polls WHERE Id = ?`
 	rows, err := db.DB.QueryContext(ctx, qPoll, poll.Id)
 	defer rows.Close()
 	if err != nil {
@@ -78,7 +94,10 @@ func checkPollAccess(ctx context.Context, request *server.Request) (poll PollInf
 		err = noPollError("Id not found")
 		return
 	}
-	err = rows.Scan(&salt, &electorate, &poll.NbChoices, &poll.Active, &poll.CurrentRound)
+	err = rows.Scan(&salt, &admin, &electorate, &poll.NbChoices, &poll.Active, &poll.Terminated,
+		&poll.CurrentRound,
+		&poll.Type, &poll.Rule, &poll.MaxOutcomeCost, &poll.MaxBallotCost, &poll.BallotCostIsCount,
+		&poll.Information, &poll.InformationTop, &poll.RoundType, &poll.CurrentMover)
 	if err != nil {
 		return
 	}
@@ -107,11 +126,44 @@ func checkPollAccess(ctx context.Context, request *server.Request) (poll PollInf
 		}
 	}
 
+	const qInvited = `SELECT 1 FROM Invitations WHERE Poll = ? AND User = ?`
+	if electorate == db.ElectorateInvited && request.User.Id != admin {
+		var rows *sql.Rows
+		rows, err = db.DB.QueryContext(ctx, qInvited, poll.Id, request.User.Id)
+		defer rows.Close()
+		if err != nil {
+			return
+		}
+		if !rows.Next() {
+			err = server.NewHttpError(http.StatusForbidden, "Not invited", "Invitation-only poll")
+			return
+		}
+	}
+
+	// Check blocked
+	if request.User != nil {
+		const qBlocked = `SELECT 1 FROM Blocked WHERE Poll = ? AND User = ?`
+		var rows *sql.Rows
+		rows, err = db.DB.QueryContext(ctx, qBlocked, poll.Id, request.User.Id)
+		defer rows.Close()
+		if err != nil {
+			return
+		}
+		if rows.Next() {
+			err = server.NewHttpError(http.StatusForbidden, "Blocked", "Blocked by the administrator")
+			return
+		}
+	}
+
 	// Check participant
 	if request.User != nil {
-		const qParticipate = `SELECT 1 FROM Participants WHERE Poll = ? AND User = ?`
+		const qParticipate = `
+		  SELECT 1 FROM Participants WHERE Poll = ? AND User = ?
+		   UNION ALL
+		  SELECT 1 FROM Delegations WHERE Poll = ? AND User = ?`
 		var rows *sql.Rows
-		rows, err = db.DB.QueryContext(ctx, qParticipate, poll.Id, request.User.Id)
+		rows, err = db.DB.QueryContext(ctx, qParticipate, poll.Id, request.User.Id, poll.Id,
+			request.User.Id)
 		poll.Participate = rows.Next()
 		rows.Close()
 		if err != nil {
@@ -126,22 +178,102 @@ func checkPollAccess(ctx context.Context, request *server.Request) (poll PollInf
 	return
 }
 
+// adminPollInfo contains the information about a poll needed by administration handlers.
+type adminPollInfo struct {
+	salted.Segment
+	Electorate   db.Electorate
+	Terminated   bool
+	CurrentRound uint8
+}
+
+// checkPollAdmin verifies that the logged user is the administrator of the poll targeted by the
+// request.
+// Errors are sent by panic.
+func checkPollAdmin(ctx context.Context, request *server.Request) (poll adminPollInfo) {
+	if request.User == nil || !request.User.Logged {
+		must(request.SessionError)
+		panic(server.UnauthorizedHttpError("Unlogged user"))
+	}
+	segment, err := salted.FromRequest(request)
+	must(err)
+	poll.Segment = segment
+
+	const qPoll = `
+	  SELECT Electorate, State = 'Terminated', CurrentRound
+	    FROM Polls
+	   WHERE Id = ? AND Salt = ? AND Admin = ?`
+	err = db.DB.QueryRowContext(ctx, qPoll, segment.Id, segment.Salt, request.User.Id).
+		Scan(&poll.Electorate, &poll.Terminated, &poll.CurrentRound)
+	if err == sql.ErrNoRows {
+		panic(server.NewHttpError(http.StatusNotFound, "Not found", "No poll administrated by the user"))
+	}
+	must(err)
+	return
+}
+
+// BallotType returns the type of ballots currently accepted by the poll.
+// Acceptance set polls whose ballots contain at most one alternative are uninominal. Other
+// acceptance set polls are approval polls. Ranked polls have ranked ballots and grading polls have
+// grading ballots.
 func (pollInfo PollInfo) BallotType() BallotType {
-	// TODO really compute the value
 	if !pollInfo.Active {
 		return BallotTypeClosed
 	}
-	return BallotTypeUninominal
+	switch pollInfo.Type {
+	case db.PollTypeRanked:
+		return BallotTypeRanked
+	case db.PollTypeGrading:
+		return BallotTypeGrading
+	}
+	if pollInfo.BallotCostIsCount && pollInfo.MaxBallotCost < 2 {
+		return BallotTypeUninominal
+	}
+	return BallotTypeApproval
 }
 
+// InformationType returns the kind of information given about the previous round, according to
+// the field Information of the poll. Full counts are always given once the poll is terminated. For
+// grading polls, full counts are grade distributions.
 func (pollInfo PollInfo) InformationType() InformationType {
-	// TODO really compute the value
 	if pollInfo.CurrentRound == 0 {
 		return InformationTypeNoneYet
 	}
+	information := pollInfo.Information
+	if pollInfo.Terminated {
+		information = db.InformationCounts
+	}
+	switch information {
+	case db.InformationRanking:
+		return InformationTypeRanking
+	case db.InformationWinner:
+		return InformationTypeWinner
+	case db.InformationTop:
+		return InformationTypeTop
+	case db.InformationNone:
+		return InformationTypeHidden
+	}
+	if pollInfo.Type == db.PollTypeGrading {
+		return InformationTypeGrades
+	}
 	return InformationTypeCounts
 }
 
+// checkInformation returns an error if the information mode of the poll is not in allowed.
+// Information modes restrict only what is given between rounds, hence there is no error when the
+// poll is terminated.
+func (pollInfo PollInfo) checkInformation(allowed ...db.Information) error {
+	if pollInfo.Terminated {
+		return nil
+	}
+	for _, information := range allowed {
+		if pollInfo.Information == information {
+			return nil
+		}
+	}
+	return server.NewHttpError(http.StatusForbidden, "Hidden information",
+		"This information is not given for this poll")
+}
+
 /** PollHandler **/
 
 type BallotType uint8
@@ -149,6 +281,9 @@ type BallotType uint8
 const (
 	BallotTypeClosed BallotType = iota
 	BallotTypeUninominal
+	BallotTypeApproval
+	BallotTypeRanked
+	BallotTypeGrading
 )
 
 type InformationType uint8
@@ -156,6 +291,11 @@ type InformationType uint8
 const (
 	InformationTypeNoneYet InformationType = iota
 	InformationTypeCounts
+	InformationTypeGrades
+	InformationTypeRanking
+	InformationTypeWinner
+	InformationTypeTop
+	InformationTypeHidden
 )
 
 type PollAnswer struct {
@@ -175,6 +315,13 @@ type PollAnswer struct {
 	MaxNbRounds      uint8
 	Ballot           BallotType
 	Information      InformationType
+
+	// Sequential is true if participants move one at a time, and YourTurn is true if the user is
+	// the one allowed to move during the current round. Sealed is true if ballots cannot be changed
+	// once submitted.
+	Sequential bool
+	YourTurn   bool
+	Sealed     bool
 }
 
 // PollHandler provides general information about a poll.
@@ -187,7 +334,12 @@ func PollHandler(ctx context.Context, response server.Response, request *server.
 		Information:  pollInfo.InformationType(),
 		CurrentRound: pollInfo.CurrentRound,
 		Active:       pollInfo.Active,
+		Sequential: pollInfo.RoundType == db.RoundTypeSequential ||
+			pollInfo.RoundType == db.RoundTypeRandomSequential,
+		Sealed: pollInfo.RoundType == db.RoundTypeSynchronous,
 	}
+	answer.YourTurn = pollInfo.CurrentMover != 0 && request.User != nil &&
+		request.User.Id == pollInfo.CurrentMover
 
 	// Additional informations for display
 	const qSelect = `
@@ -307,3 +307,112 @@ func (self missingPollTest) GetRequest(t *testing.T) *srvt.Request {
 	req.Target = &segment
 	return req
 }
+
+func TestPollInfo_BallotType(t *testing.T) {
+	precheck(t)
+
+	acceptance := db.PollTypeAcceptanceSet
+	tests := []struct {
+		name   string
+		poll   PollInfo
+		expect BallotType
+	}{
+		{
+			name:   "Inactive",
+			poll:   PollInfo{Type: acceptance, Active: false, MaxBallotCost: 1, BallotCostIsCount: true},
+			expect: BallotTypeClosed,
+		},
+		{
+			name:   "Uninominal",
+			poll:   PollInfo{Type: acceptance, Active: true, MaxBallotCost: 1, BallotCostIsCount: true},
+			expect: BallotTypeUninominal,
+		},
+		{
+			name:   "Approval count",
+			poll:   PollInfo{Type: acceptance, Active: true, MaxBallotCost: 2, BallotCostIsCount: true},
+			expect: BallotTypeApproval,
+		},
+		{
+			name:   "Approval cost",
+			poll:   PollInfo{Type: acceptance, Active: true, MaxBallotCost: 1, BallotCostIsCount: false},
+			expect: BallotTypeApproval,
+		},
+		{
+			name:   "Ranked",
+			poll:   PollInfo{Type: db.PollTypeRanked, Active: true, MaxBallotCost: 1, BallotCostIsCount: true},
+			expect: BallotTypeRanked,
+		},
+		{
+			name:   "Grading",
+			poll:   PollInfo{Type: db.PollTypeGrading, Active: true, MaxBallotCost: 2, BallotCostIsCount: true},
+			expect: BallotTypeGrading,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.poll.BallotType(); got != tt.expect {
+				t.Errorf("Got %d. Expect %d.", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestPollInfo_InformationType(t *testing.T) {
+	precheck(t)
+
+	acceptance := db.PollTypeAcceptanceSet
+	tests := []struct {
+		name   string
+		poll   PollInfo
+		expect InformationType
+	}{
+		{
+			name:   "First round",
+			poll:   PollInfo{Type: acceptance, Information: db.InformationCounts},
+			expect: InformationTypeNoneYet,
+		},
+		{
+			name:   "Counts",
+			poll:   PollInfo{Type: acceptance, CurrentRound: 1, Information: db.InformationCounts},
+			expect: InformationTypeCounts,
+		},
+		{
+			name:   "Grades",
+			poll:   PollInfo{Type: db.PollTypeGrading, CurrentRound: 1, Information: db.InformationCounts},
+			expect: InformationTypeGrades,
+		},
+		{
+			name:   "Ranking",
+			poll:   PollInfo{Type: acceptance, CurrentRound: 1, Information: db.InformationRanking},
+			expect: InformationTypeRanking,
+		},
+		{
+			name:   "Winner",
+			poll:   PollInfo{Type: db.PollTypeGrading, CurrentRound: 1, Information: db.InformationWinner},
+			expect: InformationTypeWinner,
+		},
+		{
+			name:   "Top",
+			poll:   PollInfo{Type: acceptance, CurrentRound: 1, Information: db.InformationTop},
+			expect: InformationTypeTop,
+		},
+		{
+			name:   "Hidden",
+			poll:   PollInfo{Type: acceptance, CurrentRound: 1, Information: db.InformationNone},
+			expect: InformationTypeHidden,
+		},
+		{
+			name: "Terminated",
+			poll: PollInfo{Type: acceptance, CurrentRound: 1, Information: db.InformationNone,
+				Terminated: true},
+			expect: InformationTypeCounts,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.poll.InformationType(); got != tt.expect {
+				t.Errorf("Got %d. Expect %d.", got, tt.expect)
+			}
+		})
+	}
+}
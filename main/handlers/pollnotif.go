@@ -18,14 +18,17 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/JBoudou/Itero/main/services"
 	"github.com/JBoudou/Itero/mid/db"
 	"github.com/JBoudou/Itero/mid/salted"
 	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/slog"
 )
 
 //
@@ -78,50 +81,168 @@ func (self *pollNotifHandler) Handle(ctx context.Context, response server.Respon
 		return
 	}
 
-	const qCheck = `
-	  SELECT Title, Salt
-		  FROM Polls
-		 WHERE Id = ?
-		   AND (Admin = %[1]d OR Id IN ( SELECT Poll FROM Participants WHERE User = %[1]d ))`
-	stmt, err := db.DB.PrepareContext(ctx, fmt.Sprintf(qCheck, request.User.Id))
+	filter, err := newPollNotifFilter(ctx, request.User.Id)
 	must(err)
-	defer stmt.Close()
+	defer filter.Close()
 
 	answer := make([]PollNotifAnswerEntry, 0, len(baseList)/2)
 	for _, notif := range baseList {
 		if notif.Timestamp.Before(query.LastUpdate) {
 			continue
 		}
+		entry, ok, err := filter.Entry(ctx, notif)
+		must(err)
+		if ok {
+			answer = append(answer, entry)
+		}
+	}
+
+	response.SendJSON(ctx, answer)
+}
+
+// pollNotifFilter selects the notifications a user is concerned with, and converts them into
+// answer entries. A user is concerned by notifications about the polls she administrates or
+// participates in.
+type pollNotifFilter struct {
+	user uint32
+	stmt *sql.Stmt
+}
+
+func newPollNotifFilter(ctx context.Context, user uint32) (ret pollNotifFilter, err error) {
+	const qCheck = `
+	  SELECT Title, Salt
+		  FROM Polls
+		 WHERE Id = ?
+		   AND (Admin = %[1]d OR Id IN ( SELECT Poll FROM Participants WHERE User = %[1]d ))`
+	ret.user = user
+	ret.stmt, err = db.DB.PrepareContext(ctx, fmt.Sprintf(qCheck, user))
+	return
+}
+
+// Entry returns the answer entry for the notification, and whether the user is concerned with it.
+func (self pollNotifFilter) Entry(ctx context.Context, notif *services.PollNotification) (
+	entry PollNotifAnswerEntry, ok bool, err error) {
 
-		entry := PollNotifAnswerEntry{
-			Timestamp: notif.Timestamp,
-			Round:     notif.Round,
-			Action:    notif.Action,
+	if notif.User != 0 && notif.User != self.user {
+		return
+	}
+
+	entry = PollNotifAnswerEntry{
+		Timestamp: notif.Timestamp,
+		Round:     notif.Round,
+		Action:    notif.Action,
+	}
+
+	if notif.Participants != nil {
+		if member, found := notif.Participants[self.user]; !found || !member {
+			return
 		}
+		entry.Title = notif.Title
+		ok = true
+		return
+	}
 
-		if notif.Participants != nil {
-			if member, ok := notif.Participants[request.User.Id]; !ok || !member {
-				continue
-			}
-			entry.Title = notif.Title
+	rows, err := self.stmt.QueryContext(ctx, notif.Id)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		err = rows.Err()
+		return
+	}
+	segment := salted.Segment{Id: notif.Id}
+	if err = rows.Scan(&entry.Title, &segment.Salt); err != nil {
+		return
+	}
+	entry.Segment, err = segment.Encode()
+	ok = err == nil
+	return
+}
 
+func (self pollNotifFilter) Close() error {
+	return self.stmt.Close()
+}
+
+//
+// PollNotifStreamHandler
+//
+
+// pollNotifPingPeriod is the period of the comments sent on idle streams.
+const pollNotifPingPeriod = 30 * time.Second
+
+type pollNotifStreamHandler struct {
+	broker *services.PollNotifBroker
+}
+
+// PollNotifStreamHandler streams the notifications for the user as Server-Sent Events, as soon as
+// they are emitted. The data of each event is a PollNotifAnswerEntry. The id of each event is its
+// timestamp, allowing clients to resume using the Last-Event-ID header. Without that header, only
+// subsequent notifications are sent.
+func PollNotifStreamHandler(broker *services.PollNotifBroker) *pollNotifStreamHandler {
+	return &pollNotifStreamHandler{broker: broker}
+}
+
+func (self *pollNotifStreamHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
+	if request.User == nil {
+		if request.SessionError != nil {
+			must(request.SessionError)
 		} else {
-			rows, err := stmt.QueryContext(ctx, notif.Id)
-			must(err)
-			if !rows.Next() {
-				continue
-			}
-			segment := salted.Segment{Id: notif.Id}
-			err = rows.Scan(&entry.Title, &segment.Salt)
-			rows.Close()
-			must(err)
+			panic(server.UnauthorizedHttpError("Unlogged user"))
+		}
+	}
 
-			entry.Segment, err = segment.Encode()
-			must(err)
+	since := time.Now()
+	if lastId := request.Header("Last-Event-ID"); lastId != "" {
+		nano, err := strconv.ParseInt(lastId, 10, 64)
+		if err != nil {
+			panic(server.NewHttpError(http.StatusBadRequest, "Bad request", "Wrong Last-Event-ID"))
 		}
+		since = time.Unix(0, nano)
+	}
+
+	filter, err := newPollNotifFilter(ctx, request.User.Id)
+	must(err)
+	defer filter.Close()
 
-		answer = append(answer, entry)
+	missed, notifs, cancel := self.broker.Subscribe(since)
+	defer cancel()
+
+	stream, err := response.SendEventStream(ctx)
+	must(err)
+
+	send := func(notif *services.PollNotification) error {
+		entry, ok, err := filter.Entry(ctx, notif)
+		if err != nil || !ok {
+			return err
+		}
+		return stream.Send(ctx, strconv.FormatInt(notif.Timestamp.UnixNano(), 10), entry)
 	}
 
-	response.SendJSON(ctx, answer)
+	for _, notif := range missed {
+		if err := send(notif); err != nil {
+			slog.CtxLogf(ctx, "Stream error: %v", err)
+			return
+		}
+	}
+
+	ticker := time.NewTicker(pollNotifPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notif, ok := <-notifs:
+			if !ok {
+				return
+			}
+			err = send(notif)
+		case <-ticker.C:
+			err = stream.Ping(ctx)
+		}
+		if err != nil {
+			slog.CtxLogf(ctx, "Stream error: %v", err)
+			return
+		}
+	}
 }
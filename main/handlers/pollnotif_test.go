@@ -46,6 +46,7 @@ type pollNotifHandlerTest struct {
 	events    []func(uint32) events.Event
 	userKind  pollNotifUserKind
 	lastDelay time.Duration // Compute LastUpdate in query as Now - lastDelay. Default to one second.
+	turn      bool          // Send a TurnEvent for the user after events.
 	expect    []PollNotifAnswerEntry
 
 	dbEnv      dbt.Env
@@ -98,6 +99,11 @@ func (self *pollNotifHandlerTest) GetRequest(t *testing.T) *srvt.Request {
 	if self.userKind == pollNotifHandlerTestUserAdmin {
 		userId = &self.admnId
 	}
+	if self.turn {
+		turn := services.TurnEvent{Poll: self.pollId, Round: 1, User: *userId}
+		mustt(t, self.evtManager.Send(turn))
+		time.Sleep(time.Millisecond)
+	}
 
 	// Body
 	if self.lastDelay == 0 {
@@ -159,6 +165,9 @@ func TestPollNotifHandler(t *testing.T) {
 	create := func(id uint32) events.Event { return services.StartPollEvent{Poll: id} }
 	next := func(id uint32) events.Event { return services.NextRoundEvent{Poll: id} }
 	term := func(id uint32) events.Event { return services.ClosePollEvent{Poll: id} }
+	otherTurn := func(id uint32) events.Event {
+		return services.TurnEvent{Poll: id, Round: 1, User: 1 << 31}
+	}
 
 	tests := []srvt.Test{
 		&pollNotifHandlerTest{
@@ -201,6 +210,18 @@ func TestPollNotifHandler(t *testing.T) {
 			userKind: pollNotifHandlerTestUserPart,
 			expect:   []PollNotifAnswerEntry{{Action: services.PollNotifTerm}},
 		},
+		&pollNotifHandlerTest{
+			name:     "Part Turn",
+			userKind: pollNotifHandlerTestUserPart,
+			turn:     true,
+			expect:   []PollNotifAnswerEntry{{Action: services.PollNotifTurn, Round: 1}},
+		},
+		&pollNotifHandlerTest{
+			name:     "Part Other Turn",
+			events:   []func(uint32) events.Event{otherTurn},
+			userKind: pollNotifHandlerTestUserPart,
+			expect:   []PollNotifAnswerEntry{},
+		},
 		&pollNotifHandlerTest{
 			name:     "Alien Create",
 			events:   []func(uint32) events.Event{create},
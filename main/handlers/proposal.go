@@ -0,0 +1,249 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/slog"
+)
+
+// ProposalQuery is the body of propose requests. A zero Cost means 1.
+type ProposalQuery struct {
+	Name string
+	Cost float64
+}
+
+// ProposalDecisionQuery is the body of approve, merge and reject requests. Alternative is only used
+// by merge requests.
+type ProposalDecisionQuery struct {
+	Id          uint32
+	Alternative uint8
+}
+
+// ProposalEntry describes a proposal. Alternative is only meaningful when Pending is false. It is
+// then the id of the alternative the proposal has been approved as, or merged into.
+type ProposalEntry struct {
+	Id          uint32
+	Name        string
+	Cost        float64
+	Author      string
+	Pending     bool
+	Alternative uint8
+}
+
+// ProposeHandler adds a proposal for a new alternative. The query is a ProposalQuery. Only logged
+// users can propose, and only while the poll is waiting to start and accepts proposals.
+func ProposeHandler(ctx context.Context, response server.Response, request *server.Request) {
+	if request.User == nil || !request.User.Logged {
+		must(request.SessionError)
+		panic(server.UnauthorizedHttpError("Unlogged user"))
+	}
+	must(request.CheckPOST(ctx))
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+
+	var query ProposalQuery
+	must(request.UnmarshalJSONBody(&query))
+	if len(query.Name) < 1 || len(query.Name) > 128 {
+		panic(server.NewHttpError(http.StatusBadRequest, "Bad request", "Wrong name length"))
+	}
+	if query.Cost == 0 {
+		query.Cost = 1
+	}
+
+	const (
+		qPoll = `
+		  SELECT State = 'Waiting' AND Proposals, MaxOutcomeCost, MaxBallotCost, BallotCostIsCount
+		    FROM Polls
+		   WHERE Id = ?
+		     FOR UPDATE`
+		qAlternative = `SELECT 1 FROM Alternatives WHERE Poll = ? AND Name = ?`
+		qInsert      = `INSERT INTO Proposals (Poll, User, Name, Cost) VALUE (?, ?, ?, ?)`
+	)
+
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		var open, costIsCount bool
+		var maxOutcomeCost, maxBallotCost float64
+		must(tx.QueryRowContext(ctx, qPoll, pollInfo.Id).
+			Scan(&open, &maxOutcomeCost, &maxBallotCost, &costIsCount))
+		if !open {
+			panic(server.NewHttpError(http.StatusLocked, "Not proposable", "Proposals are closed"))
+		}
+		if query.Cost < 0 || query.Cost > maxOutcomeCost || (!costIsCount && query.Cost > maxBallotCost) {
+			panic(server.NewHttpError(http.StatusBadRequest, "Bad request", "Wrong cost"))
+		}
+
+		rows, err := tx.QueryContext(ctx, qAlternative, pollInfo.Id, query.Name)
+		must(err)
+		exists := rows.Next()
+		must(rows.Close())
+		if exists {
+			panic(server.NewHttpError(http.StatusConflict, "Duplicate", "Alternative already exists"))
+		}
+
+		_, err = tx.ExecContext(ctx, qInsert, pollInfo.Id, request.User.Id, query.Name, query.Cost)
+		if sqlError, ok := err.(*mysql.MySQLError); ok && sqlError.Number == 1062 {
+			panic(server.NewHttpError(http.StatusConflict, "Duplicate", "Alternative already proposed"))
+		}
+		must(err)
+	})
+
+	response.SendJSON(ctx, "Ok")
+}
+
+// ProposalsHandler lists the proposals made for a poll, by order of submission.
+func ProposalsHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+
+	const qList = `
+	  SELECT p.Id, p.Name, p.Cost, u.Name, p.Alternative IS NULL, COALESCE(p.Alternative, 0)
+	    FROM Proposals AS p, Users AS u
+	   WHERE p.Poll = ? AND u.Id = p.User
+	   ORDER BY p.Id ASC`
+	rows, err := db.DB.QueryContext(ctx, qList, pollInfo.Id)
+	must(err)
+	defer rows.Close()
+
+	answer := []ProposalEntry{}
+	for rows.Next() {
+		var entry ProposalEntry
+		must(rows.Scan(&entry.Id, &entry.Name, &entry.Cost, &entry.Author, &entry.Pending,
+			&entry.Alternative))
+		answer = append(answer, entry)
+	}
+	must(rows.Err())
+
+	response.SendJSON(ctx, answer)
+}
+
+// decideProposal checks that the logged user administrates the poll, that the poll is still waiting
+// and that the proposal in the query is pending. It then calls decide inside a transaction, with
+// the number of alternatives of the poll.
+// Errors are sent by panic.
+func decideProposal(ctx context.Context, request *server.Request,
+	decide func(tx *sql.Tx, poll uint32, query ProposalDecisionQuery, nbChoices uint8)) {
+
+	must(request.CheckPOST(ctx))
+	poll := checkPollAdmin(ctx, request)
+
+	var query ProposalDecisionQuery
+	must(request.UnmarshalJSONBody(&query))
+
+	const (
+		qPoll     = `SELECT State = 'Waiting', NbChoices FROM Polls WHERE Id = ? FOR UPDATE`
+		qProposal = `SELECT 1 FROM Proposals WHERE Id = ? AND Poll = ? AND Alternative IS NULL`
+	)
+
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		var waiting bool
+		var nbChoices uint8
+		must(tx.QueryRowContext(ctx, qPoll, poll.Id).Scan(&waiting, &nbChoices))
+		if !waiting {
+			panic(server.NewHttpError(http.StatusLocked, "Too late", "The poll already started"))
+		}
+
+		rows, err := tx.QueryContext(ctx, qProposal, query.Id, poll.Id)
+		must(err)
+		found := rows.Next()
+		must(rows.Close())
+		if !found {
+			panic(server.NewHttpError(http.StatusNotFound, "Not found", "No such pending proposal"))
+		}
+
+		decide(tx, poll.Id, query, nbChoices)
+	})
+}
+
+// ApproveProposalHandler adds a pending proposal as a new alternative of the poll. The query is a
+// ProposalDecisionQuery. Only the administrator can approve proposals, and only before the poll
+// starts.
+func ApproveProposalHandler(ctx context.Context, response server.Response, request *server.Request) {
+	const (
+		// MaxBallotCost is assigned before NbChoices, hence it is compared to the old NbChoices.
+		qPoll = `
+		  UPDATE Polls
+		     SET MaxBallotCost = IF(BallotCostIsCount AND MaxBallotCost = NbChoices,
+		                            NbChoices + 1, MaxBallotCost),
+		         NbChoices = NbChoices + 1
+		   WHERE Id = ?`
+		qAlternative = `
+		  INSERT INTO Alternatives (Poll, Id, Name, Cost)
+		  SELECT Poll, ?, Name, Cost FROM Proposals WHERE Id = ?`
+		qProposal = `UPDATE Proposals SET Alternative = ? WHERE Id = ?`
+	)
+
+	decideProposal(ctx, request, func(tx *sql.Tx, poll uint32, query ProposalDecisionQuery,
+		nbChoices uint8) {
+
+		if nbChoices == 255 {
+			panic(server.NewHttpError(http.StatusConflict, "Too many alternatives", "Limit reached"))
+		}
+		_, err := tx.ExecContext(ctx, qPoll, poll)
+		must(err)
+		_, err = tx.ExecContext(ctx, qAlternative, nbChoices, query.Id)
+		if sqlError, ok := err.(*mysql.MySQLError); ok && sqlError.Number == 1062 {
+			panic(server.NewHttpError(http.StatusConflict, "Duplicate", "Alternative already exists"))
+		}
+		must(err)
+		_, err = tx.ExecContext(ctx, qProposal, nbChoices, query.Id)
+		must(err)
+	})
+
+	response.SendJSON(ctx, "Ok")
+}
+
+// MergeProposalHandler marks a pending proposal as merged into an existing alternative. The query
+// is a ProposalDecisionQuery. Only the administrator can merge proposals, and only before the poll
+// starts.
+func MergeProposalHandler(ctx context.Context, response server.Response, request *server.Request) {
+	const qProposal = `UPDATE Proposals SET Alternative = ? WHERE Id = ?`
+
+	decideProposal(ctx, request, func(tx *sql.Tx, poll uint32, query ProposalDecisionQuery,
+		nbChoices uint8) {
+
+		if query.Alternative >= nbChoices {
+			panic(server.NewHttpError(http.StatusBadRequest, "Bad request", "No such alternative"))
+		}
+		_, err := tx.ExecContext(ctx, qProposal, query.Alternative, query.Id)
+		must(err)
+	})
+
+	response.SendJSON(ctx, "Ok")
+}
+
+// RejectProposalHandler deletes a pending proposal. The query is a ProposalDecisionQuery. Only the
+// administrator can reject proposals, and only before the poll starts.
+func RejectProposalHandler(ctx context.Context, response server.Response, request *server.Request) {
+	const qDelete = `DELETE FROM Proposals WHERE Id = ?`
+
+	decideProposal(ctx, request, func(tx *sql.Tx, poll uint32, query ProposalDecisionQuery,
+		nbChoices uint8) {
+
+		_, err := tx.ExecContext(ctx, qDelete, query.Id)
+		must(err)
+	})
+
+	response.SendJSON(ctx, "Ok")
+}
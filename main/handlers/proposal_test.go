@@ -0,0 +1,222 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/server"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+type proposalChecker struct {
+	poll        uint32
+	proposal    uint32
+	pending     bool
+	alternative uint8
+	nbChoices   uint8
+}
+
+func (self proposalChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	const (
+		qProposal = `SELECT Alternative IS NULL, COALESCE(Alternative, 0) FROM Proposals WHERE Id = ?`
+		qPoll     = `SELECT NbChoices FROM Polls WHERE Id = ?`
+	)
+	var pending bool
+	var alternative, nbChoices uint8
+	mustt(t, db.DB.QueryRow(qProposal, self.proposal).Scan(&pending, &alternative))
+	mustt(t, db.DB.QueryRow(qPoll, self.poll).Scan(&nbChoices))
+	if pending != self.pending {
+		t.Errorf("Wrong pending. Got %t. Expect %t.", pending, self.pending)
+	}
+	if alternative != self.alternative {
+		t.Errorf("Wrong alternative. Got %d. Expect %d.", alternative, self.alternative)
+	}
+	if nbChoices != self.nbChoices {
+		t.Errorf("Wrong NbChoices. Got %d. Expect %d.", nbChoices, self.nbChoices)
+	}
+}
+
+// proposalsChecker checks the list of proposals, ignoring their ids.
+type proposalsChecker []ProposalEntry
+
+func (self proposalsChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	var answer []ProposalEntry
+	mustt(t, json.NewDecoder(response.Body).Decode(&answer))
+	for i := range answer {
+		answer[i].Id = 0
+	}
+	if !reflect.DeepEqual(answer, []ProposalEntry(self)) {
+		t.Errorf("Wrong proposals. Got %v. Expect %v.", answer, self)
+	}
+}
+
+func TestProposeHandler(t *testing.T) {
+	precheck(t)
+
+	const qWaiting = `UPDATE Polls SET State = 'Waiting', Start = ?, Proposals = TRUE WHERE Id = ?`
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	userId := env.CreateUserWith("User")
+	pollId := env.CreatePoll("Proposals", adminId, db.ElectorateAll)
+	env.QuietExec(qWaiting, time.Now().Add(time.Hour), pollId)
+	activeId := env.CreatePoll("Active", adminId, db.ElectorateAll)
+	env.Must(t)
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Propose",
+			Request: makePollPOSTRequest(t, pollId, &userId, ProposalQuery{Name: "Maybe"}),
+			Checker: srvt.CheckStatus{http.StatusOK},
+		},
+		&srvt.T{
+			Name:    "Already proposed",
+			Request: makePollPOSTRequest(t, pollId, &adminId, ProposalQuery{Name: "Maybe"}),
+			Checker: srvt.CheckError{Code: http.StatusConflict, Body: "Duplicate"},
+		},
+		&srvt.T{
+			Name:    "Existing alternative",
+			Request: makePollPOSTRequest(t, pollId, &userId, ProposalQuery{Name: "Yes"}),
+			Checker: srvt.CheckError{Code: http.StatusConflict, Body: "Duplicate"},
+		},
+		&srvt.T{
+			Name:    "Wrong cost",
+			Request: makePollPOSTRequest(t, pollId, &userId, ProposalQuery{Name: "Expensive", Cost: 2}),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		&srvt.T{
+			Name:    "Not proposable",
+			Request: makePollPOSTRequest(t, activeId, &userId, ProposalQuery{Name: "Maybe"}),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Not proposable"},
+		},
+	}
+	srvt.RunFunc(t, tests, ProposeHandler)
+
+	tests = []srvt.Test{
+		&srvt.T{
+			Name:    "List",
+			Request: *makePollRequest(t, pollId, &adminId),
+			Checker: proposalsChecker{{
+				Name:    "Maybe",
+				Cost:    1,
+				Author:  dbt.UserNameWith("User"),
+				Pending: true,
+			}},
+		},
+	}
+	srvt.RunFunc(t, tests, ProposalsHandler)
+}
+
+func TestDecideProposal(t *testing.T) {
+	precheck(t)
+
+	const (
+		qWaiting  = `UPDATE Polls SET State = 'Waiting', Start = ?, Proposals = TRUE WHERE Id = ?`
+		qProposal = `INSERT INTO Proposals (Poll, User, Name) VALUE (?, ?, ?)`
+	)
+
+	var env dbt.Env
+	defer env.Close()
+	adminId := env.CreateUserWith("Admin")
+	userId := env.CreateUserWith("User")
+	pollId := env.CreatePoll("Proposals", adminId, db.ElectorateAll)
+	env.QuietExec(qWaiting, time.Now().Add(time.Hour), pollId)
+	env.Must(t)
+
+	propose := func(name string) uint32 {
+		result, err := db.DB.Exec(qProposal, pollId, userId, name)
+		mustt(t, err)
+		id, err := db.IdFromResult(result)
+		mustt(t, err)
+		return id
+	}
+	approved := propose("Maybe")
+	merged := propose("Yeah")
+	rejected := propose("Never")
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Not admin",
+			Request: makePollPOSTRequest(t, pollId, &userId, ProposalDecisionQuery{Id: approved}),
+			Checker: srvt.CheckStatus{http.StatusNotFound},
+		},
+		&srvt.T{
+			Name:    "Approve",
+			Request: makePollPOSTRequest(t, pollId, &adminId, ProposalDecisionQuery{Id: approved}),
+			Checker: proposalChecker{poll: pollId, proposal: approved, alternative: 2, nbChoices: 3},
+		},
+		&srvt.T{
+			Name:    "Approve again",
+			Request: makePollPOSTRequest(t, pollId, &adminId, ProposalDecisionQuery{Id: approved}),
+			Checker: srvt.CheckError{Code: http.StatusNotFound, Body: "Not found"},
+		},
+	}
+	srvt.RunFunc(t, tests, ApproveProposalHandler)
+
+	tests = []srvt.Test{
+		&srvt.T{
+			Name: "Wrong alternative",
+			Request: makePollPOSTRequest(t, pollId, &adminId,
+				ProposalDecisionQuery{Id: merged, Alternative: 3}),
+			Checker: srvt.CheckStatus{http.StatusBadRequest},
+		},
+		&srvt.T{
+			Name: "Merge",
+			Request: makePollPOSTRequest(t, pollId, &adminId,
+				ProposalDecisionQuery{Id: merged, Alternative: 1}),
+			Checker: proposalChecker{poll: pollId, proposal: merged, alternative: 1, nbChoices: 3},
+		},
+	}
+	srvt.RunFunc(t, tests, MergeProposalHandler)
+
+	tests = []srvt.Test{
+		&srvt.T{
+			Name:    "Reject",
+			Request: makePollPOSTRequest(t, pollId, &adminId, ProposalDecisionQuery{Id: rejected}),
+			Checker: srvt.CheckStatus{http.StatusOK},
+		},
+		&srvt.T{
+			Name:    "Reject again",
+			Request: makePollPOSTRequest(t, pollId, &adminId, ProposalDecisionQuery{Id: rejected}),
+			Checker: srvt.CheckError{Code: http.StatusNotFound, Body: "Not found"},
+		},
+	}
+	srvt.RunFunc(t, tests, RejectProposalHandler)
+
+	env.QuietExec(`UPDATE Polls SET State = 'Active' WHERE Id = ?`, pollId)
+	env.Must(t)
+	tests = []srvt.Test{
+		&srvt.T{
+			Name:    "Too late",
+			Request: makePollPOSTRequest(t, pollId, &adminId, ProposalDecisionQuery{Id: merged}),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Too late"},
+		},
+	}
+	srvt.RunFunc(t, tests, RejectProposalHandler)
+}
@@ -0,0 +1,49 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/mid/unlogged"
+)
+
+// RankedBallotAnswer represents the response sent by RankedBallotHandler.
+// Ballots are lists of alternatives ordered from the most preferred to the least preferred.
+type RankedBallotAnswer struct {
+	listBallots
+	Alternatives []PollAlternative
+}
+
+// RankedBallotHandler sends the previous ranking (if any), the current one (if any) and all the
+// alternatives.
+func RankedBallotHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+
+	if request.User == nil {
+		var user server.User
+		user, err = unlogged.FromAddr(ctx, request.RemoteAddr())
+		must(err)
+		request.User = &user
+	}
+
+	answer := RankedBallotAnswer{listBallots: getListBallots(ctx, pollInfo, request.User.Id)}
+	allAlternatives(ctx, pollInfo, &answer.Alternatives)
+	response.SendJSON(ctx, answer)
+}
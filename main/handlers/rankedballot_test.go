@@ -0,0 +1,84 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+func TestRankedBallotHandler(t *testing.T) {
+	precheck(t)
+
+	const qRank = `
+	  INSERT INTO Ballots (Poll, Round, User, Alternative, Rank) VALUE (?, ?, ?, ?, ?)`
+
+	env := new(dbt.Env)
+	defer env.Close()
+
+	userId := env.CreateUser()
+	pollId := env.CreatePollWith("RankedBallotHandler", userId, db.ElectorateAll,
+		[]string{"A", "B", "C"})
+	env.QuietExec(`UPDATE Polls SET Type = ? WHERE Id = ?`, db.PollTypeRanked, pollId)
+	mustt(t, env.Error)
+
+	request := *makePollRequest(t, pollId, &userId)
+
+	alternatives := []PollAlternative{
+		{Id: 0, Name: "A", Cost: 1.},
+		{Id: 1, Name: "B", Cost: 1.},
+		{Id: 2, Name: "C", Cost: 1.},
+	}
+	answer := func(previous, current AlternativeSet) *RankedBallotAnswer {
+		return &RankedBallotAnswer{
+			listBallots:  listBallots{Previous: previous, Current: current},
+			Alternatives: alternatives,
+		}
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "No Ballot",
+			Request: request,
+			Checker: srvt.CheckJSON{Body: answer(nil, nil)},
+		},
+		&srvt.T{
+			Name: "Current ballot",
+			Update: func(t *testing.T) {
+				env.Vote(pollId, 0, userId, 1)
+				env.QuietExec(qRank, pollId, 0, userId, 2, 3)
+				env.QuietExec(qRank, pollId, 0, userId, 0, 2)
+				env.Must(t)
+			},
+			Request: request,
+			Checker: srvt.CheckJSON{Body: answer(nil, AlternativeSet{1, 0, 2})},
+		},
+		&srvt.T{
+			Name: "Previous ballot",
+			Update: func(t *testing.T) {
+				env.NextRound(pollId)
+				env.Must(t)
+			},
+			Request: request,
+			Checker: srvt.CheckJSON{Body: answer(AlternativeSet{1, 0, 2}, nil)},
+		},
+	}
+	srvt.RunFunc(t, tests, RankedBallotHandler)
+}
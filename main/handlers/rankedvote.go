@@ -0,0 +1,88 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/JBoudou/Itero/main/services"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/events"
+)
+
+// RankedVoteQuery is the body of requests sent to RankedVoteHandler.
+// Ranking lists alternatives from the most preferred to the least preferred. Alternatives not in
+// Ranking are unranked. An empty ranking is a blank vote.
+type RankedVoteQuery struct {
+	Ranking AlternativeSet
+	Round   uint8
+}
+
+type rankedVoteHandler struct {
+	evtManager events.Manager
+}
+
+// RankedVoteHandler votes for a full or partial ranking of the alternatives. Blank votes are also
+// permitted.
+func RankedVoteHandler(evtManager events.Manager) rankedVoteHandler {
+	return rankedVoteHandler{evtManager: evtManager}
+}
+
+func (self rankedVoteHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, sendUnloggedCookie := checkVoteRequest(ctx, request, BallotTypeRanked)
+
+	// Get query
+	var voteQuery RankedVoteQuery
+	if err := request.UnmarshalJSONBody(&voteQuery); err != nil {
+		err = server.WrapError(http.StatusBadRequest, "Wrong request", err)
+		response.SendError(ctx, err)
+		return
+	}
+	if err := checkVoteRound(pollInfo, voteQuery.Round); err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+	if err := checkAlternatives(pollInfo, voteQuery.Ranking); err != nil {
+		response.SendError(ctx, err)
+		return
+	}
+
+	const qInsertBallot = `
+	  INSERT INTO Ballots (User, Poll, Alternative, Round, Rank) VALUE (?, ?, ?, ?, ?)`
+
+	var insert func(tx *sql.Tx)
+	if len(voteQuery.Ranking) > 0 {
+		insert = func(tx *sql.Tx) {
+			stmt, err := tx.PrepareContext(ctx, qInsertBallot)
+			must(err)
+			defer stmt.Close()
+			for i, alt := range voteQuery.Ranking {
+				_, err = stmt.ExecContext(ctx, request.User.Id, pollInfo.Id, alt, pollInfo.CurrentRound, i+1)
+				must(err)
+			}
+		}
+	}
+	replaceBallot(ctx, pollInfo, request.User.Id, insert)
+
+	if sendUnloggedCookie {
+		response.SendUnloggedId(ctx, *request.User, request)
+	}
+	response.SendJSON(ctx, "Ok")
+	self.evtManager.Send(services.VoteEvent{pollInfo.Id})
+}
@@ -0,0 +1,133 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/server"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+type rankedVoteChecker struct {
+	poll  uint32
+	user  uint32
+	round uint8
+}
+
+func (self *rankedVoteChecker) Check(t *testing.T, response *http.Response, request *server.Request) {
+	srvt.CheckStatus{http.StatusOK}.Check(t, response, request)
+
+	var query RankedVoteQuery
+	mustt(t, request.UnmarshalJSONBody(&query))
+
+	const qCheck = `
+		SELECT Alternative, Rank
+		  FROM Ballots
+		 WHERE Poll = ? AND User = ? AND Round = ?
+		 ORDER BY Rank ASC`
+
+	rows, err := db.DB.Query(qCheck, self.poll, self.user, self.round)
+	mustt(t, err)
+	defer rows.Close()
+	got := AlternativeSet{}
+	for expectRank := 1; rows.Next(); expectRank++ {
+		var alt uint8
+		var rank int
+		mustt(t, rows.Scan(&alt, &rank))
+		if rank != expectRank {
+			t.Errorf("Wrong rank. Got %d. Expect %d.", rank, expectRank)
+		}
+		got = append(got, alt)
+	}
+	expect := query.Ranking
+	if expect == nil {
+		expect = AlternativeSet{}
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got %v. Expect %v.", got, expect)
+	}
+}
+
+func TestRankedVoteHandler(t *testing.T) {
+	precheck(t)
+
+	var env dbt.Env
+	defer env.Close()
+	userId := env.CreateUser()
+	pollId := env.CreatePollWith("Test", userId, db.ElectorateLogged, []string{"A", "B", "C"})
+	env.QuietExec(`UPDATE Polls SET Type = ? WHERE Id = ?`, db.PollTypeRanked, pollId)
+	uniPollId := env.CreatePoll("Uninominal", userId, db.ElectorateLogged)
+	env.Must(t)
+
+	makeRequest := func(pollId uint32, vote RankedVoteQuery) srvt.Request {
+		req := *makePollRequest(t, pollId, &userId)
+		b, err := json.Marshal(vote)
+		mustt(t, err)
+		req.Body = string(b)
+		req.Method = "POST"
+		return req
+	}
+
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Full ranking",
+			Request: makeRequest(pollId, RankedVoteQuery{Ranking: AlternativeSet{2, 0, 1}}),
+			Checker: &rankedVoteChecker{poll: pollId, user: userId, round: 0},
+		},
+		&srvt.T{
+			Name:    "Partial ranking",
+			Request: makeRequest(pollId, RankedVoteQuery{Ranking: AlternativeSet{1, 2}}),
+			Checker: &rankedVoteChecker{poll: pollId, user: userId, round: 0},
+		},
+		&srvt.T{
+			Name:    "Blank vote",
+			Request: makeRequest(pollId, RankedVoteQuery{}),
+			Checker: &rankedVoteChecker{poll: pollId, user: userId, round: 0},
+		},
+		&srvt.T{
+			Name:    "Duplicated alternative",
+			Request: makeRequest(pollId, RankedVoteQuery{Ranking: AlternativeSet{0, 1, 0}}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Invalid ballot"},
+		},
+		&srvt.T{
+			Name:    "Unknown alternative",
+			Request: makeRequest(pollId, RankedVoteQuery{Ranking: AlternativeSet{3}}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Invalid ballot"},
+		},
+		&srvt.T{
+			Name: "Next round",
+			Update: func(t *testing.T) {
+				env.NextRound(pollId)
+				env.Must(t)
+			},
+			Request: makeRequest(pollId, RankedVoteQuery{Ranking: AlternativeSet{0}}),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Next round"},
+		},
+		&srvt.T{
+			Name:    "Uninominal poll",
+			Request: makeRequest(uniPollId, RankedVoteQuery{Ranking: AlternativeSet{0}}),
+			Checker: srvt.CheckError{Code: http.StatusBadRequest, Body: "Wrong poll"},
+		},
+	}
+	srvt.Run(t, tests, RankedVoteHandler)
+}
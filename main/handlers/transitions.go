@@ -0,0 +1,231 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+)
+
+// TransitionsInfoAnswer is the response sent by TransitionsInfoHandler.
+//
+// States are numbered as follows: state i < len(Alternatives) is the state of participants whose
+// first choice is Alternatives[i], state len(Alternatives) is the state of participants with a blank
+// ballot, and state len(Alternatives)+1 is the state of participants who did not vote.
+// Matrix[i][j] is the number of participants in state i during round From and in state j during
+// round To. Counts less than the minimal cell size of the poll are replaced by -1, together with
+// complementary cells (see suppressCells).
+type TransitionsInfoAnswer struct {
+	From         uint8
+	To           uint8
+	Alternatives []PollAlternative
+	Matrix       [][]int
+}
+
+// roundStates returns the state, as described in TransitionsInfoAnswer, of each participant of the
+// given round. Participants of the poll that are not in the returned map did not vote during that
+// round. If votes are reported, the last ballot of each participant up to that round is used, as by
+// db.LoadProfile. The state of ballots with more than one alternative is the alternative with the
+// best rank, and the one with the smallest identifier among them. Errors are sent by panic.
+func roundStates(ctx context.Context, pollInfo PollInfo, round uint8, reportVote bool) map[uint32]int {
+	const (
+		qAbstain = `
+		  SELECT p.User, b.Alternative
+		    FROM Participants AS p
+		    LEFT JOIN Ballots AS b ON (p.User, p.Poll, p.Round) = (b.User, b.Poll, b.Round)
+		   WHERE p.Poll = ? AND p.Round = ?
+		   ORDER BY p.User, b.Rank, b.Alternative`
+		qReport = `
+		  SELECT p.User, b.Alternative
+		    FROM (
+		           SELECT User, Poll, MAX(Round) AS Round
+		             FROM Participants
+		            WHERE Poll = ? AND Round <= ?
+		            GROUP BY User, Poll
+		         ) AS p
+		    LEFT JOIN Ballots AS b ON (p.User, p.Poll, p.Round) = (b.User, b.Poll, b.Round)
+		   WHERE NOT EXISTS (
+		           SELECT 1 FROM Delegations AS d
+		            WHERE d.Poll = p.Poll AND d.User = p.User AND d.Round > p.Round AND d.Round <= ?
+		         )
+		   ORDER BY p.User, b.Rank, b.Alternative`
+	)
+
+	var rows *sql.Rows
+	var err error
+	if reportVote {
+		rows, err = db.DB.QueryContext(ctx, qReport, pollInfo.Id, round, round)
+	} else {
+		rows, err = db.DB.QueryContext(ctx, qAbstain, pollInfo.Id, round)
+	}
+	must(err)
+	defer rows.Close()
+
+	blank := int(pollInfo.NbChoices)
+	states := make(map[uint32]int)
+	for rows.Next() {
+		var user uint32
+		var alternative sql.NullInt32
+		must(rows.Scan(&user, &alternative))
+		if _, found := states[user]; found {
+			continue
+		}
+		if alternative.Valid {
+			states[user] = int(alternative.Int32)
+		} else {
+			states[user] = blank
+		}
+	}
+	must(rows.Err())
+	return states
+}
+
+// suppressCells replaces by -1 the positive counts of the matrix that are less than minCellSize.
+// Since the totals of each round are known, hiding a single cell in a line or a column would not
+// protect it. Hence additional cells are hidden until each line and each column has either no
+// hidden cell or at least two. The additional cells are preferably taken in the lines and
+// columns already having hidden cells, then with the smallest counts.
+func suppressCells(matrix [][]int, minCellSize int) {
+	size := len(matrix)
+	hidden := make([][]bool, size)
+	lineHidden := make([]int, size)
+	columnHidden := make([]int, size)
+	hide := func(i, j int) {
+		hidden[i][j] = true
+		lineHidden[i] += 1
+		columnHidden[j] += 1
+	}
+	for i, line := range matrix {
+		hidden[i] = make([]bool, size)
+		for j, count := range line {
+			if count > 0 && count < minCellSize {
+				hide(i, j)
+			}
+		}
+	}
+
+	// better tells whether cell (i, j) is a better complementary cell than cell (k, l), given the
+	// number of hidden cells in the other dimension of each cell.
+	better := func(i, j, other, k, l, bestOther int) bool {
+		if (other > 0) != (bestOther > 0) {
+			return other > 0
+		}
+		return matrix[i][j] < matrix[k][l]
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for i := 0; i < size; i++ {
+			if lineHidden[i] != 1 {
+				continue
+			}
+			best := -1
+			for j := 0; j < size; j++ {
+				if !hidden[i][j] &&
+					(best < 0 || better(i, j, columnHidden[j], i, best, columnHidden[best])) {
+					best = j
+				}
+			}
+			hide(i, best)
+			changed = true
+		}
+		for j := 0; j < size; j++ {
+			if columnHidden[j] != 1 {
+				continue
+			}
+			best := -1
+			for i := 0; i < size; i++ {
+				if !hidden[i][j] &&
+					(best < 0 || better(i, j, lineHidden[i], best, j, lineHidden[best])) {
+					best = i
+				}
+			}
+			hide(best, j)
+			changed = true
+		}
+	}
+
+	for i, line := range matrix {
+		for j := range line {
+			if hidden[i][j] {
+				line[j] = -1
+			}
+		}
+	}
+}
+
+// TransitionsInfoHandler sends the transition matrix between two consecutive rounds of a
+// terminated poll. The first round of the pair is taken from the path, and defaults to the
+// penultimate round. Since all information is given about terminated polls, the matrix is sent
+// whatever the information mode of the poll. It is not available for grading polls.
+func TransitionsInfoHandler(ctx context.Context, response server.Response, request *server.Request) {
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+	if !pollInfo.Terminated {
+		panic(server.NewHttpError(http.StatusLocked, "Active poll", "Poll is not terminated"))
+	}
+	if pollInfo.Type == db.PollTypeGrading {
+		panic(server.NewHttpError(http.StatusBadRequest, "Wrong poll", "No transition for grading polls"))
+	}
+
+	from := getPollRoundFromRequest(request, pollInfo.CurrentRound-2)
+	if pollInfo.CurrentRound < 2 || from >= pollInfo.CurrentRound-1 {
+		panic(server.NewHttpError(http.StatusBadRequest, "Protocol error", "No transition for this round"))
+	}
+
+	const (
+		qPoll  = `SELECT MinCellSize, ReportVote FROM Polls WHERE Id = ?`
+		qUsers = `SELECT DISTINCT User FROM Participants WHERE Poll = ?`
+	)
+	var minCellSize int
+	var reportVote bool
+	must(db.DB.QueryRowContext(ctx, qPoll, pollInfo.Id).Scan(&minCellSize, &reportVote))
+
+	answer := TransitionsInfoAnswer{From: from, To: from + 1}
+	allAlternatives(ctx, pollInfo, &answer.Alternatives)
+	nbStates := int(pollInfo.NbChoices) + 2
+	answer.Matrix = make([][]int, nbStates)
+	for i := range answer.Matrix {
+		answer.Matrix[i] = make([]int, nbStates)
+	}
+
+	fromStates := roundStates(ctx, pollInfo, answer.From, reportVote)
+	toStates := roundStates(ctx, pollInfo, answer.To, reportVote)
+	stateOf := func(states map[uint32]int, user uint32) int {
+		if state, found := states[user]; found {
+			return state
+		}
+		return nbStates - 1
+	}
+
+	rows, err := db.DB.QueryContext(ctx, qUsers, pollInfo.Id)
+	must(err)
+	defer rows.Close()
+	for rows.Next() {
+		var user uint32
+		must(rows.Scan(&user))
+		answer.Matrix[stateOf(fromStates, user)][stateOf(toStates, user)] += 1
+	}
+	must(rows.Err())
+
+	suppressCells(answer.Matrix, minCellSize)
+	response.SendJSON(ctx, answer)
+}
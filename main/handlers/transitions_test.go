@@ -0,0 +1,118 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	srvt "github.com/JBoudou/Itero/mid/server/servertest"
+)
+
+func TestTransitionsInfoHandler(t *testing.T) {
+	precheck(t)
+
+	const (
+		qParticipate = `INSERT INTO Participants (Poll, User, Round) VALUE (?, ?, ?)`
+		qTerminate   = `UPDATE Polls SET State = 'Terminated', MinCellSize = ? WHERE Id = ?`
+		qReportVote  = `UPDATE Polls SET ReportVote = TRUE WHERE Id = ?`
+	)
+
+	var env dbt.Env
+	defer env.Close()
+	create, userId := createInformationPolls(&env)
+	createPoll := func(minCellSize uint8, terminate, reportVote bool) uint32 {
+		pollId := create(db.InformationCounts, 3)
+		if reportVote {
+			env.QuietExec(qReportVote, pollId)
+		}
+		users := informationPollUsers(t, pollId)
+		env.Vote(pollId, 1, users[0], 2)
+		env.QuietExec(qParticipate, pollId, users[1], 1)
+		env.NextRound(pollId)
+		if terminate {
+			env.QuietExec(qTerminate, minCellSize, pollId)
+		}
+		return pollId
+	}
+	plainPoll := createPoll(0, true, false)
+	hiddenPoll := createPoll(2, true, false)
+	reportPoll := createPoll(0, true, true)
+	activePoll := createPoll(0, false, false)
+	env.Must(t)
+
+	// From round 0 to round 1, first user moved from B to C, second one from B to blank, and the
+	// last one from C to abstention, or stayed on C when votes are reported.
+	tests := []srvt.Test{
+		&srvt.T{
+			Name:    "Plain",
+			Request: *makePollRequest(t, plainPoll, &userId),
+			Checker: srvt.CheckJSON{Body: TransitionsInfoAnswer{
+				From:         0,
+				To:           1,
+				Alternatives: informationTestAlternatives,
+				Matrix: [][]int{
+					{0, 0, 0, 0, 0},
+					{0, 0, 1, 1, 0},
+					{0, 0, 0, 0, 1},
+					{0, 0, 0, 0, 0},
+					{0, 0, 0, 0, 0},
+				},
+			}},
+		},
+		&srvt.T{
+			Name:    "Min cell size",
+			Request: *makePollRequest(t, hiddenPoll, &userId),
+			Checker: srvt.CheckJSON{Body: TransitionsInfoAnswer{
+				From:         0,
+				To:           1,
+				Alternatives: informationTestAlternatives,
+				Matrix: [][]int{
+					{0, 0, 0, 0, 0},
+					{0, 0, -1, -1, -1},
+					{0, 0, -1, -1, -1},
+					{0, 0, 0, 0, 0},
+					{0, 0, 0, 0, 0},
+				},
+			}},
+		},
+		&srvt.T{
+			Name:    "Report vote",
+			Request: *makePollRequest(t, reportPoll, &userId),
+			Checker: srvt.CheckJSON{Body: TransitionsInfoAnswer{
+				From:         0,
+				To:           1,
+				Alternatives: informationTestAlternatives,
+				Matrix: [][]int{
+					{0, 0, 0, 0, 0},
+					{0, 0, 1, 1, 0},
+					{0, 0, 1, 0, 0},
+					{0, 0, 0, 0, 0},
+					{0, 0, 0, 0, 0},
+				},
+			}},
+		},
+		&srvt.T{
+			Name:    "Active",
+			Request: *makePollRequest(t, activePoll, &userId),
+			Checker: srvt.CheckError{Code: http.StatusLocked, Body: "Active poll"},
+		},
+	}
+	srvt.RunFunc(t, tests, TransitionsInfoHandler)
+}
@@ -22,11 +22,8 @@ import (
 	"net/http"
 
 	"github.com/JBoudou/Itero/main/services"
-	"github.com/JBoudou/Itero/mid/db"
 	"github.com/JBoudou/Itero/mid/server"
-	"github.com/JBoudou/Itero/mid/unlogged"
 	"github.com/JBoudou/Itero/pkg/events"
-	"github.com/JBoudou/Itero/pkg/slog"
 )
 
 type UninominalVoteQuery struct {
@@ -45,33 +42,7 @@ func UninominalVoteHandler(evtManager events.Manager) uninominalVoteHandler {
 }
 
 func (self uninominalVoteHandler) Handle(ctx context.Context, response server.Response, request *server.Request) {
-
-	// Verifications
-	if err := request.CheckPOST(ctx); err != nil {
-		response.SendError(ctx, err)
-		return
-	}
-	pollInfo, err := checkPollAccess(ctx, request)
-	must(err)
-	if !pollInfo.Active {
-		err = server.NewHttpError(http.StatusLocked, "Inactive poll", "Poll is currently not active")
-		response.SendError(ctx, err)
-		return
-	}
-	if pollInfo.BallotType() != BallotTypeUninominal {
-		err = server.NewHttpError(http.StatusBadRequest, "Wrong poll", "Poll is not uninominal")
-		response.SendError(ctx, err)
-		return
-	}
-
-	// Unlogged
-	sendUnloggedCookie := request.User == nil
-	if sendUnloggedCookie {
-		var user server.User
-		user, err = unlogged.FromAddr(ctx, request.RemoteAddr())
-		must(err)
-		request.User = &user
-	}
+	pollInfo, sendUnloggedCookie := checkVoteRequest(ctx, request, BallotTypeUninominal)
 
 	// Get query
 	var voteQuery UninominalVoteQuery
@@ -80,54 +51,22 @@ func (self uninominalVoteHandler) Handle(ctx context.Context, response server.Re
 		response.SendError(ctx, err)
 		return
 	}
-
-	// Check round before DB operations.
-	// We should check after the DB operations, but it is more difficul and the difference is
-	// insignificant.
-	if voteQuery.Round != pollInfo.CurrentRound {
-		if voteQuery.Round+1 == pollInfo.CurrentRound {
-			err = server.NewHttpError(http.StatusLocked, "Next round",
-				"Round may have changed while the user voted")
-		} else {
-			err = server.NewHttpError(http.StatusBadRequest, "Wrong round",
-				"Round is neither current nor previous")
-		}
+	if err := checkVoteRound(pollInfo, voteQuery.Round); err != nil {
 		response.SendError(ctx, err)
 		return
 	}
 
-	const (
-		qDeleteBallot      = `DELETE FROM Ballots WHERE User = ? AND Poll = ? AND Round = ?`
-		qInsertBallot      = `INSERT INTO Ballots (User, Poll, Alternative, Round) VALUE (?, ?, ?, ?)`
-		qLastRound         = `SELECT 1 FROM Participants WHERE User = ? AND Poll = ? AND Round = ?`
-		qInsertParticipant = `INSERT INTO Participants (User, Poll, Round) VALUE (?, ?, ?)`
-	)
+	const qInsertBallot = `INSERT INTO Ballots (User, Poll, Alternative, Round) VALUE (?, ?, ?, ?)`
 
-	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
-		var result sql.Result
-		result, err = tx.ExecContext(ctx, qDeleteBallot, request.User.Id, pollInfo.Id, pollInfo.CurrentRound)
-		must(err)
-
-		// Insert a row in Participants if needed
-		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
-			var rows *sql.Rows
-			rows, err = tx.QueryContext(ctx, qLastRound, request.User.Id, pollInfo.Id, pollInfo.CurrentRound)
-			must(err)
-			if !rows.Next() {
-				_, err = tx.ExecContext(ctx, qInsertParticipant, request.User.Id, pollInfo.Id,
-					pollInfo.CurrentRound)
-				must(err)
-			}
-			must(rows.Close())
-		}
-
-		// Add the ballot
-		if !voteQuery.Blank {
-			_, err = tx.ExecContext(ctx, qInsertBallot, request.User.Id, pollInfo.Id, voteQuery.Alternative,
+	var insert func(tx *sql.Tx)
+	if !voteQuery.Blank {
+		insert = func(tx *sql.Tx) {
+			_, err := tx.ExecContext(ctx, qInsertBallot, request.User.Id, pollInfo.Id, voteQuery.Alternative,
 				pollInfo.CurrentRound)
 			must(err)
 		}
-	})
+	}
+	replaceBallot(ctx, pollInfo, request.User.Id, insert)
 
 	if sendUnloggedCookie {
 		response.SendUnloggedId(ctx, *request.User, request)
@@ -162,6 +162,22 @@ func TestUninominalVoteHandler(t *testing.T) {
 			Request: makeRequest(&userId, pollId, UninominalVoteQuery{Blank: true, Round: 2}),
 			Checker: srvt.CheckStatus{Code: http.StatusBadRequest},
 		},
+		&srvt.T{
+			Name: "Synchronous vote",
+			Update: func(t *testing.T) {
+				const qSynchronous = `UPDATE Polls SET RoundType = ? WHERE Id = ?`
+				env.QuietExec(qSynchronous, db.RoundTypeSynchronous, pollId)
+				env.NextRound(pollId)
+				env.Must(t)
+			},
+			Request: makeRequest(&userId, pollId, UninominalVoteQuery{Alternative: 0, Round: 2}),
+			Checker: &voteChecker{poll: pollId, user: userId, round: 2},
+		},
+		&srvt.T{
+			Name:    "Synchronous change",
+			Request: makeRequest(&userId, pollId, UninominalVoteQuery{Alternative: 1, Round: 2}),
+			Checker: srvt.CheckError{Code: http.StatusConflict, Body: "Sealed ballot"},
+		},
 		&srvt.T{
 			Name: "Inactive",
 			Update: func(t *testing.T) {
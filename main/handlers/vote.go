@@ -0,0 +1,149 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/mid/unlogged"
+	"github.com/JBoudou/Itero/pkg/slog"
+)
+
+// checkVoteRequest performs the verifications common to all vote handlers.
+//
+// It checks that the request is a POST, that the user can access the poll, and that the poll is
+// active and accepts ballots of the given type. If the request has no user, an unlogged user is
+// attached to it and sendUnloggedCookie is true. For sequential polls, it also checks that the user
+// is the current mover. Errors are sent by panic.
+func checkVoteRequest(ctx context.Context, request *server.Request,
+	ballotType BallotType) (pollInfo PollInfo, sendUnloggedCookie bool) {
+
+	must(request.CheckPOST(ctx))
+	pollInfo, err := checkPollAccess(ctx, request)
+	must(err)
+	if !pollInfo.Active {
+		panic(server.NewHttpError(http.StatusLocked, "Inactive poll", "Poll is currently not active"))
+	}
+	if pollInfo.BallotType() != ballotType {
+		panic(server.NewHttpError(http.StatusBadRequest, "Wrong poll", "Wrong ballot type"))
+	}
+
+	sendUnloggedCookie = request.User == nil
+	if sendUnloggedCookie {
+		var user server.User
+		user, err = unlogged.FromAddr(ctx, request.RemoteAddr())
+		must(err)
+		request.User = &user
+	}
+
+	if pollInfo.CurrentMover != 0 && pollInfo.CurrentMover != request.User.Id {
+		panic(server.NewHttpError(http.StatusLocked, "Not your turn",
+			"Another participant is moving"))
+	}
+	return
+}
+
+// checkVoteRound ensures that round is the current round of the poll.
+//
+// The round should be checked after the DB operations, but it is more difficult and the difference
+// is insignificant.
+func checkVoteRound(pollInfo PollInfo, round uint8) error {
+	if round == pollInfo.CurrentRound {
+		return nil
+	}
+	if round+1 == pollInfo.CurrentRound {
+		return server.NewHttpError(http.StatusLocked, "Next round",
+			"Round may have changed while the user voted")
+	}
+	return server.NewHttpError(http.StatusBadRequest, "Wrong round",
+		"Round is neither current nor previous")
+}
+
+// checkAlternatives verifies that each alternative in list exists in the poll and appears only
+// once.
+func checkAlternatives(poll PollInfo, list AlternativeSet) error {
+	seen := make([]bool, poll.NbChoices)
+	for _, alt := range list {
+		if alt >= poll.NbChoices {
+			return server.NewHttpError(http.StatusBadRequest, "Invalid ballot", "Unknown alternative")
+		}
+		if seen[alt] {
+			return server.NewHttpError(http.StatusBadRequest, "Invalid ballot", "Duplicated alternative")
+		}
+		seen[alt] = true
+	}
+	return nil
+}
+
+// replaceBallot replaces the ballot of the user for the current round of the poll.
+//
+// The previous ballot is deleted and the user is added to the participants of the round if needed.
+// Then insert is called to add the new ballot. Nothing is inserted if insert is nil, resulting in a
+// blank ballot. All these operations are done in a single transaction. Ballots of grading polls are
+// stored in table GradeBallots instead of table Ballots. Any delegation of the user for the current
+// round is cancelled.
+//
+// Ballots of synchronous polls are sealed: if the user already participates in the current round,
+// a Conflict error is sent by panic and nothing is changed.
+func replaceBallot(ctx context.Context, pollInfo PollInfo, user uint32, insert func(tx *sql.Tx)) {
+	qDeleteBallot := `DELETE FROM Ballots WHERE User = ? AND Poll = ? AND Round = ?`
+	if pollInfo.Type == db.PollTypeGrading {
+		qDeleteBallot = `DELETE FROM GradeBallots WHERE User = ? AND Poll = ? AND Round = ?`
+	}
+	const (
+		qLastRound         = `SELECT 1 FROM Participants WHERE User = ? AND Poll = ? AND Round = ?`
+		qInsertParticipant = `INSERT INTO Participants (User, Poll, Round) VALUE (?, ?, ?)`
+		qDeleteDelegation  = `DELETE FROM Delegations WHERE User = ? AND Poll = ? AND Round = ?`
+	)
+
+	db.RepeatDeadlocked(slog.CtxLoadLogger(ctx), ctx, nil, func(tx *sql.Tx) {
+		if pollInfo.RoundType == db.RoundTypeSynchronous {
+			rows, err := tx.QueryContext(ctx, qLastRound, user, pollInfo.Id, pollInfo.CurrentRound)
+			must(err)
+			voted := rows.Next()
+			must(rows.Close())
+			if voted {
+				panic(server.NewHttpError(http.StatusConflict, "Sealed ballot",
+					"The ballot for this round has already been submitted"))
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, qDeleteDelegation, user, pollInfo.Id, pollInfo.CurrentRound)
+		must(err)
+		result, err := tx.ExecContext(ctx, qDeleteBallot, user, pollInfo.Id, pollInfo.CurrentRound)
+		must(err)
+
+		// Insert a row in Participants if needed
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			rows, err := tx.QueryContext(ctx, qLastRound, user, pollInfo.Id, pollInfo.CurrentRound)
+			must(err)
+			if !rows.Next() {
+				_, err = tx.ExecContext(ctx, qInsertParticipant, user, pollInfo.Id, pollInfo.CurrentRound)
+				must(err)
+			}
+			must(rows.Close())
+		}
+
+		if insert != nil {
+			insert(tx)
+		}
+	})
+}
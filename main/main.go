@@ -56,6 +56,9 @@ func main() {
 	StartService(NextRoundService)
 	StartService(ClosePollService)
 	StartService(EmailService)
+	StartService(InboxService)
+	StartService(ReminderService)
+	StartService(DigestService)
 
 	// Handlers
 	StartHandler("/a/login", LoginHandler)
@@ -65,16 +68,54 @@ func main() {
 	StartHandler("/a/poll/", PollHandler)
 	StartHandler("/a/ballot/uninominal/", UninominalBallotHandler, server.Compress)
 	StartHandler("/a/vote/uninominal/", UninominalVoteHandler)
+	StartHandler("/a/ballot/approval/", ApprovalBallotHandler, server.Compress)
+	StartHandler("/a/vote/approval/", ApprovalVoteHandler)
+	StartHandler("/a/ballot/ranked/", RankedBallotHandler, server.Compress)
+	StartHandler("/a/vote/ranked/", RankedVoteHandler)
+	StartHandler("/a/ballot/grading/", GradingBallotHandler, server.Compress)
+	StartHandler("/a/vote/grading/", GradingVoteHandler)
+	StartHandler("/a/delegate/", DelegateHandler)
 	StartHandler("/a/info/count/", CountInfoHandler, server.Compress)
+	StartHandler("/a/info/budget/", BudgetInfoHandler, server.Compress)
+	StartHandler("/a/info/grades/", GradesInfoHandler, server.Compress)
+	StartHandler("/a/info/ranking/", RankingInfoHandler, server.Compress)
+	StartHandler("/a/info/winner/", WinnerInfoHandler)
+	StartHandler("/a/info/top/", TopInfoHandler, server.Compress)
+	StartHandler("/a/info/history/", HistoryInfoHandler, server.Compress)
+	StartHandler("/a/info/transitions/", TransitionsInfoHandler, server.Compress)
 	StartHandler("/a/create", CreateHandler)
+	StartHandler("/a/clone/", CloneHandler)
 	StartHandler("/a/delete/", DeleteHandler)
+	StartHandler("/a/edit/", EditHandler)
 	StartHandler("/a/pollnotif", PollNotifHandler, server.Compress)
+	StartHandler("/a/pollnotif/stream", PollNotifStreamHandler)
+	StartHandler("/a/inbox/", InboxHandler, server.Compress)
+	StartHandler("/a/inbox/count", InboxCountHandler)
+	StartHandler("/a/inbox/read", InboxReadHandler)
+	StartHandler("/a/emailprefs", EmailPrefsHandler)
+	StartHandler("/a/emailprefs/set", SetEmailPrefsHandler)
 	StartHandler("/a/config", ConfigHandler)
 	StartHandler("/a/confirm/", ConfirmHandler)
 	StartHandler("/a/reverify", ReverifyHandler)
 	StartHandler("/a/forgot", ForgotHandler)
 	StartHandler("/a/passwd/", PasswdHandler)
 	StartHandler("/a/launch/", LaunchHandler)
+	StartHandler("/a/invite/", InviteHandler)
+	StartHandler("/a/revoke/", RevokeHandler)
+	StartHandler("/a/invitations/", InvitationsHandler, server.Compress)
+	StartHandler("/a/redeem/", RedeemHandler)
+	StartHandler("/a/participants/", ParticipantsHandler, server.Compress)
+	StartHandler("/a/participants/remove/", RemoveParticipantHandler)
+	StartHandler("/a/participants/block/", BlockHandler)
+	StartHandler("/a/participants/weight/", WeightHandler)
+	StartHandler("/a/comments/", CommentsHandler, server.Compress)
+	StartHandler("/a/comment/", CommentHandler)
+	StartHandler("/a/comment/delete/", DeleteCommentHandler)
+	StartHandler("/a/propose/", ProposeHandler)
+	StartHandler("/a/proposals/", ProposalsHandler, server.Compress)
+	StartHandler("/a/proposals/approve/", ApproveProposalHandler)
+	StartHandler("/a/proposals/merge/", MergeProposalHandler)
+	StartHandler("/a/proposals/reject/", RejectProposalHandler)
 	StartHandler("/p/", ShortURLHandler)
 
 	var logger slog.Leveled
@@ -17,6 +17,9 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"reflect"
 	"time"
 
 	"github.com/JBoudou/Itero/mid/db"
@@ -31,7 +34,8 @@ type closePollService struct {
 }
 
 // ClosePollService is the factory for the service that terminates polls when their last round is
-// over.
+// over. Polls that opted for it are also terminated when their ballots converged. See converged for
+// details.
 func ClosePollService(evtManager events.Manager, log slog.StackedLeveled) *closePollService {
 	return &closePollService{
 		logger:     log.With("ClosePoll"),
@@ -40,25 +44,141 @@ func ClosePollService(evtManager events.Manager, log slog.StackedLeveled) *close
 }
 
 func (self *closePollService) ProcessOne(id uint32) error {
-	const qUpdate = `
+	const (
+		qUpdate = `
 	  UPDATE Polls SET State = 'Terminated'
 	   WHERE Id = ? AND State = 'Active'
 	     AND ( CurrentRound >= MaxNbRounds
 	           OR (CurrentRound >= MinNbRounds AND Deadline <= CURRENT_TIMESTAMP) )`
+		qConverged = `UPDATE Polls SET State = 'Terminated' WHERE Id = ? AND State = 'Active'`
+	)
 
-	if err := service.SQLProcessOne(qUpdate, id); err != nil {
+	err := service.SQLProcessOne(qUpdate, id)
+	if errors.Is(err, service.NothingToDoYet) {
+		var stop bool
+		if stop, err = self.converged(id); err == nil {
+			if stop {
+				err = service.SQLProcessOne(qConverged, id)
+			} else {
+				err = service.NothingToDoYet
+			}
+		}
+	}
+	if err != nil {
 		return err
 	}
 	return self.evtManager.Send(ClosePollEvent{id})
 }
 
+// converged tells whether an active poll must be terminated because its ballots converged.
+// This happens only after MinNbRounds rounds, when either StopStableProfile is true and the
+// profiles of the last two rounds are identical, or StopStableWinner is positive and the winner of
+// the last StopStableWinner + 1 rounds is the same. For sequential polls, the profile must have
+// been stable during a full cycle of movers, that is as many rounds as there are participants.
+// Profiles are loaded as for counts, hence reported votes are taken into account.
+func (self *closePollService) converged(id uint32) (bool, error) {
+	const qPoll = `
+	  SELECT p.Type, p.Rule, p.RoundType, p.CurrentRound, p.MinNbRounds, p.StopStableProfile,
+	         p.StopStableWinner, (SELECT COUNT(DISTINCT User) FROM Participants WHERE Poll = p.Id)
+	    FROM Polls AS p WHERE p.Id = ? AND p.State = 'Active'`
+
+	rows, err := db.DB.Query(qPoll, id)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return false, rows.Err()
+	}
+	var pollType, rule, roundType, round, minNbRounds, stableWinner uint8
+	var stableProfile bool
+	var nbParticipants int
+	err = rows.Scan(&pollType, &rule, &roundType, &round, &minNbRounds, &stableProfile,
+		&stableWinner, &nbParticipants)
+	if err != nil {
+		return false, err
+	}
+	rows.Close()
+	if (!stableProfile && stableWinner == 0) || round < minNbRounds {
+		return false, nil
+	}
+
+	// Number of consecutive rounds the profile must be unchanged.
+	stableRounds := 1
+	if isSequential(roundType) && nbParticipants > 1 {
+		stableRounds = nbParticipants
+	}
+
+	// Profiles and winners of previous rounds, from the last one.
+	nbRounds := int(stableWinner) + 1
+	if stableProfile && nbRounds < stableRounds+1 {
+		nbRounds = stableRounds + 1
+	}
+	if nbRounds > int(round) {
+		nbRounds = int(round)
+	}
+	profiles := make([]interface{}, nbRounds)
+	winners := make([]uint8, nbRounds)
+	for i := range profiles {
+		profiles[i], winners[i], err = roundOutcome(id, pollType, rule, round-uint8(i)-1)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if stableProfile && nbRounds > stableRounds {
+		stable := true
+		for _, profile := range profiles[1 : stableRounds+1] {
+			if !reflect.DeepEqual(profiles[0], profile) {
+				stable = false
+				break
+			}
+		}
+		if stable {
+			return true, nil
+		}
+	}
+	if stableWinner == 0 || nbRounds <= int(stableWinner) {
+		return false, nil
+	}
+	for _, winner := range winners[1:] {
+		if winner != winners[0] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// roundOutcome loads the profile of a round of a poll, and computes its winner.
+func roundOutcome(id uint32, pollType, rule, round uint8) (profile interface{}, winner uint8,
+	err error) {
+
+	ctx := context.Background()
+	if pollType == db.PollTypeGrading {
+		gradeProfile, err := db.LoadGradeProfile(ctx, id, round)
+		if err != nil {
+			return nil, 0, err
+		}
+		return gradeProfile, db.GradeRuleFromDB(rule).Outcome(gradeProfile)[0].Alternative, nil
+	}
+	rankProfile, err := db.LoadProfile(ctx, id, round)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rankProfile, db.RuleFromDB(rule).Outcome(rankProfile)[0].Alternative, nil
+}
+
+// CheckAll lists the polls to terminate, together with the active polls that opted for convergence,
+// since their ballots may have converged while the service was not running.
 func (self *closePollService) CheckAll() service.Iterator {
 	const qSelectClose = `
 		  SELECT Id, COALESCE(LEAST(Deadline, CURRENT_TIMESTAMP), CURRENT_TIMESTAMP)
 		    FROM Polls
 		  WHERE State = 'Active'
 		    AND ( CurrentRound >= MaxNbRounds
-		          OR (CurrentRound >= MinNbRounds AND Deadline <= CURRENT_TIMESTAMP) )`
+		          OR (CurrentRound >= MinNbRounds AND Deadline <= CURRENT_TIMESTAMP)
+		          OR (CurrentRound >= MinNbRounds AND CurrentRound > 0
+		              AND (StopStableProfile OR StopStableWinner > 0)) )`
 	return service.SQLCheckAll(qSelectClose)
 }
 
@@ -76,7 +196,13 @@ func (self *closePollService) CheckOne(id uint32) time.Time {
 		return time.Time{}
 	}
 	if !rows.Next() {
-		return time.Time{}
+		stop, err := self.converged(id)
+		if err != nil {
+			self.Logger().Errorf("CheckOne convergence error: %v", err)
+		}
+		if !stop {
+			return time.Time{}
+		}
 	}
 	return time.Now()
 }
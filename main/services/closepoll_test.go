@@ -222,3 +222,132 @@ func TestClosePollService_Events(t *testing.T) {
 	}
 	checkEventSchedule(t, tests, ClosePollService)
 }
+
+// convergence //
+
+func TestClosePollService_Convergence(t *testing.T) {
+	t.Parallel()
+
+	const (
+		qSetStop = `
+		  UPDATE Polls SET StopStableProfile = ?, StopStableWinner = ?, RoundType = ? WHERE Id = ?`
+		qIsActive = `SELECT State = 'Active' FROM Polls WHERE Id = ?`
+	)
+
+	// Ballots are given for each round, as the alternative chosen by each user.
+	tests := []struct {
+		name          string
+		stableProfile bool
+		stableWinner  uint8
+		sequential    bool
+		ballots       [][]uint8
+		expectClosed  bool
+	}{
+		{
+			name:          "Stable profile",
+			stableProfile: true,
+			ballots:       [][]uint8{{1, 0, 1}, {1, 0, 1}},
+			expectClosed:  true,
+		},
+		{
+			name:          "Changed profile",
+			stableProfile: true,
+			ballots:       [][]uint8{{1, 0, 1}, {1, 1, 1}},
+			expectClosed:  false,
+		},
+		{
+			name:          "Sequential stable one round",
+			stableProfile: true,
+			sequential:    true,
+			ballots:       [][]uint8{{1, 0, 1}, {1, 0, 1}},
+			expectClosed:  false,
+		},
+		{
+			name:          "Sequential stable cycle",
+			stableProfile: true,
+			sequential:    true,
+			ballots:       [][]uint8{{1, 0, 1}, {1, 0, 1}, {1, 0, 1}, {1, 0, 1}},
+			expectClosed:  true,
+		},
+		{
+			name:          "Sequential changed in cycle",
+			stableProfile: true,
+			sequential:    true,
+			ballots:       [][]uint8{{1, 0, 1}, {1, 1, 1}, {1, 1, 1}, {1, 1, 1}},
+			expectClosed:  false,
+		},
+		{
+			name:         "Not opted in",
+			ballots:      [][]uint8{{1, 0, 1}, {1, 0, 1}},
+			expectClosed: false,
+		},
+		{
+			name:         "Stable winner",
+			stableWinner: 2,
+			ballots:      [][]uint8{{1, 0, 1}, {1, 1, 1}, {1, 1, 0}},
+			expectClosed: true,
+		},
+		{
+			name:         "Changed winner",
+			stableWinner: 2,
+			ballots:      [][]uint8{{0, 0, 1}, {1, 1, 1}, {1, 1, 0}},
+			expectClosed: false,
+		},
+		{
+			name:         "Too few rounds",
+			stableWinner: 2,
+			ballots:      [][]uint8{{1, 0, 1}, {1, 1, 1}},
+			expectClosed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			env := new(dbt.Env)
+			defer env.Close()
+			var users [3]uint32
+			for i := range users {
+				users[i] = env.CreateUserWith(t.Name() + fmt.Sprint(i))
+			}
+			pollId := env.CreatePoll("TestClosePollService_Convergence", users[0], db.ElectorateAll)
+			roundType := db.RoundTypeSynchronous
+			if tt.sequential {
+				roundType = db.RoundTypeSequential
+			}
+			env.QuietExec(qSetStop, tt.stableProfile, tt.stableWinner, roundType, pollId)
+			for round, ballots := range tt.ballots {
+				for i, alternative := range ballots {
+					env.Vote(pollId, uint8(round), users[i], alternative)
+				}
+				env.NextRound(pollId)
+			}
+			env.Must(t)
+
+			var svc service.Service
+			mustt(t, root.IoC.Inject(ClosePollService, &svc))
+			if got := svc.CheckOne(pollId); got.IsZero() == tt.expectClosed {
+				t.Errorf("Wrong CheckOne result %v.", got)
+			}
+			iterator := svc.CheckAll()
+			listed := idDateIteratorHasId(t, iterator, pollId)
+			iterator.Close()
+			if tt.expectClosed && !listed {
+				t.Errorf("Poll not listed by CheckAll.")
+			}
+			err := svc.ProcessOne(pollId)
+			if errors.Is(err, service.NothingToDoYet) {
+				err = nil
+			}
+			mustt(t, err)
+
+			var active bool
+			mustt(t, db.DB.QueryRow(qIsActive, pollId).Scan(&active))
+			if active == tt.expectClosed {
+				t.Errorf("Wrong state. Got active %t.", active)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package services
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/root"
+	"github.com/JBoudou/Itero/mid/salted"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/mid/service"
+	"github.com/JBoudou/Itero/pkg/emailsender"
+	"github.com/JBoudou/Itero/pkg/rules"
+	"github.com/JBoudou/Itero/pkg/slog"
+)
+
+// DigestPeriod is the frequency at which a user receives digest emails.
+type DigestPeriod uint8
+
+const (
+	DigestNone DigestPeriod = iota
+	DigestDaily
+	DigestWeekly
+)
+
+// Next returns the first date after from that is a whole number of periods after date.
+func (self DigestPeriod) Next(date, from time.Time) time.Time {
+	days := 1
+	if self == DigestWeekly {
+		days = 7
+	}
+	for !date.After(from) {
+		date = date.AddDate(0, 0, days)
+	}
+	return date
+}
+
+func (self DigestPeriod) String() string {
+	switch self {
+	case DigestDaily:
+		return "daily"
+	case DigestWeekly:
+		return "weekly"
+	}
+	return "none"
+}
+
+type digestService struct {
+	sender emailsender.Sender
+	logger slog.Leveled
+}
+
+// DigestService is the factory for the service that sends digest emails. A digest summarises the
+// new rounds and the terminated polls since the previous digest, and lists the polls awaiting the
+// vote of the user. The objects processed by the service are the users that opted in for digests.
+// Digests are built from the inbox of users, hence they depend on InboxService.
+func DigestService(sender emailsender.Sender, log slog.StackedLeveled) *digestService {
+	return &digestService{
+		sender: sender,
+		logger: log.With("Digest"),
+	}
+}
+
+type digestEntry struct {
+	Title   string
+	Segment string
+	Round   uint8 // Starting from 1.
+
+	// Only for terminated polls. Result is either the funded alternatives, or all the alternatives
+	// from the best to the worst.
+	Funded bool
+	Result []string
+
+	poll uint32
+}
+
+type digestEmailData struct {
+	Sender     string
+	Name       string
+	Address    string
+	BaseURL    string
+	Period     DigestPeriod
+	Awaiting   []digestEntry
+	Rounds     []digestEntry
+	Terminated []digestEntry
+}
+
+func (self *digestService) ProcessOne(id uint32) error {
+	const (
+		qDigest = `
+		  SELECT d.Period, d.NextSend, d.LastSent, u.Name, u.Email,
+		         u.Verified AND u.Email IS NOT NULL AND u.Name IS NOT NULL
+		    FROM Digests AS d, Users AS u
+		   WHERE d.User = ? AND u.Id = d.User AND d.NextSend <= CURRENT_TIMESTAMP
+		     FOR UPDATE`
+		qUpdate = `UPDATE Digests SET NextSend = ?, LastSent = CURRENT_TIMESTAMP WHERE User = ?`
+	)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var data digestEmailData
+	var sendable bool
+	var nextSend time.Time
+	var lastSent sql.NullTime
+	var name, email sql.NullString
+	err = tx.QueryRow(qDigest, id).Scan(&data.Period, &nextSend, &lastSent, &name, &email, &sendable)
+	if err == sql.ErrNoRows {
+		return service.NothingToDoYet
+	}
+	if err != nil {
+		return err
+	}
+	data.Name, data.Address = name.String, email.String
+	since := lastSent.Time
+	if !lastSent.Valid {
+		since = nextSend.AddDate(0, 0, -7)
+		if data.Period == DigestDaily {
+			since = nextSend.AddDate(0, 0, -1)
+		}
+	}
+
+	// The next date is recorded first, so that no digest is sent twice.
+	if _, err = tx.Exec(qUpdate, data.Period.Next(nextSend, time.Now()), id); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	if !sendable {
+		return nil
+	}
+
+	if err = self.fillDigest(id, since, &data); err != nil {
+		return err
+	}
+	if len(data.Awaiting) == 0 && len(data.Rounds) == 0 && len(data.Terminated) == 0 {
+		return nil
+	}
+
+	tmpl, err := template.ParseFiles(filepath.Join(root.BaseDir, TmplBaseDir, "en", "digest.txt"))
+	if err != nil {
+		return err
+	}
+	data.Sender = emailConfig.Sender
+	data.BaseURL = server.BaseURL()
+	return self.sender.Send(emailsender.Email{
+		To:   []string{data.Address},
+		Tmpl: tmpl,
+		Data: data,
+	})
+}
+
+// fillDigest retrieves the content of the digest of user since the given date. Polls are awaiting
+// the vote of the user if the user is allowed to vote in the current round, and has neither voted
+// nor delegated yet.
+func (self *digestService) fillDigest(user uint32, since time.Time, data *digestEmailData) error {
+	const (
+		qNotifs = `
+		  SELECT p.Id, p.Salt, n.Title, n.Round, n.Action
+		    FROM Notifications AS n, Polls AS p
+		   WHERE n.User = ? AND n.Created > ? AND n.Action IN (?, ?) AND p.Id = n.Poll
+		   ORDER BY n.Created ASC, n.Id ASC`
+		qAwaiting = `
+		  SELECT p.Id, p.Salt, p.Title, p.CurrentRound, ?
+		    FROM Polls AS p
+		   WHERE p.State = 'Active'
+		     AND p.Id IN (SELECT Poll FROM Participants WHERE User = ?)
+		     AND p.Id NOT IN (SELECT Poll FROM Participants WHERE User = ? AND Round = p.CurrentRound)
+		     AND p.Id NOT IN (SELECT Poll FROM Delegations WHERE User = ? AND Round = p.CurrentRound)
+		     AND (p.CurrentMover IS NULL OR p.CurrentMover = ?)
+		   ORDER BY p.Id ASC`
+	)
+
+	rows, err := db.DB.Query(qAwaiting, PollNotifTurn, user, user, user, user)
+	if err == nil {
+		err = self.scanEntries(rows, data)
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err = db.DB.Query(qNotifs, user, since, PollNotifNext, PollNotifTerm)
+	if err == nil {
+		err = self.scanEntries(rows, data)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range data.Terminated {
+		if err = self.pollResult(&data.Terminated[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pollResult computes the result of the last round of a terminated poll. All information is given
+// about terminated polls, whatever their Information mode.
+func (self *digestService) pollResult(entry *digestEntry) error {
+	const (
+		qPoll         = `SELECT Type, Rule, MaxOutcomeCost, CurrentRound FROM Polls WHERE Id = ?`
+		qAlternatives = `SELECT Name, Cost FROM Alternatives WHERE Poll = ? ORDER BY Id ASC`
+	)
+	ctx := context.Background()
+
+	var pollType, rule, round uint8
+	var budget float64
+	err := db.DB.QueryRow(qPoll, entry.poll).Scan(&pollType, &rule, &budget, &round)
+	if err != nil || round == 0 {
+		return err
+	}
+	round -= 1
+
+	rows, err := db.DB.Query(qAlternatives, entry.poll)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var names []string
+	var costs []float64
+	for rows.Next() {
+		var name string
+		var cost float64
+		if err = rows.Scan(&name, &cost); err != nil {
+			return err
+		}
+		names = append(names, name)
+		costs = append(costs, cost)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	var outcome []rules.Result
+	switch {
+	case pollType == db.PollTypeGrading:
+		var profile *rules.GradeProfile
+		if profile, err = db.LoadGradeProfile(ctx, entry.poll, round); err != nil {
+			return err
+		}
+		outcome = db.GradeRuleFromDB(rule).Outcome(profile)
+
+	case rule == db.PollRuleGreedyApproval || rule == db.PollRuleEqualShares:
+		var profile *rules.Profile
+		if profile, err = db.LoadProfile(ctx, entry.poll, round); err != nil {
+			return err
+		}
+		entry.Funded = true
+		for _, alt := range db.BudgetRuleFromDB(rule).Funded(profile, costs, budget) {
+			entry.Result = append(entry.Result, names[alt])
+		}
+		return nil
+
+	default:
+		var profile *rules.Profile
+		if profile, err = db.LoadProfile(ctx, entry.poll, round); err != nil {
+			return err
+		}
+		outcome = db.RuleFromDB(rule).Outcome(profile)
+	}
+
+	for _, result := range outcome {
+		entry.Result = append(entry.Result, names[result.Alternative])
+	}
+	return nil
+}
+
+// scanEntries adds to data the entries read from rows, according to their action. Rows must
+// contain a poll id, its salt, its title, a round and an action.
+func (self *digestService) scanEntries(rows *sql.Rows, data *digestEmailData) error {
+	defer rows.Close()
+	for rows.Next() {
+		var entry digestEntry
+		var action PollNotifAction
+		segment := salted.Segment{}
+		err := rows.Scan(&segment.Id, &segment.Salt, &entry.Title, &entry.Round, &action)
+		if err != nil {
+			return err
+		}
+		entry.poll = segment.Id
+		entry.Round += 1
+		if entry.Segment, err = segment.Encode(); err != nil {
+			return err
+		}
+		switch action {
+		case PollNotifTurn:
+			data.Awaiting = append(data.Awaiting, entry)
+		case PollNotifNext:
+			data.Rounds = append(data.Rounds, entry)
+		case PollNotifTerm:
+			data.Terminated = append(data.Terminated, entry)
+		}
+	}
+	return rows.Err()
+}
+
+func (self *digestService) CheckAll() service.Iterator {
+	const qCheckAll = `SELECT User, NextSend FROM Digests ORDER BY NextSend ASC`
+	return service.SQLCheckAll(qCheckAll)
+}
+
+func (self *digestService) CheckOne(id uint32) (ret time.Time) {
+	const qCheckOne = `SELECT NextSend FROM Digests WHERE User = ?`
+	err := db.DB.QueryRow(qCheckOne, id).Scan(&ret)
+	if err != nil && err != sql.ErrNoRows {
+		self.Logger().Errorf("CheckOne query error: %v", err)
+	}
+	return
+}
+
+func (self *digestService) Interval() time.Duration {
+	return time.Hour
+}
+
+func (self *digestService) Logger() slog.Leveled {
+	return self.logger
+}
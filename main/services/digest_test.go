@@ -0,0 +1,152 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/root"
+	"github.com/JBoudou/Itero/mid/service"
+	"github.com/JBoudou/Itero/pkg/emailsender"
+	estest "github.com/JBoudou/Itero/pkg/emailsender/emailsendertest"
+)
+
+func TestDigestPeriod_Next(t *testing.T) {
+	from := time.Date(2021, time.March, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		period DigestPeriod
+		date   time.Time
+		expect time.Time
+	}{
+		{
+			name:   "Daily future",
+			period: DigestDaily,
+			date:   from.Add(time.Hour),
+			expect: from.Add(time.Hour),
+		},
+		{
+			name:   "Daily now",
+			period: DigestDaily,
+			date:   from,
+			expect: from.AddDate(0, 0, 1),
+		},
+		{
+			name:   "Daily late",
+			period: DigestDaily,
+			date:   from.AddDate(0, 0, -3).Add(time.Hour),
+			expect: from.Add(time.Hour),
+		},
+		{
+			name:   "Weekly late",
+			period: DigestWeekly,
+			date:   from.AddDate(0, 0, -10),
+			expect: from.AddDate(0, 0, 4),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.period.Next(tt.date, from)
+			if !got.Equal(tt.expect) {
+				t.Errorf("Got %v. Expect %v.", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestDigestService(t *testing.T) {
+	const (
+		qVerified = `UPDATE Users SET Verified = TRUE WHERE Id = ?`
+		qDigest   = `
+		  INSERT INTO Digests (User, Period, NextSend)
+		  VALUE (?, ?, SUBTIME(CURRENT_TIMESTAMP, '01:00:00'))`
+		qNotif = `
+		  INSERT INTO Notifications (User, Poll, Title, Round, Action) VALUE (?, ?, 'Digest', 1, ?)`
+	)
+
+	env := new(dbt.Env)
+	defer env.Close()
+	userId := env.CreateUserWith(t.Name())
+	pollId := env.CreatePoll("Digest", userId, db.ElectorateAll)
+	env.QuietExec(qVerified, userId)
+	env.Vote(pollId, 0, userId, 1)
+	env.NextRound(pollId)
+	env.QuietExec(qNotif, userId, pollId, PollNotifNext)
+	termId := env.CreatePoll("Terminated", userId, db.ElectorateAll)
+	env.Vote(termId, 0, userId, 1)
+	env.NextRound(termId)
+	env.QuietExec(`UPDATE Polls SET State = 'Terminated' WHERE Id = ?`, termId)
+	env.QuietExec(qNotif, userId, termId, PollNotifTerm)
+	env.QuietExec(qDigest, userId, DigestDaily)
+
+	// Polls not awaiting the vote of the user.
+	otherId := env.CreateUserWith(t.Name() + "Other")
+	turnId := env.CreatePoll("Turn", otherId, db.ElectorateAll)
+	delegId := env.CreatePoll("Delegated", otherId, db.ElectorateAll)
+	for _, id := range []uint32{turnId, delegId} {
+		env.Vote(id, 0, userId, 1)
+		env.Vote(id, 0, otherId, 1)
+		env.NextRound(id)
+	}
+	env.QuietExec(`UPDATE Polls SET RoundType = ?, CurrentMover = ? WHERE Id = ?`,
+		db.RoundTypeSequential, otherId, turnId)
+	env.QuietExec(`INSERT INTO Delegations (Poll, Round, User, Delegate) VALUE (?, 1, ?, ?)`,
+		delegId, userId, otherId)
+	env.Must(t)
+
+	var sent []emailsender.Email
+	locator := root.IoC.Sub()
+	mustt(t, locator.Bind(func() emailsender.Sender {
+		return estest.SenderMock{
+			T: t,
+			Send_: func(email emailsender.Email) error {
+				sent = append(sent, email)
+				return nil
+			},
+		}
+	}))
+	var svc service.Service
+	mustt(t, locator.Inject(DigestService, &svc))
+
+	mustt(t, svc.ProcessOne(userId))
+	if len(sent) != 1 {
+		t.Fatalf("Wrong number of emails. Got %d. Expect 1.", len(sent))
+	}
+	data, ok := sent[0].Data.(digestEmailData)
+	if !ok {
+		t.Fatalf("Wrong email data %v.", sent[0].Data)
+	}
+	if len(data.Awaiting) != 1 || len(data.Rounds) != 1 || len(data.Terminated) != 1 {
+		t.Fatalf("Wrong digest %v.", data)
+	}
+	result := data.Terminated[0].Result
+	if data.Terminated[0].Funded || len(result) != 2 || result[0] != "Yes" {
+		t.Errorf("Wrong result %v. Expect Yes to win.", result)
+	}
+
+	next := svc.CheckOne(userId)
+	if !next.After(time.Now().Add(22 * time.Hour)) {
+		t.Errorf("Wrong next send time %v.", next)
+	}
+	if err := svc.ProcessOne(userId); !errors.Is(err, service.NothingToDoYet) {
+		t.Errorf("Second ProcessOne. Got %v. Expect NothingToDoYet.", err)
+	}
+}
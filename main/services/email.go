@@ -18,12 +18,14 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"path/filepath"
 	"text/template"
 	"time"
 
 	"github.com/JBoudou/Itero/mid/db"
 	"github.com/JBoudou/Itero/mid/root"
+	"github.com/JBoudou/Itero/mid/salted"
 	"github.com/JBoudou/Itero/mid/server"
 	"github.com/JBoudou/Itero/mid/service"
 	"github.com/JBoudou/Itero/pkg/config"
@@ -36,7 +38,8 @@ import (
 const TmplBaseDir = "email"
 
 // EmailService is the factory for the service that sends emails to users.
-// Emails are sent when some events are received.
+// Emails are sent when some events are received. Emails about the lifecycle of polls are sent only
+// to verified users that opted in for them.
 func EmailService(sender emailsender.Sender, log slog.StackedLeveled) emailService {
 	return emailService{
 		sender: sender,
@@ -111,7 +114,8 @@ func (self emailService) Logger() slog.Leveled {
 
 func (self emailService) FilterEvent(evt events.Event) bool {
 	switch evt.(type) {
-	case CreateUserEvent, ReverifyEvent, ForgotEvent:
+	case CreateUserEvent, ReverifyEvent, ForgotEvent, InvitationEvent,
+		StartPollEvent, NextRoundEvent, ClosePollEvent, DeletePollEvent:
 		return true
 	}
 	return false
@@ -125,6 +129,10 @@ func (self emailService) ReceiveEvent(evt events.Event, ctrl service.RunnerContr
 		self.confirmationEmail(converted.User, ctrl, "reverify.txt", db.ConfirmationTypeVerify, 48*time.Hour)
 	case ForgotEvent:
 		self.confirmationEmail(converted.User, ctrl, "forgot.txt", db.ConfirmationTypePasswd, 3*time.Hour)
+	case InvitationEvent:
+		self.invitationEmail(converted.Invitation)
+	case StartPollEvent, NextRoundEvent, ClosePollEvent, DeletePollEvent:
+		self.pollEmail(NewPollNotification(evt))
 	}
 }
 
@@ -192,3 +200,55 @@ func (self emailService) confirmationEmail(userId uint32, ctrl service.RunnerCon
 		return
 	}
 }
+
+func (self emailService) invitationEmail(invitationId uint32) {
+	var data struct {
+		Sender     string
+		Address    string
+		Admin      string
+		Title      string
+		BaseURL    string
+		Invitation string
+	}
+	data.Sender = emailConfig.Sender
+	data.BaseURL = server.BaseURL()
+
+	// Find the template
+	tmpl, err := template.ParseFiles(filepath.Join(root.BaseDir, TmplBaseDir, "en", "invitation.txt"))
+	if err != nil {
+		self.log.Errorf("Error retrieving template: %v", err)
+		return
+	}
+
+	// Retrieve invitation data
+	const qSelect = `
+	  SELECT i.Salt, i.Email, p.Title, u.Name
+	    FROM Invitations AS i, Polls AS p, Users AS u
+	   WHERE i.Id = ? AND i.User IS NULL AND p.Id = i.Poll AND u.Id = p.Admin`
+	segment := salted.Segment{Id: invitationId}
+	err = db.DB.QueryRow(qSelect, invitationId).Scan(&segment.Salt, &data.Address, &data.Title, &data.Admin)
+	if err == sql.ErrNoRows {
+		self.log.Logf("Invitation %d not found or already redeemed", invitationId)
+		return
+	}
+	if err != nil {
+		self.log.Errorf("Error retrieving invitation %d: %v", invitationId, err)
+		return
+	}
+	data.Invitation, err = segment.Encode()
+	if err != nil {
+		self.log.Errorf("Error encoding invitation %v.", err)
+		return
+	}
+
+	// Send the email
+	err = self.sender.Send(emailsender.Email{
+		To:   []string{data.Address},
+		Tmpl: tmpl,
+		Data: data,
+	})
+	if err != nil {
+		self.log.Errorf("Error sending email: %v", err)
+		return
+	}
+}
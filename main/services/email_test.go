@@ -130,6 +130,150 @@ func TestEmailService_Events(t *testing.T) {
 	}
 }
 
+func TestEmailService_Invitation(t *testing.T) {
+	t.Parallel()
+
+	const (
+		qInvite = `INSERT INTO Invitations (Salt, Poll, Email) VALUE (?, ?, ?)`
+		address = "invitee@example.com"
+	)
+
+	dbenv := dbtest.Env{}
+	defer dbenv.Close()
+	uid := dbenv.CreateUserWith(t.Name())
+	pid := dbenv.CreatePoll("Invitation", uid, db.ElectorateInvited)
+	dbenv.Must(t)
+	result, err := db.DB.Exec(qInvite, 42, pid, address)
+	mustt(t, err)
+	invitation, err := db.IdFromResult(result)
+	mustt(t, err)
+
+	emailChan := make(chan emailsender.Email, 1)
+	locator := root.IoC.Sub()
+
+	err = locator.Bind(func() events.Manager {
+		return &evtest.ManagerMock{
+			T: t,
+			AddReceiver_: func(r events.Receiver) error {
+				r.Receive(InvitationEvent{Invitation: invitation})
+				return nil
+			},
+		}
+	})
+	mustt(t, err)
+
+	err = locator.Bind(func() emailsender.Sender {
+		return estest.SenderMock{
+			T: t,
+			Send_: func(email emailsender.Email) error {
+				emailChan <- email
+				return nil
+			},
+		}
+	})
+	mustt(t, err)
+
+	var stop service.StopFunction
+	mustt(t, locator.Inject(EmailService, service.Run, &stop))
+	defer stop()
+
+	select {
+	case email := <-emailChan:
+		if len(email.To) != 1 || email.To[0] != address {
+			t.Errorf("Wrong recipients. Got %v. Expect %s.", email.To, address)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Errorf("No email sent")
+	}
+}
+
+func TestEmailService_PollEvents(t *testing.T) {
+	t.Parallel()
+
+	const (
+		qVerified = `UPDATE Users SET Verified = TRUE WHERE Id = ?`
+		qPrefs    = `INSERT INTO EmailPreferences (User, Action) VALUE (?, ?)`
+		qPart     = `INSERT INTO Participants (Poll, User, Round) VALUE (?, ?, 0)`
+	)
+
+	dbenv := dbtest.Env{}
+	defer dbenv.Close()
+	admin := dbenv.CreateUserWith(t.Name() + "Admin")
+	optIn := dbenv.CreateUserWith(t.Name() + "OptIn")
+	optOut := dbenv.CreateUserWith(t.Name() + "OptOut")
+	pid := dbenv.CreatePoll("PollEvents", admin, db.ElectorateAll)
+	for _, user := range []uint32{admin, optIn, optOut} {
+		dbenv.QuietExec(qVerified, user)
+		dbenv.QuietExec(qPart, pid, user)
+	}
+	dbenv.QuietExec(qPrefs, optIn, PollNotifNext)
+	dbenv.QuietExec(qPrefs, optIn, PollNotifDelete)
+	dbenv.QuietExec(qPrefs, optOut, PollNotifStart)
+	dbenv.Must(t)
+	address := dbtest.UserEmailWith(t.Name() + "OptIn")
+
+	tests := []struct {
+		name   string
+		event  events.Event
+		expect int
+	}{
+		{name: "Next", event: NextRoundEvent{Poll: pid, Round: 1}, expect: 1},
+		{name: "Term", event: ClosePollEvent{Poll: pid}, expect: 0},
+		{
+			name: "Delete",
+			event: DeletePollEvent{Poll: pid, Title: "PollEvents",
+				Participants: map[uint32]bool{optIn: true, optOut: true}},
+			expect: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emailChan := make(chan emailsender.Email, 4)
+			locator := root.IoC.Sub()
+
+			err := locator.Bind(func() events.Manager {
+				return &evtest.ManagerMock{
+					T: t,
+					AddReceiver_: func(r events.Receiver) error {
+						r.Receive(tt.event)
+						return nil
+					},
+				}
+			})
+			mustt(t, err)
+
+			err = locator.Bind(func() emailsender.Sender {
+				return estest.SenderMock{
+					T: t,
+					Send_: func(email emailsender.Email) error {
+						emailChan <- email
+						return nil
+					},
+				}
+			})
+			mustt(t, err)
+
+			var stop service.StopFunction
+			mustt(t, locator.Inject(EmailService, service.Run, &stop))
+			time.Sleep(200 * time.Millisecond)
+			stop()
+			close(emailChan)
+
+			got := 0
+			for email := range emailChan {
+				got += 1
+				if len(email.To) != 1 || email.To[0] != address {
+					t.Errorf("Wrong recipients. Got %v. Expect %s.", email.To, address)
+				}
+			}
+			if got != tt.expect {
+				t.Errorf("Wrong number of emails. Got %d. Expect %d.", got, tt.expect)
+			}
+		})
+	}
+}
+
 type emailTestInstance struct {
 	name     string
 	type_    db.ConfirmationType
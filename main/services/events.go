@@ -41,11 +41,29 @@ type ForgotEvent struct {
 // Polls
 //
 
+// InvitationEvent is sent when an invitation to a poll must be emailed.
+type InvitationEvent struct {
+	Invitation uint32
+}
+
 // CreatePollEvent is sents when a new poll has been created.
 type CreatePollEvent struct {
 	Poll uint32
 }
 
+// EditPollEvent is sent when a poll that did not start yet, or is still in its first round, has
+// been modified.
+type EditPollEvent struct {
+	Poll uint32
+}
+
+// RemoveParticipantEvent is sent when the administrator of a poll removed a participant and all
+// its ballots.
+type RemoveParticipantEvent struct {
+	Poll uint32
+	User uint32
+}
+
 // StartPollEvent is sent when a poll has started.
 type StartPollEvent struct {
 	Poll uint32
@@ -62,6 +80,13 @@ type NextRoundEvent struct {
 	Round uint8
 }
 
+// TurnEvent is sent when a participant of a sequential poll becomes the only one allowed to move.
+type TurnEvent struct {
+	Poll  uint32
+	Round uint8
+	User  uint32
+}
+
 // ClosePollEvent is sent when a poll has been marked as terminated.
 type ClosePollEvent struct {
 	Poll uint32
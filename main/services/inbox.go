@@ -0,0 +1,139 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package services
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/service"
+	"github.com/JBoudou/Itero/pkg/events"
+	"github.com/JBoudou/Itero/pkg/slog"
+)
+
+// InboxRetention is the duration during which notifications are kept in the inbox of users.
+const InboxRetention = 30 * 24 * time.Hour
+
+type inboxService struct {
+	logger slog.Leveled
+}
+
+// InboxService is the factory for the service that keeps notifications in the inbox of logged
+// users. Notifications about a poll are stored for its administrator and its participants.
+// Notifications about an invitation are stored for the user whose email address has been invited.
+// The objects processed by the service are users, whose old notifications are purged.
+func InboxService(log slog.StackedLeveled) *inboxService {
+	return &inboxService{logger: log.With("Inbox")}
+}
+
+func (self *inboxService) ProcessOne(id uint32) error {
+	const qPurge = `
+	  DELETE FROM Notifications
+	   WHERE User = ? AND Created <= SUBTIME(CURRENT_TIMESTAMP, ?)`
+	result, err := db.DB.Exec(qPurge, id, db.DurationToTime(InboxRetention))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.NothingToDoYet
+	}
+	return nil
+}
+
+func (self *inboxService) CheckAll() service.Iterator {
+	qCheckAll := `
+	  SELECT User, ADDTIME(MIN(Created), '` + db.DurationToTime(InboxRetention) + `') AS Date
+	    FROM Notifications
+	   GROUP BY User
+	   ORDER BY Date ASC`
+	return service.SQLCheckAll(qCheckAll)
+}
+
+func (self *inboxService) CheckOne(id uint32) (ret time.Time) {
+	const qCheckOne = `SELECT MIN(Created) FROM Notifications WHERE User = ?`
+	var oldest sql.NullTime
+	if err := db.DB.QueryRow(qCheckOne, id).Scan(&oldest); err != nil {
+		self.Logger().Errorf("CheckOne query error: %v", err)
+		return
+	}
+	if oldest.Valid {
+		ret = oldest.Time.Add(InboxRetention)
+	}
+	return
+}
+
+func (self *inboxService) Interval() time.Duration {
+	return 24 * time.Hour
+}
+
+func (self *inboxService) Logger() slog.Leveled {
+	return self.logger
+}
+
+func (self *inboxService) FilterEvent(evt events.Event) bool {
+	switch evt.(type) {
+	case StartPollEvent, NextRoundEvent, ClosePollEvent, DeletePollEvent, InvitationEvent:
+		return true
+	}
+	return false
+}
+
+func (self *inboxService) ReceiveEvent(evt events.Event, ctrl service.RunnerControler) {
+	const (
+		qPoll = `
+		  INSERT INTO Notifications (User, Poll, Title, Round, Action)
+		  SELECT u.Id, p.Id, p.Title, p.CurrentRound, ?
+		    FROM Polls AS p, Users AS u
+		   WHERE p.Id = ? AND u.Name IS NOT NULL
+		     AND (u.Id = p.Admin OR u.Id IN (SELECT User FROM Participants WHERE Poll = p.Id))`
+		qDelete = `
+		  INSERT INTO Notifications (User, Title, Action)
+		  SELECT Id, ?, ? FROM Users WHERE Id = ? AND Name IS NOT NULL`
+		qInvitation = `
+		  INSERT INTO Notifications (User, Poll, Title, Action)
+		  SELECT u.Id, p.Id, p.Title, ?
+		    FROM Invitations AS i, Polls AS p, Users AS u
+		   WHERE i.Id = ? AND i.User IS NULL AND p.Id = i.Poll
+		     AND u.Email = i.Email AND u.Name IS NOT NULL`
+	)
+
+	notif := NewPollNotification(evt)
+	var err error
+	switch e := evt.(type) {
+	case DeletePollEvent:
+		for user, member := range e.Participants {
+			if !member {
+				continue
+			}
+			if _, err = db.DB.Exec(qDelete, e.Title, notif.Action, user); err != nil {
+				break
+			}
+		}
+	case InvitationEvent:
+		_, err = db.DB.Exec(qInvitation, notif.Action, e.Invitation)
+	default:
+		_, err = db.DB.Exec(qPoll, notif.Action, notif.Id)
+	}
+	if err != nil {
+		self.Logger().Errorf("Error storing notification: %v", err)
+	}
+}
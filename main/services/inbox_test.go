@@ -0,0 +1,239 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/root"
+	"github.com/JBoudou/Itero/mid/service"
+	"github.com/JBoudou/Itero/pkg/events"
+	evtest "github.com/JBoudou/Itero/pkg/events/eventstest"
+)
+
+// inboxUsers are the users involved in the tests of the inbox service.
+type inboxUsers struct {
+	admin, participant, outsider, invitee uint32
+}
+
+func TestInboxService_Events(t *testing.T) {
+	t.Parallel()
+
+	const (
+		qInvite = `INSERT INTO Invitations (Salt, Poll, Email) VALUE (?, ?, ?)`
+		qCount  = `SELECT COUNT(*) FROM Notifications WHERE User = ? AND Action = ?`
+	)
+
+	tests := []struct {
+		name   string
+		event  func(poll, invitation uint32, users inboxUsers) events.Event
+		action PollNotifAction
+		expect func(users inboxUsers) []uint32 // users receiving a notification
+	}{
+		{
+			name:   "StartPollEvent",
+			event:  func(poll, _ uint32, _ inboxUsers) events.Event { return StartPollEvent{Poll: poll} },
+			action: PollNotifStart,
+			expect: func(users inboxUsers) []uint32 { return []uint32{users.admin, users.participant} },
+		},
+		{
+			name: "NextRoundEvent",
+			event: func(poll, _ uint32, _ inboxUsers) events.Event {
+				return NextRoundEvent{Poll: poll, Round: 1}
+			},
+			action: PollNotifNext,
+			expect: func(users inboxUsers) []uint32 { return []uint32{users.admin, users.participant} },
+		},
+		{
+			name:   "ClosePollEvent",
+			event:  func(poll, _ uint32, _ inboxUsers) events.Event { return ClosePollEvent{Poll: poll} },
+			action: PollNotifTerm,
+			expect: func(users inboxUsers) []uint32 { return []uint32{users.admin, users.participant} },
+		},
+		{
+			name: "DeletePollEvent",
+			event: func(poll, _ uint32, users inboxUsers) events.Event {
+				return DeletePollEvent{
+					Poll:         poll,
+					Title:        "Deleted",
+					Participants: map[uint32]bool{users.participant: true, users.outsider: false},
+				}
+			},
+			action: PollNotifDelete,
+			expect: func(users inboxUsers) []uint32 { return []uint32{users.participant} },
+		},
+		{
+			name: "InvitationEvent",
+			event: func(_, invitation uint32, _ inboxUsers) events.Event {
+				return InvitationEvent{Invitation: invitation}
+			},
+			action: PollNotifInvite,
+			expect: func(users inboxUsers) []uint32 { return []uint32{users.invitee} },
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			env := new(dbt.Env)
+			defer env.Close()
+			users := inboxUsers{
+				admin:       env.CreateUserWith(t.Name() + "Admin"),
+				participant: env.CreateUserWith(t.Name() + "Participant"),
+				outsider:    env.CreateUserWith(t.Name() + "Outsider"),
+				invitee:     env.CreateUserWith(t.Name() + "Invitee"),
+			}
+			pollId := env.CreatePoll("Inbox", users.admin, db.ElectorateAll)
+			env.Vote(pollId, 0, users.participant, 1)
+			env.Must(t)
+			result, err := db.DB.Exec(qInvite, 42, pollId, dbt.UserEmailWith(t.Name()+"Invitee"))
+			mustt(t, err)
+			invitation, err := db.IdFromResult(result)
+			mustt(t, err)
+
+			locator := root.IoC.Sub()
+			err = locator.Bind(func() events.Manager {
+				return &evtest.ManagerMock{
+					T: t,
+					AddReceiver_: func(r events.Receiver) error {
+						r.Receive(tt.event(pollId, invitation, users))
+						return nil
+					},
+				}
+			})
+			mustt(t, err)
+
+			var stop service.StopFunction
+			mustt(t, locator.Inject(InboxService, service.Run, &stop))
+			defer stop()
+
+			expected := make(map[uint32]bool)
+			for _, user := range tt.expect(users) {
+				expected[user] = true
+			}
+			all := []uint32{users.admin, users.participant, users.outsider, users.invitee}
+
+			// Notifications are stored asynchronously.
+			var counts map[uint32]int
+			for i := 0; i < 20; i++ {
+				counts = make(map[uint32]int)
+				done := true
+				for _, user := range all {
+					var count int
+					mustt(t, db.DB.QueryRow(qCount, user, tt.action).Scan(&count))
+					counts[user] = count
+					if expected[user] && count == 0 {
+						done = false
+					}
+				}
+				if done {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			for _, user := range all {
+				if got := counts[user] > 0; got != expected[user] {
+					t.Errorf("Wrong notification for user %d. Got %t. Expect %t.", user, got, expected[user])
+				}
+			}
+		})
+	}
+}
+
+func TestInboxService_Retention(t *testing.T) {
+	t.Parallel()
+
+	const (
+		qInsert = `
+		  INSERT INTO Notifications (User, Title, Action, Created)
+		  VALUE (?, 'Retention', ?, SUBTIME(CURRENT_TIMESTAMP, ?))`
+		qCount = `SELECT COUNT(*) FROM Notifications WHERE User = ?`
+	)
+
+	tests := []struct {
+		name        string
+		ages        []time.Duration // ages of the notifications
+		expectPurge bool
+		expectLeft  int
+	}{
+		{
+			name:       "Recent",
+			ages:       []time.Duration{time.Hour, 24 * time.Hour},
+			expectLeft: 2,
+		},
+		{
+			name:        "Old",
+			ages:        []time.Duration{time.Hour, InboxRetention + time.Hour},
+			expectPurge: true,
+			expectLeft:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			env := new(dbt.Env)
+			defer env.Close()
+			userId := env.CreateUserWith(t.Name())
+			env.Must(t)
+			oldest := time.Duration(0)
+			for _, age := range tt.ages {
+				_, err := db.DB.Exec(qInsert, userId, PollNotifStart, db.DurationToTime(age))
+				mustt(t, err)
+				if age > oldest {
+					oldest = age
+				}
+			}
+
+			var svc service.Service
+			mustt(t, root.IoC.Inject(InboxService, &svc))
+
+			expect := time.Now().Add(InboxRetention - oldest)
+			if diff := svc.CheckOne(userId).Sub(expect); diff < -2*time.Second || diff > 2*time.Second {
+				t.Errorf("Wrong CheckOne. Got %v. Expect %v.", svc.CheckOne(userId), expect)
+			}
+			iterator := svc.CheckAll()
+			listed := idDateIteratorHasId(t, iterator, userId)
+			iterator.Close()
+			if !listed {
+				t.Errorf("User not listed by CheckAll.")
+			}
+
+			err := svc.ProcessOne(userId)
+			if tt.expectPurge {
+				mustt(t, err)
+			} else if !errors.Is(err, service.NothingToDoYet) {
+				t.Errorf("Wrong ProcessOne error. Got %v. Expect NothingToDoYet.", err)
+			}
+
+			var left int
+			mustt(t, db.DB.QueryRow(qCount, userId).Scan(&left))
+			if left != tt.expectLeft {
+				t.Errorf("Wrong number of notifications left. Got %d. Expect %d.", left, tt.expectLeft)
+			}
+		})
+	}
+}
@@ -17,6 +17,8 @@
 package services
 
 import (
+	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/JBoudou/Itero/mid/db"
@@ -44,29 +46,36 @@ func NextRoundService(evtManager events.Manager, log slog.StackedLeveled) *nextR
 func (self *nextRoundService) ProcessOne(id uint32) error {
 	const (
 		qCheck = `
-	    SELECT p.CurrentRound
+	    SELECT p.CurrentRound, p.RoundType, COALESCE(p.CurrentMover, 0)
 	      FROM Polls AS p
 	      LEFT OUTER JOIN Participants_Round_Count AS r ON (p.Id, p.CurrentRound) = (r.Poll, r.Round)
 	      LEFT OUTER JOIN Participants_Poll_Count  AS a ON p.Id = a.Poll
+	      LEFT OUTER JOIN Participants_Round_Weight AS rw ON (p.Id, p.CurrentRound) = (rw.Poll, rw.Round)
+	      LEFT OUTER JOIN Participants_Poll_Weight  AS aw ON p.Id = aw.Poll
 	     WHERE p.Id = ? AND p.State = 'Active' AND p.CurrentRound < p.MaxNbRounds
 	       AND (   ( RoundDeadline(p.CurrentRoundStart, p.MaxRoundDuration, p.Deadline,
 	                               p.CurrentRound, p.MinNbRounds) <= CURRENT_TIMESTAMP()
 	                 AND ( p.CurrentRound > 0 OR r.Count > 2 ))
 	            OR (    p.CurrentRound > 0
+	                AND p.RoundType <> ?
 	                AND (   (p.RoundThreshold = 0 AND r.Count > 0)
 	                     OR ( p.RoundThreshold > 0
-	                          AND r.Count / a.Count >= p.RoundThreshold ) )
+	                          AND IF(p.ThresholdOnWeight, rw.Weight / aw.Weight, r.Count / a.Count)
+	                              >= p.RoundThreshold ) )
 	                AND (   (p.CurrentRound + 1 < MinNbRounds)
 	                     OR p.Deadline IS NULL
 	                     OR (ADDTIME(CURRENT_TIMESTAMP(), p.MaxRoundDuration) < p.Deadline)
-	                     OR (p.Deadline < CURRENT_TIMESTAMP()) )))
+	                     OR (p.Deadline < CURRENT_TIMESTAMP()) ))
+	            OR (p.CurrentRound > 0 AND p.RoundType IN (?, ?) AND r.Count > 0)
+	            OR (p.CurrentRound > 0 AND p.RoundType = ? AND r.Count >= a.Count))
 	       FOR UPDATE`
 		qUpdate = `
-	    UPDATE Polls SET CurrentRound = CurrentRound + 1
+	    UPDATE Polls SET CurrentRound = CurrentRound + 1, CurrentMover = ?
 	     WHERE Id = ?`
 	)
 
-	rows, err := db.DB.Query(qCheck, id)
+	rows, err := db.DB.Query(qCheck, id, db.RoundTypeSynchronous,
+		db.RoundTypeSequential, db.RoundTypeRandomSequential, db.RoundTypeSynchronous)
 	defer rows.Close()
 	if err != nil {
 		return err
@@ -75,19 +84,64 @@ func (self *nextRoundService) ProcessOne(id uint32) error {
 	if !rows.Next() {
 		return service.NothingToDoYet
 	}
-	var round uint8
-	err = rows.Scan(&round)
+	var round, roundType uint8
+	var mover uint32
+	err = rows.Scan(&round, &roundType, &mover)
 	if err != nil {
 		return err
 	}
 	rows.Close()
 
-	_, err = db.DB.Exec(qUpdate, id)
+	var nextMover sql.NullInt64
+	if isSequential(roundType) {
+		nextMover, err = self.nextMover(id, roundType, mover)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = db.DB.Exec(qUpdate, nextMover, id)
 	if err != nil {
 		return err
 	}
 
-	return self.evtManager.Send(NextRoundEvent{Poll: id, Round: round + 1})
+	err = self.evtManager.Send(NextRoundEvent{Poll: id, Round: round + 1})
+	if err == nil && nextMover.Valid {
+		err = self.evtManager.Send(TurnEvent{Poll: id, Round: round + 1, User: uint32(nextMover.Int64)})
+	}
+	return err
+}
+
+// isSequential returns whether participants of polls with the given round type move one at a time.
+func isSequential(roundType uint8) bool {
+	return roundType == db.RoundTypeSequential || roundType == db.RoundTypeRandomSequential
+}
+
+// nextMover chooses the participant allowed to move during the next round of a sequential poll.
+// The result is not valid if the poll has no participant.
+func (self *nextRoundService) nextMover(id uint32, roundType uint8, current uint32) (
+	ret sql.NullInt64, err error) {
+
+	const (
+		qSequential = `
+	    SELECT User FROM Participants WHERE Poll = ? GROUP BY User
+	     ORDER BY User <= ?, User ASC
+	     LIMIT 1`
+		qRandom = `
+	    SELECT User FROM Participants WHERE Poll = ? GROUP BY User
+	     ORDER BY User = ?, RAND()
+	     LIMIT 1`
+	)
+
+	query := qSequential
+	if roundType == db.RoundTypeRandomSequential {
+		query = qRandom
+	}
+	err = db.DB.QueryRow(query, id, current).Scan(&ret)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	return
 }
 
 func (self *nextRoundService) CheckAll() service.Iterator {
@@ -109,19 +163,26 @@ func (self *nextRoundService) CheckOne(id uint32) (ret time.Time) {
 			                     p.MinNbRounds) <= CURRENT_TIMESTAMP,
 	           COALESCE(p.CurrentRound > 0 OR r.Count > 2, FALSE),
 	           COALESCE(    p.CurrentRound > 0
+	                    AND p.RoundType <> ?
 	                    AND (   (p.RoundThreshold = 0 AND r.Count > 0)
 	                         OR ( p.RoundThreshold > 0
-	                              AND r.Count / a.Count >= p.RoundThreshold ) )
+	                              AND IF(p.ThresholdOnWeight, rw.Weight / aw.Weight, r.Count / a.Count)
+	                                  >= p.RoundThreshold ) )
 	                    AND (   (p.CurrentRound + 1 < MinNbRounds)
 	                         OR p.Deadline IS NULL
 	                         OR (ADDTIME(CURRENT_TIMESTAMP(), p.MaxRoundDuration) < p.Deadline)
 	                         OR (p.Deadline < CURRENT_TIMESTAMP()) ), FALSE)
+	        OR COALESCE(p.CurrentRound > 0 AND p.RoundType IN (?, ?) AND r.Count > 0, FALSE)
+	        OR COALESCE(p.CurrentRound > 0 AND p.RoundType = ? AND r.Count >= a.Count, FALSE)
 	      FROM Polls AS p
 	      LEFT OUTER JOIN Participants_Round_Count AS r ON (p.Id, p.CurrentRound) = (r.Poll, r.Round)
 	      LEFT OUTER JOIN Participants_Poll_Count  AS a ON p.Id = a.Poll
+	      LEFT OUTER JOIN Participants_Round_Weight AS rw ON (p.Id, p.CurrentRound) = (rw.Poll, rw.Round)
+	      LEFT OUTER JOIN Participants_Poll_Weight  AS aw ON p.Id = aw.Poll
 	     WHERE p.Id = ? AND p.State = 'Active' AND p.CurrentRound < p.MaxNbRounds`
 
-	rows, err := db.DB.Query(qCheck, id)
+	rows, err := db.DB.Query(qCheck, db.RoundTypeSynchronous,
+		db.RoundTypeSequential, db.RoundTypeRandomSequential, db.RoundTypeSynchronous, id)
 	defer rows.Close()
 	if err != nil {
 		self.Logger().Errorf("CheckOne query error: %v.", err)
@@ -156,7 +217,7 @@ func (self *nextRoundService) Logger() slog.Leveled {
 
 func (self *nextRoundService) FilterEvent(evt events.Event) bool {
 	switch evt.(type) {
-	case VoteEvent, CreatePollEvent, StartPollEvent:
+	case VoteEvent, CreatePollEvent, EditPollEvent, StartPollEvent, RemoveParticipantEvent:
 		return true
 	}
 	return false
@@ -168,7 +229,11 @@ func (self *nextRoundService) ReceiveEvent(evt events.Event, ctrl service.Runner
 		ctrl.Schedule(e.Poll)
 	case CreatePollEvent:
 		ctrl.Schedule(e.Poll)
+	case EditPollEvent:
+		ctrl.Schedule(e.Poll)
 	case StartPollEvent:
 		ctrl.Schedule(e.Poll)
+	case RemoveParticipantEvent:
+		ctrl.Schedule(e.Poll)
 	}
 }
@@ -39,6 +39,9 @@ type nextRoundTestInstance struct {
 	deadlineFact float32 // if !=0 set Deadline = CurrentRoundStart + deadlineFact * MaxRoundDuration
 	threshold    float64 // RoundThreshold
 	nbVoter      int     // number of Participant with LastRound = Poll.CurrentRound
+	sequential   bool    // whether RoundType is Sequential
+	synchronous  bool    // whether RoundType is Synchronous
+	firstWeight  uint32  // if >0, set ThresholdOnWeight and the weight of the first participant
 	expectNext   bool
 	expectList   bool               // whether it must be listed by CheckAll
 	expectCheck  testCheckOneResult // kind of response from CheckOne (see testCheckOneResult*)
@@ -65,6 +68,9 @@ func metaTestNextRound(t *testing.T, checker func(*testing.T, *nextRoundTestInst
 		  UPDATE Polls
 		     SET CurrentRoundStart = SUBTIME(CURRENT_TIMESTAMP(), ? * MaxRoundDuration)
 		   WHERE Id = ?`
+		qSequential  = `UPDATE Polls SET RoundType = ? WHERE Id = ?`
+		qOnWeight    = `UPDATE Polls SET ThresholdOnWeight = TRUE WHERE Id = ?`
+		qWeight      = `INSERT INTO Weights (Poll, User, Weight) VALUE (?, ?, ?)`
 		qSetDeadline = `
 		  UPDATE Polls
 			   SET Deadline = ADDTIME(CurrentRoundStart, ? * MaxRoundDuration)
@@ -142,6 +148,53 @@ func metaTestNextRound(t *testing.T, checker func(*testing.T, *nextRoundTestInst
 			expectList:   true,
 			expectCheck:  testCheckOneResultFuture,
 		},
+		{
+			name:        "Sequential mover moved",
+			round:       1,
+			threshold:   1,
+			nbVoter:     1,
+			sequential:  true,
+			expectNext:  true,
+			expectList:  true,
+			expectCheck: testCheckOneResultPast,
+		},
+		{
+			name:        "Sequential mover waiting",
+			round:       1,
+			threshold:   1,
+			sequential:  true,
+			expectNext:  false,
+			expectList:  true,
+			expectCheck: testCheckOneResultFuture,
+		},
+		{
+			name:        "Synchronous all voted",
+			round:       1,
+			nbVoter:     3,
+			synchronous: true,
+			expectNext:  true,
+			expectList:  true,
+			expectCheck: testCheckOneResultPast,
+		},
+		{
+			name:        "Synchronous threshold ignored",
+			round:       1,
+			nbVoter:     2,
+			synchronous: true,
+			expectNext:  false,
+			expectList:  true,
+			expectCheck: testCheckOneResultFuture,
+		},
+		{
+			name:        "Weighted threshold",
+			round:       1,
+			threshold:   0.5,
+			nbVoter:     1,
+			firstWeight: 3,
+			expectNext:  true,
+			expectList:  true,
+			expectCheck: testCheckOneResultPast,
+		},
 		{
 			name:         "Missing rounds time",
 			round:        1,
@@ -204,6 +257,18 @@ func metaTestNextRound(t *testing.T, checker func(*testing.T, *nextRoundTestInst
 			if err == nil && tt.nowFact > 0 {
 				_, err = db.DB.Exec(qSetNow, tt.nowFact, pollId)
 			}
+			if err == nil && tt.sequential {
+				_, err = db.DB.Exec(qSequential, db.RoundTypeSequential, pollId)
+			}
+			if err == nil && tt.synchronous {
+				_, err = db.DB.Exec(qSequential, db.RoundTypeSynchronous, pollId)
+			}
+			if err == nil && tt.firstWeight > 0 {
+				_, err = db.DB.Exec(qOnWeight, pollId)
+				if err == nil {
+					_, err = db.DB.Exec(qWeight, pollId, user[0], tt.firstWeight)
+				}
+			}
 			if err == nil && tt.deadlineFact != 0 {
 				_, err = db.DB.Exec(qSetDeadline, tt.deadlineFact, pollId)
 			}
@@ -286,6 +351,69 @@ func TestNextRoundService_ProcessOne(t *testing.T) {
 	metaTestNextRound(t, nextRound_processOne_checker)
 }
 
+func TestNextRoundService_Turn(t *testing.T) {
+	const (
+		qParticipate = `INSERT INTO Participants(Poll, User, Round) VALUE (?,?,?)`
+		qSequential  = `UPDATE Polls SET RoundType = ?, CurrentRound = 1 WHERE Id = ?`
+		qMover       = `SELECT CurrentMover FROM Polls WHERE Id = ?`
+	)
+
+	env := new(dbt.Env)
+	defer env.Close()
+	var users [2]uint32
+	for i := range users {
+		users[i] = env.CreateUserWith(t.Name() + strconv.FormatInt(int64(i), 10))
+	}
+	if users[0] > users[1] {
+		users[0], users[1] = users[1], users[0]
+	}
+	pollId := env.CreatePoll("TestNextRoundService_Turn", users[0], db.ElectorateAll)
+	env.QuietExec(qSequential, db.RoundTypeSequential, pollId)
+	for _, user := range users {
+		env.QuietExec(qParticipate, pollId, user, 0)
+	}
+	env.Must(t)
+
+	var turns []TurnEvent
+	locator := root.IoC.Sub()
+	locator.Bind(func() events.Manager {
+		return &eventstest.ManagerMock{
+			T: t,
+			Send_: func(evt events.Event) error {
+				if turn, ok := evt.(TurnEvent); ok {
+					turns = append(turns, turn)
+				}
+				return nil
+			},
+		}
+	})
+	var svc service.Service
+	mustt(t, locator.Inject(NextRoundService, &svc))
+
+	// Only the mover of each round votes. There is no mover yet for round 1.
+	expect := []uint32{users[0], users[1], users[0]}
+	mover := users[0]
+	for round := uint8(1); round <= 3; round++ {
+		env.QuietExec(qParticipate, pollId, mover, round)
+		env.Must(t)
+		mustt(t, svc.ProcessOne(pollId))
+
+		mustt(t, db.DB.QueryRow(qMover, pollId).Scan(&mover))
+		if mover != expect[round-1] {
+			t.Errorf("Round %d. Wrong mover. Got %d. Expect %d.", round+1, mover, expect[round-1])
+		}
+	}
+
+	if len(turns) != len(expect) {
+		t.Fatalf("Wrong number of TurnEvent. Got %d. Expect %d.", len(turns), len(expect))
+	}
+	for i, turn := range turns {
+		if turn.Poll != pollId || turn.Round != uint8(i+2) || turn.User != expect[i] {
+			t.Errorf("Wrong TurnEvent %d. Got %v.", i, turn)
+		}
+	}
+}
+
 // CheckAll //
 
 func idDateIteratorHasId(t *testing.T, iterator service.Iterator, id uint32) bool {
@@ -378,11 +506,21 @@ func TestNextRoundService_Events(t *testing.T) {
 			event:    CreatePollEvent{2},
 			schedule: []uint32{2},
 		},
+		{
+			name:     "EditPollEvent",
+			event:    EditPollEvent{4},
+			schedule: []uint32{4},
+		},
 		{
 			name:     "StartPollEvent",
 			event:    StartPollEvent{3},
 			schedule: []uint32{3},
 		},
+		{
+			name:     "RemoveParticipantEvent",
+			event:    RemoveParticipantEvent{5, 7},
+			schedule: []uint32{5},
+		},
 		{
 			name:  "ClosePollEvent",
 			event: ClosePollEvent{42},
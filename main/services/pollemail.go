@@ -0,0 +1,160 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/root"
+	"github.com/JBoudou/Itero/mid/salted"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/pkg/emailsender"
+)
+
+// PollEmailActions lists the kinds of notifications that can be sent by email. Users opt in for
+// each of them by adding a row in the EmailPreferences table.
+var PollEmailActions = []PollNotifAction{
+	PollNotifStart,
+	PollNotifNext,
+	PollNotifTerm,
+	PollNotifDelete,
+}
+
+var pollEmailTemplates = map[PollNotifAction]string{
+	PollNotifStart:  "pollstart.txt",
+	PollNotifNext:   "pollnext.txt",
+	PollNotifTerm:   "pollterm.txt",
+	PollNotifDelete: "polldelete.txt",
+}
+
+type pollEmailData struct {
+	Sender  string
+	Name    string
+	Address string
+	Title   string
+	Round   uint8 // Starting from 1.
+	BaseURL string
+	Segment string
+}
+
+// pollEmail sends an email about the notification to each user related to the poll that opted in
+// for that kind of notification.
+func (self emailService) pollEmail(notif *PollNotification) {
+	tmplFile, ok := pollEmailTemplates[notif.Action]
+	if !ok {
+		return
+	}
+	tmpl, err := template.ParseFiles(filepath.Join(root.BaseDir, TmplBaseDir, "en", tmplFile))
+	if err != nil {
+		self.log.Errorf("Error retrieving template: %v", err)
+		return
+	}
+
+	var recipients []pollEmailData
+	if notif.Action == PollNotifDelete {
+		recipients, err = self.deletedPollRecipients(notif)
+	} else {
+		recipients, err = self.pollRecipients(notif)
+	}
+	if err != nil {
+		self.log.Errorf("Error retrieving recipients for poll %d: %v", notif.Id, err)
+		return
+	}
+
+	for _, data := range recipients {
+		err = self.sender.Send(emailsender.Email{
+			To:   []string{data.Address},
+			Tmpl: tmpl,
+			Data: data,
+		})
+		if err != nil {
+			self.log.Errorf("Error sending email: %v", err)
+		}
+	}
+}
+
+func (self emailService) pollRecipients(notif *PollNotification) (ret []pollEmailData, err error) {
+	const qSelect = `
+	  SELECT u.Name, u.Email, p.Title, p.Salt, p.CurrentRound
+	    FROM Polls AS p, EmailPreferences AS e, Users AS u
+	   WHERE p.Id = ? AND e.Action = ? AND u.Id = e.User
+	     AND u.Name IS NOT NULL AND u.Email IS NOT NULL AND u.Verified
+	     AND ( u.Id = p.Admin
+	           OR u.Id IN (SELECT User FROM Participants WHERE Poll = p.Id)
+	           OR u.Id IN (SELECT User FROM Invitations WHERE Poll = p.Id) )`
+
+	rows, err := db.DB.Query(qSelect, notif.Id, notif.Action)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		data := pollEmailData{Sender: emailConfig.Sender, BaseURL: server.BaseURL()}
+		segment := salted.Segment{Id: notif.Id}
+		if err = rows.Scan(&data.Name, &data.Address, &data.Title, &segment.Salt, &data.Round); err != nil {
+			return
+		}
+		data.Round += 1
+		if data.Segment, err = segment.Encode(); err != nil {
+			return
+		}
+		ret = append(ret, data)
+	}
+	err = rows.Err()
+	return
+}
+
+func (self emailService) deletedPollRecipients(notif *PollNotification) (ret []pollEmailData,
+	err error) {
+	args := make([]interface{}, 0, len(notif.Participants)+1)
+	args = append(args, notif.Action)
+	for user, member := range notif.Participants {
+		if member {
+			args = append(args, user)
+		}
+	}
+	if len(args) == 1 {
+		return
+	}
+
+	qSelect := `
+	  SELECT u.Name, u.Email
+	    FROM EmailPreferences AS e, Users AS u
+	   WHERE e.Action = ? AND u.Id = e.User
+	     AND u.Name IS NOT NULL AND u.Email IS NOT NULL AND u.Verified
+	     AND u.Id IN (?` + strings.Repeat(`, ?`, len(args)-2) + `)`
+
+	rows, err := db.DB.Query(qSelect, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		data := pollEmailData{Sender: emailConfig.Sender, BaseURL: server.BaseURL(), Title: notif.Title}
+		if err = rows.Scan(&data.Name, &data.Address); err != nil {
+			return
+		}
+		ret = append(ret, data)
+	}
+	err = rows.Err()
+	return
+}
@@ -17,6 +17,7 @@
 package services
 
 import (
+	"sync"
 	"time"
 
 	"github.com/JBoudou/Itero/mid/root"
@@ -34,6 +35,8 @@ const (
 	PollNotifNext
 	PollNotifTerm
 	PollNotifDelete
+	PollNotifTurn
+	PollNotifInvite
 )
 
 type PollNotification struct {
@@ -43,6 +46,9 @@ type PollNotification struct {
 	Round        uint8
 	Title        string
 	Participants map[uint32]bool
+
+	// User is the only user the notification is for. Zero means any user.
+	User uint32
 }
 
 // NewPollNotification creates a new notification from an event.
@@ -60,6 +66,12 @@ func NewPollNotification(evt events.Event) (ret *PollNotification) {
 		ret.Action = PollNotifNext
 		ret.Round = e.Round
 
+	case TurnEvent:
+		ret.Id = e.Poll
+		ret.Action = PollNotifTurn
+		ret.Round = e.Round
+		ret.User = e.User
+
 	case ClosePollEvent:
 		ret.Id = e.Poll
 		ret.Action = PollNotifTerm
@@ -69,6 +81,10 @@ func NewPollNotification(evt events.Event) (ret *PollNotification) {
 		ret.Action = PollNotifDelete
 		ret.Title = e.Title
 		ret.Participants = e.Participants
+
+	case InvitationEvent:
+		// The poll is not known from the event.
+		ret.Action = PollNotifInvite
 	}
 
 	return
@@ -127,8 +143,13 @@ func newPollNotifRunner(delay time.Duration) *pollNotifRunner {
 }
 
 func (self *pollNotifRunner) filter(evt events.Event) bool {
+	return isPollNotifEvent(evt)
+}
+
+// isPollNotifEvent returns whether a notification is created for the event.
+func isPollNotifEvent(evt events.Event) bool {
 	switch evt.(type) {
-	case StartPollEvent, NextRoundEvent, ClosePollEvent, DeletePollEvent:
+	case StartPollEvent, NextRoundEvent, TurnEvent, ClosePollEvent, DeletePollEvent:
 		return true
 	}
 	return false
@@ -152,6 +173,116 @@ func (self *pollNotifRunner) run(eventChan <-chan events.Event, notifChan chan<-
 	}
 }
 
+//
+// PollNotifBroker
+//
+
+// PollNotifStreamDelay is the default duration during which notifications are kept by
+// PollNotifBroker, for subscribers to resume.
+const PollNotifStreamDelay = 10 * time.Minute
+
+// pollNotifSubscriberSize is the number of notifications a subscriber may lag behind before being
+// unsubscribed.
+const pollNotifSubscriberSize = 32
+
+// PollNotifBroker dispatches notifications to subscribers as soon as the corresponding events are
+// received. Recent notifications are kept, allowing subscribers to resume from a given time.
+// The timestamps of the notifications dispatched by a broker are all distinct.
+//
+// A factory is binded to this type in root.IoC. The factory calls RunPollNotifBroker with
+// PollNotifStreamDelay as delay.
+type PollNotifBroker struct {
+	mutex       sync.Mutex
+	history     *pollNotifList
+	subscribers map[chan *PollNotification]bool
+	last        time.Time
+}
+
+func init() {
+	root.IoC.Bind(func(evtManager events.Manager) (*PollNotifBroker, error) {
+		return RunPollNotifBroker(PollNotifStreamDelay, evtManager)
+	})
+}
+
+// RunPollNotifBroker launches a broker of notifications corresponding to the events received from
+// the given event Manager. Notifications are kept for the given duration.
+func RunPollNotifBroker(delay time.Duration, evtManager events.Manager) (*PollNotifBroker, error) {
+	ret := &PollNotifBroker{
+		history:     newPollNotifList(delay),
+		subscribers: make(map[chan *PollNotification]bool),
+	}
+
+	eventChan := make(chan events.Event, 64)
+	err := evtManager.AddReceiver(events.AsyncForwarder{
+		Filter: isPollNotifEvent,
+		Chan:   eventChan,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go ret.run(eventChan)
+	return ret, nil
+}
+
+func (self *PollNotifBroker) run(eventChan <-chan events.Event) {
+	for evt := range eventChan {
+		notif := NewPollNotification(evt)
+
+		self.mutex.Lock()
+		if !notif.Timestamp.After(self.last) {
+			notif.Timestamp = self.last.Add(time.Nanosecond)
+		}
+		self.last = notif.Timestamp
+		self.history.Add(notif)
+		for ch := range self.subscribers {
+			select {
+			case ch <- notif:
+			default:
+				delete(self.subscribers, ch)
+				close(ch)
+			}
+		}
+		self.mutex.Unlock()
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for ch := range self.subscribers {
+		delete(self.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Subscribe registers a new subscriber. The returned slice contains the kept notifications whose
+// timestamp is after since. The returned channel receives all the subsequent notifications. It is
+// closed when the broker stops, or when the subscriber lags too far behind. The returned function
+// must be called to unsubscribe.
+func (self *PollNotifBroker) Subscribe(since time.Time) (
+	missed []*PollNotification, notifs <-chan *PollNotification, cancel func()) {
+
+	ch := make(chan *PollNotification, pollNotifSubscriberSize)
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for _, notif := range self.history.Slice() {
+		if notif.Timestamp.After(since) {
+			missed = append(missed, notif)
+		}
+	}
+	self.subscribers[ch] = true
+
+	cancel = func() {
+		self.mutex.Lock()
+		defer self.mutex.Unlock()
+		if self.subscribers[ch] {
+			delete(self.subscribers, ch)
+			close(ch)
+		}
+	}
+	return missed, ch, cancel
+}
+
 //
 // pollNotifList
 //
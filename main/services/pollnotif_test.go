@@ -135,6 +135,12 @@ func TestPollNotif(t *testing.T) {
 			id:     3,
 			action: PollNotifTerm,
 		},
+		{
+			event:  TurnEvent{Poll: 4, Round: 2, User: 5},
+			id:     4,
+			round:  2,
+			action: PollNotifTurn,
+		},
 	}
 	for _, elt := range elements {
 		evtManager.Send(elt.event)
@@ -161,3 +167,50 @@ func TestPollNotif(t *testing.T) {
 		}
 	}
 }
+
+func TestPollNotifBroker(t *testing.T) {
+	t.Parallel()
+
+	evtManager := events.NewAsyncManager(0)
+	defer evtManager.Close()
+	broker, err := RunPollNotifBroker(time.Second, evtManager)
+	mustt(t, err)
+
+	start := time.Now()
+	evtManager.Send(StartPollEvent{Poll: 1})
+	evtManager.Send(NextRoundEvent{Poll: 2, Round: 1})
+	time.Sleep(5 * time.Millisecond)
+
+	missed, notifs, cancel := broker.Subscribe(start)
+	defer cancel()
+	if len(missed) != 2 {
+		t.Fatalf("Wrong number of missed notifications. Got %d. Expect 2.", len(missed))
+	}
+	if missed[0].Id != 1 || missed[1].Id != 2 {
+		t.Errorf("Wrong missed notifications %d and %d.", missed[0].Id, missed[1].Id)
+	}
+	if !missed[1].Timestamp.After(missed[0].Timestamp) {
+		t.Errorf("Timestamps not increasing.")
+	}
+
+	again, _, cancelAgain := broker.Subscribe(missed[0].Timestamp)
+	cancelAgain()
+	if len(again) != 1 || again[0].Id != 2 {
+		t.Errorf("Wrong resumption. Got %v.", again)
+	}
+
+	evtManager.Send(ClosePollEvent{Poll: 3})
+	select {
+	case notif := <-notifs:
+		if notif.Id != 3 || notif.Action != PollNotifTerm {
+			t.Errorf("Wrong notification. Got %v.", notif)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("No notification received.")
+	}
+
+	cancel()
+	if _, ok := <-notifs; ok {
+		t.Errorf("Channel not closed after cancel.")
+	}
+}
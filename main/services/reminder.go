@@ -0,0 +1,229 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package services
+
+import (
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/JBoudou/Itero/mid/db"
+	"github.com/JBoudou/Itero/mid/root"
+	"github.com/JBoudou/Itero/mid/salted"
+	"github.com/JBoudou/Itero/mid/server"
+	"github.com/JBoudou/Itero/mid/service"
+	"github.com/JBoudou/Itero/pkg/config"
+	"github.com/JBoudou/Itero/pkg/emailsender"
+	"github.com/JBoudou/Itero/pkg/events"
+	"github.com/JBoudou/Itero/pkg/slog"
+)
+
+// ReminderDefaultLeadTime is the default duration between the reminder emails and the end of the
+// round. It can be changed by the LeadTime field of the "reminders" configuration value.
+const ReminderDefaultLeadTime = 12 * time.Hour
+
+type reminderService struct {
+	sender   emailsender.Sender
+	logger   slog.Leveled
+	leadTime string
+}
+
+// ReminderService is the factory for the service that reminds participants of polls to vote before
+// the end of the current round. Participants that have not voted yet in the current round, and are
+// allowed to vote, are sent at most one email per round. Only rounds lasting longer than the lead
+// time are concerned.
+func ReminderService(sender emailsender.Sender, log slog.StackedLeveled) *reminderService {
+	ret := &reminderService{
+		sender:   sender,
+		logger:   log.With("Reminder"),
+		leadTime: db.DurationToTime(ReminderDefaultLeadTime),
+	}
+
+	var cfg struct {
+		LeadTime string
+	}
+	err := config.ValueOr("reminders", &cfg,
+		map[string]string{"LeadTime": ReminderDefaultLeadTime.String()})
+	if err == nil {
+		var leadTime time.Duration
+		leadTime, err = time.ParseDuration(cfg.LeadTime)
+		if err == nil {
+			ret.leadTime = db.DurationToTime(leadTime)
+		}
+	}
+	if err != nil {
+		ret.logger.Errorf("Wrong configuration, using default lead time: %v", err)
+	}
+	return ret
+}
+
+const (
+	// reminderDeadline is the SQL expression for the end of the current round of poll p.
+	reminderDeadline = `
+	  RoundDeadline(p.CurrentRoundStart, p.MaxRoundDuration, p.Deadline, p.CurrentRound,
+	                p.MinNbRounds)`
+
+	// reminderRecipients is the SQL condition satisfied by the users u to remind for poll p.
+	// In sequential polls, only the current mover is reminded.
+	reminderRecipients = `
+	      u.Verified AND u.Email IS NOT NULL AND u.Name IS NOT NULL
+	  AND (p.CurrentMover IS NULL OR u.Id = p.CurrentMover)
+	  AND u.Id IN (SELECT User FROM Participants WHERE Poll = p.Id)
+	  AND u.Id NOT IN (SELECT User FROM Participants WHERE Poll = p.Id AND Round = p.CurrentRound)
+	  AND u.Id NOT IN (SELECT User FROM Reminders WHERE Poll = p.Id AND Round = p.CurrentRound)`
+
+	// reminderPolls is the SQL condition satisfied by the polls p for which reminders may be sent.
+	// The lead time must be given as the only parameter.
+	reminderPolls = `
+	      p.State = 'Active' AND p.CurrentRound > 0 AND p.CurrentRound < p.MaxNbRounds
+	  AND p.MaxRoundDuration > ?
+	  AND ` + reminderDeadline + ` > CURRENT_TIMESTAMP`
+)
+
+type reminderEmailData struct {
+	Sender   string
+	Name     string
+	Address  string
+	Title    string
+	Round    uint8 // Starting from 1.
+	Deadline time.Time
+	BaseURL  string
+	Segment  string
+}
+
+func (self *reminderService) ProcessOne(id uint32) error {
+	const (
+		qRecipients = `
+		  SELECT u.Id, u.Name, u.Email, p.Title, p.Salt, p.CurrentRound, ` + reminderDeadline + `
+		    FROM Polls AS p, Users AS u
+		   WHERE p.Id = ? AND ` + reminderPolls + `
+		     AND SUBTIME(` + reminderDeadline + `, ?) <= CURRENT_TIMESTAMP
+		     AND ` + reminderRecipients
+		qSent = `INSERT IGNORE INTO Reminders (Poll, Round, User) VALUE (?, ?, ?)`
+	)
+
+	tmpl, err := template.ParseFiles(filepath.Join(root.BaseDir, TmplBaseDir, "en", "reminder.txt"))
+	if err != nil {
+		return err
+	}
+
+	type recipient struct {
+		user uint32
+		data reminderEmailData
+	}
+	rows, err := db.DB.Query(qRecipients, id, self.leadTime, self.leadTime)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var recipients []recipient
+	for rows.Next() {
+		elt := recipient{data: reminderEmailData{Sender: emailConfig.Sender, BaseURL: server.BaseURL()}}
+		segment := salted.Segment{Id: id}
+		err = rows.Scan(&elt.user, &elt.data.Name, &elt.data.Address, &elt.data.Title, &segment.Salt,
+			&elt.data.Round, &elt.data.Deadline)
+		if err != nil {
+			return err
+		}
+		elt.data.Round += 1
+		if elt.data.Segment, err = segment.Encode(); err != nil {
+			return err
+		}
+		recipients = append(recipients, elt)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if len(recipients) == 0 {
+		return service.NothingToDoYet
+	}
+
+	for _, elt := range recipients {
+		// The reminder is recorded first, so that no participant receives it twice.
+		result, err := db.DB.Exec(qSent, id, elt.data.Round-1, elt.user)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			continue
+		}
+		err = self.sender.Send(emailsender.Email{
+			To:   []string{elt.data.Address},
+			Tmpl: tmpl,
+			Data: elt.data,
+		})
+		if err != nil {
+			self.Logger().Errorf("Error sending email: %v", err)
+		}
+	}
+	return nil
+}
+
+func (self *reminderService) CheckAll() service.Iterator {
+	const qCheckAll = `
+	  SELECT p.Id, SUBTIME(` + reminderDeadline + `, ?) AS Next
+	    FROM Polls AS p
+	   WHERE ` + reminderPolls + `
+	     AND EXISTS (SELECT 1 FROM Users AS u WHERE ` + reminderRecipients + `)
+	   ORDER BY Next ASC`
+	return service.SQLCheckAll(qCheckAll, self.leadTime, self.leadTime)
+}
+
+func (self *reminderService) CheckOne(id uint32) (ret time.Time) {
+	const qCheckOne = `
+	  SELECT SUBTIME(` + reminderDeadline + `, ?)
+	    FROM Polls AS p
+	   WHERE p.Id = ? AND ` + reminderPolls + `
+	     AND EXISTS (SELECT 1 FROM Users AS u WHERE ` + reminderRecipients + `)`
+
+	rows, err := db.DB.Query(qCheckOne, self.leadTime, id, self.leadTime)
+	if err != nil {
+		self.Logger().Errorf("CheckOne query error: %v", err)
+		return
+	}
+	defer rows.Close()
+	if rows.Next() {
+		if err = rows.Scan(&ret); err != nil {
+			self.Logger().Errorf("CheckOne scan error: %v", err)
+		}
+	}
+	return
+}
+
+func (self *reminderService) Interval() time.Duration {
+	return time.Hour
+}
+
+func (self *reminderService) Logger() slog.Leveled {
+	return self.logger
+}
+
+func (self *reminderService) FilterEvent(evt events.Event) bool {
+	switch evt.(type) {
+	case NextRoundEvent:
+		return true
+	}
+	return false
+}
+
+func (self *reminderService) ReceiveEvent(evt events.Event, ctrl service.RunnerControler) {
+	if e, ok := evt.(NextRoundEvent); ok {
+		ctrl.Schedule(e.Poll)
+	}
+}
@@ -0,0 +1,136 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JBoudou/Itero/mid/db"
+	dbt "github.com/JBoudou/Itero/mid/db/dbtest"
+	"github.com/JBoudou/Itero/mid/root"
+	"github.com/JBoudou/Itero/mid/service"
+	"github.com/JBoudou/Itero/pkg/emailsender"
+	estest "github.com/JBoudou/Itero/pkg/emailsender/emailsendertest"
+)
+
+func TestReminderService(t *testing.T) {
+	const (
+		qVerified = `UPDATE Users SET Verified = TRUE WHERE Id = ?`
+		qSoon     = `
+		  UPDATE Polls
+		     SET MaxRoundDuration = '24:00:00', Deadline = DATE_ADD(CURRENT_TIMESTAMP, INTERVAL 30 DAY),
+		         CurrentRoundStart = SUBTIME(CURRENT_TIMESTAMP, '20:00:00')
+		   WHERE Id = ?`
+	)
+
+	env := new(dbt.Env)
+	defer env.Close()
+	admin := env.CreateUserWith(t.Name() + "Admin")
+	voter := env.CreateUserWith(t.Name() + "Voter")
+	late := env.CreateUserWith(t.Name() + "Late")
+	pollId := env.CreatePoll("Reminder", admin, db.ElectorateAll)
+	for _, user := range []uint32{admin, voter, late} {
+		env.QuietExec(qVerified, user)
+		env.Vote(pollId, 0, user, 1)
+	}
+	env.NextRound(pollId)
+	env.Vote(pollId, 1, admin, 1)
+	env.Vote(pollId, 1, voter, 0)
+	env.QuietExec(qSoon, pollId)
+	env.Must(t)
+
+	var sent []emailsender.Email
+	locator := root.IoC.Sub()
+	mustt(t, locator.Bind(func() emailsender.Sender {
+		return estest.SenderMock{
+			T: t,
+			Send_: func(email emailsender.Email) error {
+				sent = append(sent, email)
+				return nil
+			},
+		}
+	}))
+	var svc service.Service
+	mustt(t, locator.Inject(ReminderService, &svc))
+
+	if svc.CheckOne(pollId).IsZero() {
+		t.Errorf("CheckOne returned zero time.")
+	}
+	mustt(t, svc.ProcessOne(pollId))
+	address := dbt.UserEmailWith(t.Name() + "Late")
+	if len(sent) != 1 || len(sent[0].To) != 1 || sent[0].To[0] != address {
+		t.Errorf("Wrong emails. Got %v. Expect one to %s.", sent, address)
+	}
+
+	// Reminders are not sent twice, even by a new instance of the service.
+	mustt(t, locator.Inject(ReminderService, &svc))
+	if err := svc.ProcessOne(pollId); !errors.Is(err, service.NothingToDoYet) {
+		t.Errorf("Second ProcessOne. Got %v. Expect NothingToDoYet.", err)
+	}
+	if !svc.CheckOne(pollId).IsZero() {
+		t.Errorf("CheckOne returned a time after all reminders have been sent.")
+	}
+	if len(sent) != 1 {
+		t.Errorf("Reminder sent twice.")
+	}
+}
+
+func TestReminderService_Sequential(t *testing.T) {
+	const (
+		qVerified = `UPDATE Users SET Verified = TRUE WHERE Id = ?`
+		qSoon     = `
+		  UPDATE Polls
+		     SET MaxRoundDuration = '24:00:00', Deadline = DATE_ADD(CURRENT_TIMESTAMP, INTERVAL 30 DAY),
+		         CurrentRoundStart = SUBTIME(CURRENT_TIMESTAMP, '20:00:00'),
+		         RoundType = ?, CurrentMover = ?
+		   WHERE Id = ?`
+	)
+
+	env := new(dbt.Env)
+	defer env.Close()
+	admin := env.CreateUserWith(t.Name() + "Admin")
+	mover := env.CreateUserWith(t.Name() + "Mover")
+	pollId := env.CreatePoll("Reminder", admin, db.ElectorateAll)
+	for _, user := range []uint32{admin, mover} {
+		env.QuietExec(qVerified, user)
+		env.Vote(pollId, 0, user, 1)
+	}
+	env.NextRound(pollId)
+	env.QuietExec(qSoon, db.RoundTypeSequential, mover, pollId)
+	env.Must(t)
+
+	var sent []emailsender.Email
+	locator := root.IoC.Sub()
+	mustt(t, locator.Bind(func() emailsender.Sender {
+		return estest.SenderMock{
+			T: t,
+			Send_: func(email emailsender.Email) error {
+				sent = append(sent, email)
+				return nil
+			},
+		}
+	}))
+	var svc service.Service
+	mustt(t, locator.Inject(ReminderService, &svc))
+
+	mustt(t, svc.ProcessOne(pollId))
+	address := dbt.UserEmailWith(t.Name() + "Mover")
+	if len(sent) != 1 || len(sent[0].To) != 1 || sent[0].To[0] != address {
+		t.Errorf("Wrong emails. Got %v. Expect one to %s.", sent, address)
+	}
+}
@@ -34,6 +34,7 @@ type startPollService struct {
 }
 
 // StartPollService is the factory for the service that starts polls that was created as waiting.
+// Proposals still pending when a poll starts are discarded.
 func StartPollService(evtManager events.Manager, log slog.StackedLeveled) *startPollService {
 	return &startPollService{
 		logger: log.With("StartPoll"),
@@ -42,14 +43,20 @@ func StartPollService(evtManager events.Manager, log slog.StackedLeveled) *start
 }
 
 func (self *startPollService) ProcessOne(id uint32) error {
-	const qUpdate = `
-	  UPDATE Polls SET State = 'Active'
-	   WHERE Id = ? AND State = 'Waiting'
-	     AND Start <= CURRENT_TIMESTAMP`
+	const (
+		qUpdate = `
+		  UPDATE Polls SET State = 'Active'
+		   WHERE Id = ? AND State = 'Waiting'
+		     AND Start <= CURRENT_TIMESTAMP`
+		qProposals = `DELETE FROM Proposals WHERE Poll = ? AND Alternative IS NULL`
+	)
 
 	if err := service.SQLProcessOne(qUpdate, id); err != nil {
 		return err
 	}
+	if _, err := db.DB.Exec(qProposals, id); err != nil {
+		self.Logger().Errorf("Error discarding proposals: %v", err)
+	}
 	return self.evtManager.Send(StartPollEvent{id})
 }
 
@@ -95,7 +102,7 @@ func (self *startPollService) Logger() slog.Leveled {
 
 func (self *startPollService) FilterEvent(evt events.Event) bool {
 	switch evt.(type) {
-	case CreatePollEvent:
+	case CreatePollEvent, EditPollEvent:
 		return true
 	}
 	return false
@@ -105,5 +112,7 @@ func (self *startPollService) ReceiveEvent(evt events.Event, ctrl service.Runner
 	switch e := evt.(type) {
 	case CreatePollEvent:
 		ctrl.Schedule(e.Poll)
+	case EditPollEvent:
+		ctrl.Schedule(e.Poll)
 	}
 }
@@ -213,6 +213,11 @@ func TestStartPollService_Events(t *testing.T) {
 			event:    CreatePollEvent{42},
 			schedule: []uint32{42},
 		},
+		{
+			name:     "EditPollEvent",
+			event:    EditPollEvent{42},
+			schedule: []uint32{42},
+		},
 		{
 			name:  "StartPollEvent",
 			event: StartPollEvent{42},
@@ -44,10 +44,25 @@ var Ok bool
 
 var (
 	PollTypeAcceptanceSet uint8
+	PollTypeRanked        uint8
+	PollTypeGrading       uint8
 
-	PollRulePlurality uint8
+	PollRulePlurality     uint8
+	PollRuleBorda         uint8
+	PollRuleCopeland      uint8
+	PollRuleSchulze       uint8
+	PollRuleInstantRunoff uint8
+
+	PollRuleGreedyApproval uint8
+	PollRuleEqualShares    uint8
+
+	PollRuleMajorityJudgment uint8
+	PollRuleRangeVoting      uint8
 
 	RoundTypeFreelyAsynchronous uint8
+	RoundTypeSequential         uint8
+	RoundTypeRandomSequential   uint8
+	RoundTypeSynchronous        uint8
 )
 
 // State is the enum type for the field State of table Polls.
@@ -66,6 +81,18 @@ const (
 	ElectorateAll      Electorate = "All"
 	ElectorateLogged   Electorate = "Logged"
 	ElectorateVerified Electorate = "Verified"
+	ElectorateInvited  Electorate = "Invited"
+)
+
+// Information is the enum type for the field Information of table Polls.
+type Information string
+
+const (
+	InformationCounts  Information = "Counts"
+	InformationRanking Information = "Ranking"
+	InformationWinner  Information = "Winner"
+	InformationTop     Information = "Top"
+	InformationNone    Information = "None"
 )
 
 var (
@@ -123,9 +150,30 @@ func init() {
 	DB.SetMaxOpenConns(cfg.MaxOpenConns)
 
 	// Fill variables
-	fillVars(logger, "PollType", map[string]*uint8{"Acceptance Set": &PollTypeAcceptanceSet})
-	fillVars(logger, "PollRule", map[string]*uint8{"Plurality": &PollRulePlurality})
-	fillVars(logger, "RoundType", map[string]*uint8{"Freely Asynchronous": &RoundTypeFreelyAsynchronous})
+	fillVars(logger, "PollType", map[string]*uint8{
+		"Acceptance Set": &PollTypeAcceptanceSet,
+		"Ranked":         &PollTypeRanked,
+		"Grading":        &PollTypeGrading,
+	})
+	fillVars(logger, "PollRule", map[string]*uint8{
+		"Plurality":      &PollRulePlurality,
+		"Borda":          &PollRuleBorda,
+		"Copeland":       &PollRuleCopeland,
+		"Schulze":        &PollRuleSchulze,
+		"Instant-Runoff": &PollRuleInstantRunoff,
+
+		"Greedy Approval": &PollRuleGreedyApproval,
+		"Equal Shares":    &PollRuleEqualShares,
+
+		"Majority Judgment": &PollRuleMajorityJudgment,
+		"Range Voting":      &PollRuleRangeVoting,
+	})
+	fillVars(logger, "RoundType", map[string]*uint8{
+		"Freely Asynchronous": &RoundTypeFreelyAsynchronous,
+		"Sequential":          &RoundTypeSequential,
+		"Random Sequential":   &RoundTypeRandomSequential,
+		"Synchronous":         &RoundTypeSynchronous,
+	})
 }
 
 // AddURLQuery adds a query string to an url string.
@@ -0,0 +1,369 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/JBoudou/Itero/pkg/rules"
+)
+
+// RuleFromDB returns the rule corresponding to a value of the field Rule of table Polls.
+// Plurality is returned for unknown values and for budget rules.
+func RuleFromDB(id uint8) rules.Rule {
+	switch id {
+	case PollRuleBorda:
+		return rules.Borda{}
+	case PollRuleCopeland:
+		return rules.Copeland{}
+	case PollRuleSchulze:
+		return rules.Schulze{}
+	case PollRuleInstantRunoff:
+		return rules.InstantRunoff{}
+	default:
+		return rules.Plurality{}
+	}
+}
+
+// BudgetRuleFromDB returns the budget rule corresponding to a value of the field Rule of table
+// Polls. GreedyApproval is returned for values that do not correspond to budget rules.
+func BudgetRuleFromDB(id uint8) rules.BudgetRule {
+	switch id {
+	case PollRuleEqualShares:
+		return rules.EqualShares{}
+	default:
+		return rules.GreedyApproval{}
+	}
+}
+
+// GradeRuleFromDB returns the grading rule corresponding to a value of the field Rule of table
+// Polls. MajorityJudgment is returned for values that do not correspond to grading rules.
+func GradeRuleFromDB(id uint8) rules.GradeRule {
+	switch id {
+	case PollRuleRangeVoting:
+		return rules.RangeVoting{}
+	default:
+		return rules.MajorityJudgment{}
+	}
+}
+
+// LoadProfile retrieves the ballots of a round of a poll.
+// If the poll reports votes (field ReportVote), the last ballot of each participant up to the given
+// round is used. Otherwise only the ballots of the given round are used. Blank ballots are empty
+// ballots in the profile. The weight of each ballot is the weight of its author in the poll (1 by
+// default) plus the weights of the delegators resolved to its author (see ResolveDelegations).
+func LoadProfile(ctx context.Context, poll uint32, round uint8) (profile *rules.Profile, err error) {
+	profile, _, err = LoadProfileDelegated(ctx, poll, round)
+	return
+}
+
+// LoadProfileDelegated is like LoadProfile but also returns the number of ballots cast through
+// delegation.
+func LoadProfileDelegated(ctx context.Context, poll uint32, round uint8) (
+	profile *rules.Profile, delegated uint32, err error) {
+
+	const (
+		qPoll    = `SELECT NbChoices, ReportVote FROM Polls WHERE Id = ?`
+		qAbstain = `
+		  SELECT p.User, b.Alternative, b.Rank
+		    FROM Participants AS p
+		    LEFT JOIN Ballots AS b ON (p.User, p.Poll, p.Round) = (b.User, b.Poll, b.Round)
+		   WHERE p.Poll = ? AND p.Round = ?
+		   ORDER BY p.User`
+		qReport = `
+		  SELECT p.User, b.Alternative, b.Rank
+		    FROM (
+		           SELECT User, Poll, MAX(Round) AS Round
+		             FROM Participants
+		            WHERE Poll = ? AND Round <= ?
+		            GROUP BY User, Poll
+		         ) AS p
+		    LEFT JOIN Ballots AS b ON (p.User, p.Poll, p.Round) = (b.User, b.Poll, b.Round)
+		   WHERE NOT EXISTS (
+		           SELECT 1 FROM Delegations AS d
+		            WHERE d.Poll = p.Poll AND d.User = p.User AND d.Round > p.Round AND d.Round <= ?
+		         )
+		   ORDER BY p.User`
+	)
+
+	profile = &rules.Profile{}
+	var reportVote bool
+	err = DB.QueryRowContext(ctx, qPoll, poll).Scan(&profile.NbAlternatives, &reportVote)
+	if err != nil {
+		return
+	}
+
+	var rows *sql.Rows
+	if reportVote {
+		rows, err = DB.QueryContext(ctx, qReport, poll, round, round)
+	} else {
+		rows, err = DB.QueryContext(ctx, qAbstain, poll, round)
+	}
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	var users []uint32
+	var lastUser uint32
+	var ranks map[uint8]uint8
+	for rows.Next() {
+		var user uint32
+		var alternative, rank *uint8
+		if err = rows.Scan(&user, &alternative, &rank); err != nil {
+			return
+		}
+		if ranks == nil || user != lastUser {
+			ranks = make(map[uint8]uint8)
+			profile.Ballots = append(profile.Ballots, rules.Ballot{Ranks: ranks, Weight: 1})
+			users = append(users, user)
+			lastUser = user
+		}
+		if alternative != nil && rank != nil {
+			ranks[*alternative] = *rank
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	rows.Close()
+
+	weights, delegated, err := ballotWeights(ctx, poll, round, reportVote, users)
+	for i, user := range users {
+		profile.Ballots[i].Weight = weights[user]
+	}
+	return
+}
+
+// LoadGradeProfile retrieves the grading ballots of a round of a poll.
+// Rounds, weights and delegations are handled as by LoadProfile. Blank ballots are ballots without grades in
+// the profile.
+func LoadGradeProfile(ctx context.Context, poll uint32, round uint8) (profile *rules.GradeProfile, err error) {
+	profile, _, err = LoadGradeProfileDelegated(ctx, poll, round)
+	return
+}
+
+// LoadGradeProfileDelegated is like LoadGradeProfile but also returns the number of ballots cast
+// through delegation.
+func LoadGradeProfileDelegated(ctx context.Context, poll uint32, round uint8) (
+	profile *rules.GradeProfile, delegated uint32, err error) {
+
+	const (
+		qPoll = `
+		  SELECT p.NbChoices, p.ReportVote, (SELECT COUNT(*) FROM Grades AS g WHERE g.Poll = p.Id)
+		    FROM Polls AS p WHERE p.Id = ?`
+		qAbstain = `
+		  SELECT p.User, b.Alternative, b.Grade
+		    FROM Participants AS p
+		    LEFT JOIN GradeBallots AS b ON (p.User, p.Poll, p.Round) = (b.User, b.Poll, b.Round)
+		   WHERE p.Poll = ? AND p.Round = ?
+		   ORDER BY p.User`
+		qReport = `
+		  SELECT p.User, b.Alternative, b.Grade
+		    FROM (
+		           SELECT User, Poll, MAX(Round) AS Round
+		             FROM Participants
+		            WHERE Poll = ? AND Round <= ?
+		            GROUP BY User, Poll
+		         ) AS p
+		    LEFT JOIN GradeBallots AS b ON (p.User, p.Poll, p.Round) = (b.User, b.Poll, b.Round)
+		   WHERE NOT EXISTS (
+		           SELECT 1 FROM Delegations AS d
+		            WHERE d.Poll = p.Poll AND d.User = p.User AND d.Round > p.Round AND d.Round <= ?
+		         )
+		   ORDER BY p.User`
+	)
+
+	profile = &rules.GradeProfile{}
+	var reportVote bool
+	err = DB.QueryRowContext(ctx, qPoll, poll).Scan(&profile.NbAlternatives, &reportVote,
+		&profile.NbGrades)
+	if err != nil {
+		return
+	}
+
+	var rows *sql.Rows
+	if reportVote {
+		rows, err = DB.QueryContext(ctx, qReport, poll, round, round)
+	} else {
+		rows, err = DB.QueryContext(ctx, qAbstain, poll, round)
+	}
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	var users []uint32
+	var lastUser uint32
+	var grades map[uint8]uint8
+	for rows.Next() {
+		var user uint32
+		var alternative, grade *uint8
+		if err = rows.Scan(&user, &alternative, &grade); err != nil {
+			return
+		}
+		if grades == nil || user != lastUser {
+			grades = make(map[uint8]uint8)
+			profile.Ballots = append(profile.Ballots, rules.GradeBallot{Grades: grades, Weight: 1})
+			users = append(users, user)
+			lastUser = user
+		}
+		if alternative != nil && grade != nil {
+			grades[*alternative] = *grade
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	rows.Close()
+
+	weights, delegated, err := ballotWeights(ctx, poll, round, reportVote, users)
+	for i, user := range users {
+		profile.Ballots[i].Weight = weights[user]
+	}
+	return
+}
+
+// ResolveDelegations follows the delegations from each delegator until reaching a user in voters.
+// The result maps each delegator to the voter whose ballot is used instead of its own. Delegators
+// whose chain of delegations ends on a user that did not vote, or contains a cycle, are absent
+// from the result.
+func ResolveDelegations(delegations map[uint32]uint32, voters map[uint32]bool) map[uint32]uint32 {
+	ret := make(map[uint32]uint32, len(delegations))
+	for delegator := range delegations {
+		visited := map[uint32]bool{delegator: true}
+		current := delegator
+		for {
+			next, ok := delegations[current]
+			if !ok || visited[next] {
+				break
+			}
+			if voters[next] {
+				ret[delegator] = next
+				break
+			}
+			visited[next] = true
+			current = next
+		}
+	}
+	return ret
+}
+
+// loadDelegations retrieves the delegations used for a round of a poll. If the poll reports votes,
+// the last delegation of each user up to the given round is used, unless the user voted directly
+// afterwards. Otherwise only the delegations of the given round are used.
+func loadDelegations(ctx context.Context, poll uint32, round uint8, reportVote bool) (
+	delegations map[uint32]uint32, err error) {
+
+	const (
+		qAbstain = `SELECT User, Delegate FROM Delegations WHERE Poll = ? AND Round = ?`
+		qReport  = `
+		  SELECT d.User, d.Delegate
+		    FROM Delegations AS d, (
+		           SELECT User, MAX(Round) AS Round
+		             FROM Delegations
+		            WHERE Poll = ? AND Round <= ?
+		            GROUP BY User
+		         ) AS m
+		   WHERE d.Poll = ? AND (d.User, d.Round) = (m.User, m.Round)
+		     AND NOT EXISTS (
+		           SELECT 1 FROM Participants AS p
+		            WHERE p.Poll = d.Poll AND p.User = d.User AND p.Round > d.Round AND p.Round <= ?
+		         )`
+	)
+
+	var rows *sql.Rows
+	if reportVote {
+		rows, err = DB.QueryContext(ctx, qReport, poll, round, poll, round)
+	} else {
+		rows, err = DB.QueryContext(ctx, qAbstain, poll, round)
+	}
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	delegations = make(map[uint32]uint32)
+	for rows.Next() {
+		var user, delegate uint32
+		if err = rows.Scan(&user, &delegate); err != nil {
+			return
+		}
+		delegations[user] = delegate
+	}
+	err = rows.Err()
+	return
+}
+
+// LoadWeights retrieves the weights of the users in a poll. Users absent from the result have
+// weight 1.
+func LoadWeights(ctx context.Context, poll uint32) (weights map[uint32]float64, err error) {
+	const qWeights = `SELECT User, Weight FROM Weights WHERE Poll = ?`
+	rows, err := DB.QueryContext(ctx, qWeights, poll)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	weights = make(map[uint32]float64)
+	for rows.Next() {
+		var user uint32
+		var weight float64
+		if err = rows.Scan(&user, &weight); err != nil {
+			return
+		}
+		weights[user] = weight
+	}
+	err = rows.Err()
+	return
+}
+
+// ballotWeights returns, for each user in voters, the weight of its ballot: its own weight plus
+// the weights of the delegators resolved to that user. It also returns the total number of resolved
+// delegations.
+func ballotWeights(ctx context.Context, poll uint32, round uint8, reportVote bool,
+	voters []uint32) (weights map[uint32]float64, delegated uint32, err error) {
+
+	own, err := LoadWeights(ctx, poll)
+	if err != nil {
+		return
+	}
+	weightOf := func(user uint32) float64 {
+		if weight, ok := own[user]; ok {
+			return weight
+		}
+		return 1
+	}
+
+	delegations, err := loadDelegations(ctx, poll, round, reportVote)
+	if err != nil {
+		return
+	}
+
+	weights = make(map[uint32]float64, len(voters))
+	voterSet := make(map[uint32]bool, len(voters))
+	for _, user := range voters {
+		voterSet[user] = true
+		weights[user] = weightOf(user)
+	}
+	for delegator, voter := range ResolveDelegations(delegations, voterSet) {
+		weights[voter] += weightOf(delegator)
+		delegated += 1
+	}
+	return
+}
@@ -0,0 +1,245 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/JBoudou/Itero/pkg/rules"
+)
+
+func TestRuleFromDB(t *testing.T) {
+	precheck(t)
+
+	tests := []struct {
+		id     uint8
+		expect rules.Rule
+	}{
+		{id: PollRulePlurality, expect: rules.Plurality{}},
+		{id: PollRuleBorda, expect: rules.Borda{}},
+		{id: PollRuleCopeland, expect: rules.Copeland{}},
+		{id: PollRuleSchulze, expect: rules.Schulze{}},
+		{id: PollRuleInstantRunoff, expect: rules.InstantRunoff{}},
+	}
+	for _, tt := range tests {
+		if got := RuleFromDB(tt.id); got != tt.expect {
+			t.Errorf("Wrong rule for %d. Got %T. Expect %T.", tt.id, got, tt.expect)
+		}
+	}
+}
+
+func TestBudgetRuleFromDB(t *testing.T) {
+	precheck(t)
+
+	tests := []struct {
+		id     uint8
+		expect rules.BudgetRule
+	}{
+		{id: PollRulePlurality, expect: rules.GreedyApproval{}},
+		{id: PollRuleGreedyApproval, expect: rules.GreedyApproval{}},
+		{id: PollRuleEqualShares, expect: rules.EqualShares{}},
+	}
+	for _, tt := range tests {
+		if got := BudgetRuleFromDB(tt.id); got != tt.expect {
+			t.Errorf("Wrong rule for %d. Got %T. Expect %T.", tt.id, got, tt.expect)
+		}
+	}
+}
+
+func TestGradeRuleFromDB(t *testing.T) {
+	precheck(t)
+
+	tests := []struct {
+		id     uint8
+		expect rules.GradeRule
+	}{
+		{id: PollRulePlurality, expect: rules.MajorityJudgment{}},
+		{id: PollRuleMajorityJudgment, expect: rules.MajorityJudgment{}},
+		{id: PollRuleRangeVoting, expect: rules.RangeVoting{}},
+	}
+	for _, tt := range tests {
+		if got := GradeRuleFromDB(tt.id); got != tt.expect {
+			t.Errorf("Wrong rule for %d. Got %T. Expect %T.", tt.id, got, tt.expect)
+		}
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	precheck(t)
+
+	const (
+		qInsertUser  = `INSERT INTO Users (Name, Email, Passwd) VALUE (?,?,?)`
+		qDeleteUser  = `DELETE FROM Users WHERE Id = ?`
+		qInsertPoll  = `INSERT INTO Polls (Title, Admin, Salt, NbChoices, ReportVote) VALUE (?,?,42,3,?)`
+		qDeletePoll  = `DELETE FROM Polls WHERE Id = ?`
+		qInsertAlt   = `INSERT INTO Alternatives (Poll, Id, Name) VALUE (?,?,?)`
+		qNextRound   = `UPDATE Polls SET CurrentRound = CurrentRound + 1 WHERE Id = ?`
+		qParticipate = `INSERT INTO Participants (User, Poll, Round) VALUE (?,?,?)`
+		qVote        = `INSERT INTO Ballots (User, Poll, Round, Alternative, Rank) VALUE (?,?,?,?,?)`
+	)
+
+	result, err := DB.Exec(qInsertUser, t.Name(), t.Name()+"@example.com", "123456")
+	mustt(t, err)
+	uid, err := IdFromResult(result)
+	mustt(t, err)
+	defer func() { DB.Exec(qDeleteUser, uid) }()
+
+	for _, report := range []bool{false, true} {
+		result, err = DB.Exec(qInsertPoll, t.Name(), uid, report)
+		mustt(t, err)
+		pid, err := IdFromResult(result)
+		mustt(t, err)
+		defer func() { DB.Exec(qDeletePoll, pid) }()
+		for i, name := range []string{"A", "B", "C"} {
+			_, err = DB.Exec(qInsertAlt, pid, i, name)
+			mustt(t, err)
+		}
+
+		// Vote C > A on round 0, then nothing on round 1.
+		_, err = DB.Exec(qParticipate, uid, pid, 0)
+		mustt(t, err)
+		_, err = DB.Exec(qVote, uid, pid, 0, 2, 1)
+		mustt(t, err)
+		_, err = DB.Exec(qVote, uid, pid, 0, 0, 2)
+		mustt(t, err)
+		_, err = DB.Exec(qNextRound, pid)
+		mustt(t, err)
+
+		ballot := rules.Ballot{Ranks: map[uint8]uint8{2: 1, 0: 2}, Weight: 1}
+		got, err := LoadProfile(context.Background(), pid, 0)
+		mustt(t, err)
+		expect := &rules.Profile{NbAlternatives: 3, Ballots: []rules.Ballot{ballot}}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("Round 0, report %t. Got %v. Expect %v.", report, got, expect)
+		}
+
+		got, err = LoadProfile(context.Background(), pid, 1)
+		mustt(t, err)
+		expect = &rules.Profile{NbAlternatives: 3}
+		if report {
+			expect.Ballots = []rules.Ballot{ballot}
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("Round 1, report %t. Got %v. Expect %v.", report, got, expect)
+		}
+	}
+}
+
+func TestLoadGradeProfile(t *testing.T) {
+	precheck(t)
+
+	const (
+		qInsertUser  = `INSERT INTO Users (Name, Email, Passwd) VALUE (?,?,?)`
+		qDeleteUser  = `DELETE FROM Users WHERE Id = ?`
+		qInsertPoll  = `INSERT INTO Polls (Title, Admin, Salt, NbChoices, Type) VALUE (?,?,42,2,?)`
+		qDeletePoll  = `DELETE FROM Polls WHERE Id = ?`
+		qInsertAlt   = `INSERT INTO Alternatives (Poll, Id, Name) VALUE (?,?,?)`
+		qInsertGrade = `INSERT INTO Grades (Poll, Id, Name) VALUE (?,?,?)`
+		qParticipate = `INSERT INTO Participants (User, Poll, Round) VALUE (?,?,?)`
+		qVote        = `INSERT INTO GradeBallots (User, Poll, Round, Alternative, Grade) VALUE (?,?,?,?,?)`
+	)
+
+	result, err := DB.Exec(qInsertUser, t.Name(), t.Name()+"@example.com", "123456")
+	mustt(t, err)
+	uid, err := IdFromResult(result)
+	mustt(t, err)
+	defer func() { DB.Exec(qDeleteUser, uid) }()
+
+	result, err = DB.Exec(qInsertPoll, t.Name(), uid, PollTypeGrading)
+	mustt(t, err)
+	pid, err := IdFromResult(result)
+	mustt(t, err)
+	defer func() { DB.Exec(qDeletePoll, pid) }()
+	for i, name := range []string{"A", "B"} {
+		_, err = DB.Exec(qInsertAlt, pid, i, name)
+		mustt(t, err)
+	}
+	for i, name := range []string{"Bad", "Fair", "Good"} {
+		_, err = DB.Exec(qInsertGrade, pid, i, name)
+		mustt(t, err)
+	}
+
+	_, err = DB.Exec(qParticipate, uid, pid, 0)
+	mustt(t, err)
+	_, err = DB.Exec(qVote, uid, pid, 0, 0, 2)
+	mustt(t, err)
+	_, err = DB.Exec(qVote, uid, pid, 0, 1, 1)
+	mustt(t, err)
+
+	got, err := LoadGradeProfile(context.Background(), pid, 0)
+	mustt(t, err)
+	expect := &rules.GradeProfile{NbAlternatives: 2, NbGrades: 3, Ballots: []rules.GradeBallot{
+		{Grades: map[uint8]uint8{0: 2, 1: 1}, Weight: 1},
+	}}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got %v. Expect %v.", got, expect)
+	}
+}
+
+func TestResolveDelegations(t *testing.T) {
+	tests := []struct {
+		name        string
+		delegations map[uint32]uint32
+		voters      []uint32
+		expect      map[uint32]uint32
+	}{
+		{
+			name:        "Direct",
+			delegations: map[uint32]uint32{1: 2},
+			voters:      []uint32{2},
+			expect:      map[uint32]uint32{1: 2},
+		},
+		{
+			name:        "Transitive",
+			delegations: map[uint32]uint32{1: 2, 2: 3, 4: 2},
+			voters:      []uint32{3},
+			expect:      map[uint32]uint32{1: 3, 2: 3, 4: 3},
+		},
+		{
+			name:        "Dead end",
+			delegations: map[uint32]uint32{1: 2, 2: 3},
+			voters:      []uint32{4},
+			expect:      map[uint32]uint32{},
+		},
+		{
+			name:        "Cycle",
+			delegations: map[uint32]uint32{1: 2, 2: 3, 3: 1, 4: 1},
+			voters:      []uint32{5},
+			expect:      map[uint32]uint32{},
+		},
+		{
+			name:        "Voter in cycle",
+			delegations: map[uint32]uint32{1: 2, 2: 1},
+			voters:      []uint32{2},
+			expect:      map[uint32]uint32{1: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			voters := make(map[uint32]bool, len(tt.voters))
+			for _, voter := range tt.voters {
+				voters[voter] = true
+			}
+			got := ResolveDelegations(tt.delegations, voters)
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("Got %v. Expect %v.", got, tt.expect)
+			}
+		})
+	}
+}
@@ -91,6 +91,12 @@ func CompressHandlerLevel(h http.Handler, level int) http.Handler {
 			return
 		}
 
+		// Streams must not be buffered by the compressor.
+		if strings.Contains(r.Header.Get("Accept"), eventStreamType) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
 		// detect what encoding to use
 		var encoding string
 		for _, curEnc := range strings.Split(r.Header.Get(acceptEncoding), ",") {
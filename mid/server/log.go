@@ -62,3 +62,10 @@ func (self *responseWithStatus) WriteHeader(statusCode int) {
 	self.status = statusCode
 	self.ResponseWriter.WriteHeader(statusCode)
 }
+
+// Flush implements http.Flusher, for streamed responses.
+func (self *responseWithStatus) Flush() {
+	if flusher, ok := self.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -140,6 +140,11 @@ func (self *Request) RemoteAddr() string {
 	return self.original.RemoteAddr
 }
 
+// Header returns the first value of the given header of the request, or the empty string.
+func (self *Request) Header(name string) string {
+	return self.original.Header.Get(name)
+}
+
 // AddSessionIdToRequest adds a session id to an http.Request.
 // This function is meant to be used by HTTP clients and tests.
 func AddSessionIdToRequest(req *http.Request, sessionId string) {
@@ -35,6 +35,11 @@ type Response interface {
 	// On success statuc code is http.StatusOK.
 	SendJSON(ctx context.Context, data interface{})
 
+	// SendCacheableJSON sends a JSON as response, that may be cached by the client as long as etag
+	// does not change. If the request already has the given etag, status code is
+	// http.StatusNotModified and no body is sent.
+	SendCacheableJSON(ctx context.Context, req *Request, etag string, data interface{})
+
 	// SendError sends an error as response.
 	// If the error is an HttpError, its code and msg are used in the HTPP response.
 	// Also log the error.
@@ -48,6 +53,10 @@ type Response interface {
 
 	// SendUnloggedId adds a cookie for unlogged users.
 	SendUnloggedId(ctx context.Context, user User, req *Request) error
+
+	// SendEventStream starts a stream of Server-Sent Events as response. The status code is
+	// http.StatusOK. No other method must be called on the Response afterwards.
+	SendEventStream(ctx context.Context) (EventStream, error)
 }
 
 type response struct {
@@ -70,6 +79,24 @@ func (self response) SendJSON(ctx context.Context, data interface{}) {
 	}
 }
 
+func (self response) SendCacheableJSON(ctx context.Context, req *Request, etag string,
+	data interface{}) {
+
+	if err := ctx.Err(); err != nil {
+		self.SendError(ctx, err)
+		return
+	}
+	etag = `"` + etag + `"`
+	header := self.writer.Header()
+	header.Set("Cache-Control", "private, no-cache")
+	header.Set("ETag", etag)
+	if req.original != nil && req.original.Header.Get("If-None-Match") == etag {
+		self.writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+	self.SendJSON(ctx, data)
+}
+
 func (self response) SendError(ctx context.Context, err error) {
 	send := func(statusCode int, msg string) {
 		http.Error(self.writer, msg, statusCode)
@@ -520,3 +520,73 @@ func findCookie(cookies []*http.Cookie, name string) (found *http.Cookie) {
 	}
 	return
 }
+
+func TestResponse_SendCacheableJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		ifNoneMatch string
+		expectCode  int
+	}{
+		{
+			name:       "Fresh",
+			ctx:        context.Background(),
+			expectCode: http.StatusOK,
+		},
+		{
+			name:        "Other etag",
+			ctx:         context.Background(),
+			ifNoneMatch: `"bar"`,
+			expectCode:  http.StatusOK,
+		},
+		{
+			name:        "Not modified",
+			ctx:         context.Background(),
+			ifNoneMatch: `"foo"`,
+			expectCode:  http.StatusNotModified,
+		},
+		{
+			name:       "Canceled",
+			ctx:        canceledContext(),
+			expectCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mock := httptest.NewRecorder()
+			self := response{
+				writer: mock,
+			}
+			original := httptest.NewRequest("GET", "/origin", nil)
+			if tt.ifNoneMatch != "" {
+				original.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			ctx := slog.CtxSaveLogger(tt.ctx, &slog.WithStack{Target: t})
+			self.SendCacheableJSON(ctx, &Request{original: original}, "foo", 42)
+
+			result := mock.Result()
+			if result.StatusCode != tt.expectCode {
+				t.Errorf("Wrong status %d. Expect %d.", result.StatusCode, tt.expectCode)
+			}
+			if tt.expectCode >= 400 {
+				return
+			}
+			if etag := result.Header.Get("ETag"); etag != `"foo"` {
+				t.Errorf("Wrong ETag header. Got %s.", etag)
+			}
+			var buff bytes.Buffer
+			if _, err := buff.ReadFrom(result.Body); err != nil {
+				t.Fatalf("Error reading body: %s", err.Error())
+			}
+			expectBody := "42"
+			if tt.expectCode == http.StatusNotModified {
+				expectBody = ""
+			}
+			if got := buff.String(); got != expectBody {
+				t.Errorf("Wrong body. Got %s. Expect %s.", got, expectBody)
+			}
+		})
+	}
+}
@@ -27,10 +27,12 @@ type ResponseSpy struct {
 	Backend     server.Response
 	T           *testing.T
 	JsonFct     func(*testing.T, context.Context, interface{})
+	CacheFct    func(*testing.T, context.Context, *server.Request, string, interface{})
 	ErrorFct    func(*testing.T, context.Context, error)
 	RedirectFct func(*testing.T, context.Context, *server.Request, string)
 	LoginFct    func(*testing.T, context.Context, server.User, *server.Request, interface{})
 	UnloggedFct func(*testing.T, context.Context, server.User, *server.Request) error
+	StreamFct   func(*testing.T, context.Context)
 }
 
 func (self ResponseSpy) SendJSON(ctx context.Context, data interface{}) {
@@ -41,6 +43,16 @@ func (self ResponseSpy) SendJSON(ctx context.Context, data interface{}) {
 	self.Backend.SendJSON(ctx, data)
 }
 
+func (self ResponseSpy) SendCacheableJSON(ctx context.Context, req *server.Request, etag string,
+	data interface{}) {
+
+	self.T.Helper()
+	if self.CacheFct != nil {
+		self.CacheFct(self.T, ctx, req, etag, data)
+	}
+	self.Backend.SendCacheableJSON(ctx, req, etag, data)
+}
+
 func (self ResponseSpy) SendError(ctx context.Context, err error) {
 	self.T.Helper()
 	if self.ErrorFct != nil {
@@ -76,3 +88,11 @@ func (self ResponseSpy) SendUnloggedId(ctx context.Context, user server.User,
 	}
 	return self.Backend.SendUnloggedId(ctx, user, request)
 }
+
+func (self ResponseSpy) SendEventStream(ctx context.Context) (server.EventStream, error) {
+	self.T.Helper()
+	if self.StreamFct != nil {
+		self.StreamFct(self.T, ctx)
+	}
+	return self.Backend.SendEventStream(ctx)
+}
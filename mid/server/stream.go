@@ -0,0 +1,93 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// eventStreamType is the media type of Server-Sent Events.
+const eventStreamType = "text/event-stream"
+
+// EventStream sends Server-Sent Events to the client. It is obtained from
+// Response.SendEventStream.
+type EventStream interface {
+	// Send sends an event whose data is the JSON encoding of data. The id is the one the client will
+	// send back in the Last-Event-ID header when reconnecting.
+	Send(ctx context.Context, id string, data interface{}) error
+
+	// Ping sends a comment, to keep the connection alive.
+	Ping(ctx context.Context) error
+}
+
+type eventStream struct {
+	writer  http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (self response) SendEventStream(ctx context.Context) (EventStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	flusher, ok := self.writer.(http.Flusher)
+	if !ok {
+		return nil, NewHttpError(http.StatusInternalServerError, "No stream",
+			"The response writer does not support flushing")
+	}
+
+	header := self.writer.Header()
+	header.Set("Content-Type", eventStreamType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	self.writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return eventStream{writer: self.writer, flusher: flusher}, nil
+}
+
+func (self eventStream) Send(ctx context.Context, id string, data interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	buff, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if strings.ContainsAny(id, "\r\n") {
+		return fmt.Errorf("Wrong event id %q", id)
+	}
+	if _, err = fmt.Fprintf(self.writer, "id: %s\ndata: %s\n\n", id, buff); err != nil {
+		return err
+	}
+	self.flusher.Flush()
+	return nil
+}
+
+func (self eventStream) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprint(self.writer, ":\n\n"); err != nil {
+		return err
+	}
+	self.flusher.Flush()
+	return nil
+}
@@ -0,0 +1,82 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponse_SendEventStream(t *testing.T) {
+	mock := httptest.NewRecorder()
+	resp := response{mock}
+	ctx := context.Background()
+
+	stream, err := resp.SendEventStream(ctx)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if err = stream.Send(ctx, "12", map[string]int{"A": 1}); err != nil {
+		t.Errorf("Send error: %v", err)
+	}
+	if err = stream.Ping(ctx); err != nil {
+		t.Errorf("Ping error: %v", err)
+	}
+	if err = stream.Send(ctx, "1\n2", 0); err == nil {
+		t.Errorf("Expect error for wrong id")
+	}
+
+	result := mock.Result()
+	if result.StatusCode != http.StatusOK {
+		t.Errorf("Wrong StatusCode %d", result.StatusCode)
+	}
+	if got := result.Header.Get("Content-Type"); got != eventStreamType {
+		t.Errorf("Wrong Content-Type. Got %s. Expect %s.", got, eventStreamType)
+	}
+	if !mock.Flushed {
+		t.Errorf("Not flushed")
+	}
+	const expect = "id: 12\ndata: {\"A\":1}\n\n:\n\n"
+	if got := mock.Body.String(); got != expect {
+		t.Errorf("Wrong body. Got %q. Expect %q.", got, expect)
+	}
+
+	if _, err = resp.SendEventStream(canceledContext()); err == nil {
+		t.Errorf("Expect error for canceled context")
+	}
+}
+
+func TestCompressHandlerEventStream(t *testing.T) {
+	w := httptest.NewRecorder()
+	Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: 1\n\n"))
+	})).ServeHTTP(w, &http.Request{
+		Method: "GET",
+		Header: http.Header{
+			acceptEncoding: []string{"gzip"},
+			"Accept":       []string{eventStreamType},
+		},
+	})
+	if enc := w.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("Wrong content encoding. Got %q. Expect none.", enc)
+	}
+	if got := w.Body.String(); got != "data: 1\n\n" {
+		t.Errorf("Wrong body %q.", got)
+	}
+}
@@ -51,11 +51,11 @@ func SQLProcessOne(query string, id uint32) error {
 }
 
 // SQLCheckAll is a helper function to implement Service.CheckAll.
-// It executes the query and return an iterator from the returned rows.
+// It executes the query with the given arguments and return an iterator from the returned rows.
 // The query must return a list of task, each task consisting in an id and a date.
 // See IteratorFromRows for details.
-func SQLCheckAll(query string) Iterator {
-	rows, err := db.DB.Query(query)
+func SQLCheckAll(query string, args ...interface{}) Iterator {
+	rows, err := db.DB.Query(query, args...)
 	if err != nil {
 		return errorIdDateIterator{err}
 	} else {
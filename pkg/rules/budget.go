@@ -0,0 +1,193 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"sort"
+)
+
+// BudgetRule selects the alternatives to fund.
+//
+// In budget polls, each alternative has a cost, and the total cost of the selected alternatives
+// must not exceed the budget. A ballot supports all the alternatives it ranks.
+type BudgetRule interface {
+	// Funded returns the alternatives to fund, in the order they are selected. The cost of
+	// alternative i is costs[i].
+	Funded(profile *Profile, costs []float64, budget float64) []uint8
+}
+
+// budgetEpsilon is the tolerance used when comparing amounts of money.
+const budgetEpsilon = 1e-9
+
+// approvalScores returns, for each alternative, the total weight of the ballots supporting it.
+func approvalScores(profile *Profile) []float64 {
+	scores := make([]float64, profile.NbAlternatives)
+	for _, ballot := range profile.Ballots {
+		for alt := range ballot.Ranks {
+			if alt < profile.NbAlternatives {
+				scores[alt] += ballot.Weight
+			}
+		}
+	}
+	return scores
+}
+
+// greedyComplete adds to funded all the alternatives that still fit in the budget, by decreasing
+// approval score. The remaining budget is returned.
+func greedyComplete(profile *Profile, costs []float64, remaining float64,
+	funded []uint8, selected []bool) ([]uint8, float64) {
+
+	for _, result := range sortedResults(approvalScores(profile)) {
+		alt := result.Alternative
+		if selected[alt] || costs[alt] > remaining+budgetEpsilon {
+			continue
+		}
+		funded = append(funded, alt)
+		selected[alt] = true
+		remaining -= costs[alt]
+	}
+	return funded, remaining
+}
+
+// GreedyApproval funds alternatives by decreasing approval score, skipping the alternatives that
+// do not fit in the remaining budget.
+type GreedyApproval struct{}
+
+// Funded implements BudgetRule.
+func (self GreedyApproval) Funded(profile *Profile, costs []float64, budget float64) []uint8 {
+	funded, _ := greedyComplete(profile, costs, budget, nil, make([]bool, profile.NbAlternatives))
+	return funded
+}
+
+// EqualShares implements the Method of Equal Shares with cost utilities.
+//
+// The budget is split between the ballots proportionally to their weight. An alternative is
+// affordable if its supporters can pay for it together. At each step, the affordable alternative
+// minimizing the maximal payment per unit of weight is funded, and its supporters pay for it as
+// equally as possible. When no alternative is affordable anymore, the remaining budget is used
+// as in GreedyApproval.
+type EqualShares struct{}
+
+// Funded implements BudgetRule.
+func (self EqualShares) Funded(profile *Profile, costs []float64, budget float64) []uint8 {
+	nbAlt := int(profile.NbAlternatives)
+	selected := make([]bool, nbAlt)
+	var funded []uint8
+
+	var totalWeight float64
+	for _, ballot := range profile.Ballots {
+		totalWeight += ballot.Weight
+	}
+	if totalWeight <= 0 {
+		funded, _ = greedyComplete(profile, costs, budget, funded, selected)
+		return funded
+	}
+	money := make([]float64, len(profile.Ballots))
+	for i, ballot := range profile.Ballots {
+		money[i] = budget * ballot.Weight / totalWeight
+	}
+
+	supporters := make([][]int, nbAlt)
+	for i, ballot := range profile.Ballots {
+		if ballot.Weight <= 0 {
+			continue
+		}
+		for alt := range ballot.Ranks {
+			if int(alt) < nbAlt {
+				supporters[alt] = append(supporters[alt], i)
+			}
+		}
+	}
+	for _, list := range supporters {
+		sort.Ints(list)
+	}
+	scores := approvalScores(profile)
+
+	spent := 0.
+	for {
+		best := -1
+		var bestRho float64
+		for alt := 0; alt < nbAlt; alt++ {
+			if selected[alt] || len(supporters[alt]) == 0 {
+				continue
+			}
+			rho, ok := equalSharesRho(profile, money, supporters[alt], costs[alt])
+			if !ok {
+				continue
+			}
+			if best < 0 || rho < bestRho-budgetEpsilon ||
+				(rho <= bestRho+budgetEpsilon && scores[alt] > scores[best]) {
+				best = alt
+				bestRho = rho
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		for _, i := range supporters[best] {
+			payment := bestRho * profile.Ballots[i].Weight
+			if payment > money[i] {
+				payment = money[i]
+			}
+			money[i] -= payment
+		}
+		funded = append(funded, uint8(best))
+		selected[best] = true
+		spent += costs[best]
+	}
+
+	funded, _ = greedyComplete(profile, costs, budget-spent, funded, selected)
+	return funded
+}
+
+// equalSharesRho computes the minimal payment per unit of weight such that the supporters can
+// pay cost. Each supporter i pays the minimum between rho times its weight and money[i].
+// The second returned value is false if the supporters cannot afford the cost.
+func equalSharesRho(profile *Profile, money []float64, supporters []int, cost float64) (float64, bool) {
+	if cost <= 0 {
+		return 0, true
+	}
+
+	var available, weight float64
+	for _, i := range supporters {
+		available += money[i]
+		weight += profile.Ballots[i].Weight
+	}
+	if available < cost-budgetEpsilon {
+		return 0, false
+	}
+
+	// Consider supporters by increasing money per unit of weight.
+	sorted := append([]int(nil), supporters...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		ia, ib := sorted[a], sorted[b]
+		return money[ia]*profile.Ballots[ib].Weight < money[ib]*profile.Ballots[ia].Weight
+	})
+	remaining := cost
+	for _, i := range sorted {
+		ballotWeight := profile.Ballots[i].Weight
+		rho := remaining / weight
+		if rho*ballotWeight <= money[i]+budgetEpsilon {
+			return rho, true
+		}
+		remaining -= money[i]
+		weight -= ballotWeight
+	}
+	// Only reachable because of rounding errors.
+	return 0, false
+}
@@ -0,0 +1,110 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"reflect"
+	"testing"
+)
+
+type budgetTest struct {
+	name    string
+	profile Profile
+	costs   []float64
+	budget  float64
+	expect  []uint8
+}
+
+func runBudgetTests(t *testing.T, rule BudgetRule, tests []budgetTest) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rule.Funded(&tt.profile, tt.costs, tt.budget)
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("Got %v. Expect %v.", got, tt.expect)
+			}
+		})
+	}
+}
+
+var majorityProfile = Profile{NbAlternatives: 3, Ballots: []Ballot{
+	approval(6, "AB"), approval(4, "C"),
+}}
+
+func TestGreedyApproval(t *testing.T) {
+	runBudgetTests(t, GreedyApproval{}, []budgetTest{
+		{
+			name:    "Majority",
+			profile: majorityProfile,
+			costs:   []float64{60, 40, 40},
+			budget:  100,
+			expect:  []uint8{0, 1},
+		},
+		{
+			name: "Skip expensive",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				approval(3, "A"), approval(2, "B"), approval(1, "C"),
+			}},
+			costs:  []float64{120, 50, 50},
+			budget: 100,
+			expect: []uint8{1, 2},
+		},
+		{
+			name:    "No ballot",
+			profile: Profile{NbAlternatives: 2},
+			costs:   []float64{1, 1},
+			budget:  1,
+			expect:  []uint8{0},
+		},
+	})
+}
+
+func TestEqualShares(t *testing.T) {
+	runBudgetTests(t, EqualShares{}, []budgetTest{
+		{
+			name:    "Proportional",
+			profile: majorityProfile,
+			costs:   []float64{60, 40, 40},
+			budget:  100,
+			expect:  []uint8{1, 2},
+		},
+		{
+			name: "Uneven payments",
+			profile: Profile{NbAlternatives: 2, Ballots: []Ballot{
+				approval(1, "A"), approval(1, "AB"),
+			}},
+			costs:  []float64{90, 10},
+			budget: 100,
+			expect: []uint8{1, 0},
+		},
+		{
+			name: "Greedy completion",
+			profile: Profile{NbAlternatives: 2, Ballots: []Ballot{
+				approval(1, "A"), approval(1, "B"),
+			}},
+			costs:  []float64{60, 10},
+			budget: 100,
+			expect: []uint8{1, 0},
+		},
+		{
+			name:    "No ballot",
+			profile: Profile{NbAlternatives: 2},
+			costs:   []float64{1, 1},
+			budget:  1,
+			expect:  []uint8{0},
+		},
+	})
+}
@@ -0,0 +1,114 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rules
+
+// pairwise returns the matrix of pairwise comparisons of the profile.
+// The value at [a][b] is the total weight of the ballots strictly preferring a to b.
+func pairwise(profile *Profile) [][]float64 {
+	nbAlt := int(profile.NbAlternatives)
+	ret := make([][]float64, nbAlt)
+	for a := range ret {
+		ret[a] = make([]float64, nbAlt)
+	}
+	for _, ballot := range profile.Ballots {
+		for a := 0; a < nbAlt; a++ {
+			if !ballot.Ranked(uint8(a)) {
+				continue
+			}
+			for b := 0; b < nbAlt; b++ {
+				if ballot.Prefers(uint8(a), uint8(b)) {
+					ret[a][b] += ballot.Weight
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// Copeland gives to each alternative one point for each other alternative it beats in pairwise
+// majority, and half a point for each other alternative it ties with.
+type Copeland struct{}
+
+// Outcome implements Rule.
+func (self Copeland) Outcome(profile *Profile) []Result {
+	matrix := pairwise(profile)
+	scores := make([]float64, profile.NbAlternatives)
+	for a := range matrix {
+		for b := range matrix {
+			if a == b {
+				continue
+			}
+			if matrix[a][b] > matrix[b][a] {
+				scores[a] += 1
+			} else if matrix[a][b] == matrix[b][a] {
+				scores[a] += 0.5
+			}
+		}
+	}
+	return sortedResults(scores)
+}
+
+// Schulze computes the strength of the strongest paths between each pair of alternatives, using
+// winning votes. The score of each alternative is the number of other alternatives it beats
+// according to the strongest paths. The winner is the alternative with the highest score.
+type Schulze struct{}
+
+// Outcome implements Rule.
+func (self Schulze) Outcome(profile *Profile) []Result {
+	matrix := pairwise(profile)
+	nbAlt := len(matrix)
+
+	paths := make([][]float64, nbAlt)
+	for a := range paths {
+		paths[a] = make([]float64, nbAlt)
+		for b := range paths[a] {
+			if a != b && matrix[a][b] > matrix[b][a] {
+				paths[a][b] = matrix[a][b]
+			}
+		}
+	}
+
+	for i := 0; i < nbAlt; i++ {
+		for a := 0; a < nbAlt; a++ {
+			if a == i {
+				continue
+			}
+			for b := 0; b < nbAlt; b++ {
+				if b == i || b == a {
+					continue
+				}
+				through := paths[a][i]
+				if paths[i][b] < through {
+					through = paths[i][b]
+				}
+				if through > paths[a][b] {
+					paths[a][b] = through
+				}
+			}
+		}
+	}
+
+	scores := make([]float64, nbAlt)
+	for a := range paths {
+		for b := range paths {
+			if paths[a][b] > paths[b][a] {
+				scores[a] += 1
+			}
+		}
+	}
+	return sortedResults(scores)
+}
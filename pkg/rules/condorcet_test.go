@@ -0,0 +1,81 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"testing"
+)
+
+// wikipediaSchulze is the example profile of the Wikipedia page on the Schulze method.
+var wikipediaSchulze = Profile{NbAlternatives: 5, Ballots: []Ballot{
+	ranking(5, "ACBED"),
+	ranking(5, "ADECB"),
+	ranking(8, "BEDAC"),
+	ranking(3, "CABED"),
+	ranking(7, "CAEBD"),
+	ranking(2, "CBADE"),
+	ranking(7, "DCEBA"),
+	ranking(8, "EBADC"),
+}}
+
+func TestCopeland(t *testing.T) {
+	runRuleTests(t, Copeland{}, []ruleTest{
+		{
+			name: "Condorcet winner",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				ranking(1, "ABC"), ranking(1, "BAC"), ranking(1, "ACB"),
+			}},
+			expect: []Result{{0, 2}, {1, 1}, {2, 0}},
+		},
+		{
+			name: "Cycle",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				ranking(1, "ABC"), ranking(1, "BCA"), ranking(1, "CAB"),
+			}},
+			expect: []Result{{0, 1}, {1, 1}, {2, 1}},
+		},
+		{
+			name: "Tie",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				ranking(1, "AB"), ranking(1, "BA"),
+			}},
+			expect: []Result{{0, 1.5}, {1, 1.5}, {2, 0}},
+		},
+	})
+}
+
+func TestSchulze(t *testing.T) {
+	runRuleTests(t, Schulze{}, []ruleTest{
+		{
+			name:    "Wikipedia",
+			profile: wikipediaSchulze,
+			expect:  []Result{{4, 4}, {0, 3}, {2, 2}, {1, 1}, {3, 0}},
+		},
+		{
+			name: "Cycle",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				ranking(1, "ABC"), ranking(1, "BCA"), ranking(1, "CAB"),
+			}},
+			expect: []Result{{0, 0}, {1, 0}, {2, 0}},
+		},
+		{
+			name:    "Empty",
+			profile: Profile{NbAlternatives: 2},
+			expect:  []Result{{0, 0}, {1, 0}},
+		},
+	})
+}
@@ -0,0 +1,127 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rules
+
+// GradeBallot is the ballot of a single participant in a grading poll.
+type GradeBallot struct {
+	// Grades maps each graded alternative to its grade. Higher grades are better.
+	// Alternatives absent from a non-empty map have grade 0, the worst one.
+	Grades map[uint8]uint8
+
+	// Weight is the weight of the ballot. It is usually 1.
+	Weight float64
+}
+
+// GradeProfile is the set of grading ballots for a round of a poll.
+// Alternatives are numbered from 0 to NbAlternatives - 1 and grades from 0 to NbGrades - 1.
+type GradeProfile struct {
+	NbAlternatives uint8
+	NbGrades       uint8
+	Ballots        []GradeBallot
+}
+
+// Distribution returns, for each alternative, the total weight of the ballots giving each grade
+// to that alternative. The result is indexed first by alternatives, then by grades. Ballots without
+// any grade are blank ballots, and are ignored.
+func (self *GradeProfile) Distribution() [][]float64 {
+	ret := make([][]float64, self.NbAlternatives)
+	for alt := range ret {
+		ret[alt] = make([]float64, self.NbGrades)
+	}
+	for _, ballot := range self.Ballots {
+		if len(ballot.Grades) == 0 {
+			continue
+		}
+		for alt := uint8(0); alt < self.NbAlternatives; alt++ {
+			grade := ballot.Grades[alt]
+			if grade < self.NbGrades {
+				ret[alt][grade] += ballot.Weight
+			}
+		}
+	}
+	return ret
+}
+
+// GradeRule computes the outcome of a grading profile.
+type GradeRule interface {
+	// Outcome returns one result for each alternative of the profile, sorted as by Rule.
+	Outcome(profile *GradeProfile) []Result
+}
+
+// MajorityJudgment ranks the alternatives by their majority gauge.
+//
+// The majority gauge of an alternative is defined from its lower median grade m, the proportion p
+// of ballots giving it a grade strictly above m, and the proportion q of ballots giving it a grade
+// strictly below m. The score is m + p/2 if p > q, and m - q/2 otherwise. Hence the score is
+// always within a quarter of the median grade, and alternatives are compared by their median grade
+// first.
+type MajorityJudgment struct{}
+
+// Outcome implements GradeRule.
+func (self MajorityJudgment) Outcome(profile *GradeProfile) []Result {
+	distribution := profile.Distribution()
+	scores := make([]float64, profile.NbAlternatives)
+	for alt, weights := range distribution {
+		var total float64
+		for _, weight := range weights {
+			total += weight
+		}
+		if total == 0 {
+			continue
+		}
+
+		// The lower median is the highest grade given or exceeded by more than half of the weight.
+		var median int
+		var above float64
+		for grade := len(weights) - 1; grade >= 0; grade-- {
+			if above+weights[grade] > total/2 {
+				median = grade
+				break
+			}
+			above += weights[grade]
+		}
+		below := total - above - weights[median]
+
+		p, q := above/total, below/total
+		if p > q {
+			scores[alt] = float64(median) + p/2
+		} else {
+			scores[alt] = float64(median) - q/2
+		}
+	}
+	return sortedResults(scores)
+}
+
+// RangeVoting ranks the alternatives by their mean grade.
+type RangeVoting struct{}
+
+// Outcome implements GradeRule.
+func (self RangeVoting) Outcome(profile *GradeProfile) []Result {
+	distribution := profile.Distribution()
+	scores := make([]float64, profile.NbAlternatives)
+	for alt, weights := range distribution {
+		var total, sum float64
+		for grade, weight := range weights {
+			total += weight
+			sum += float64(grade) * weight
+		}
+		if total > 0 {
+			scores[alt] = sum / total
+		}
+	}
+	return sortedResults(scores)
+}
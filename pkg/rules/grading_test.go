@@ -0,0 +1,108 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"reflect"
+	"testing"
+)
+
+// grading creates a grading ballot. The grade of alternative i is grades[i].
+func grading(weight float64, grades ...uint8) GradeBallot {
+	ret := GradeBallot{Grades: make(map[uint8]uint8, len(grades)), Weight: weight}
+	for alt, grade := range grades {
+		ret.Grades[uint8(alt)] = grade
+	}
+	return ret
+}
+
+type gradeRuleTest struct {
+	name    string
+	profile GradeProfile
+	expect  []Result
+}
+
+func runGradeRuleTests(t *testing.T, rule GradeRule, tests []gradeRuleTest) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rule.Outcome(&tt.profile)
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("Got %v. Expect %v.", got, tt.expect)
+			}
+		})
+	}
+}
+
+// gradeProfile is shared by tests of grading rules.
+// A has grades 3,3,2,0. B has grades 2,2,2,1. C has grades 4,1,1,1.
+var gradeProfile = GradeProfile{NbAlternatives: 3, NbGrades: 5, Ballots: []GradeBallot{
+	grading(1, 3, 2, 4), grading(1, 3, 2, 1), grading(1, 2, 2, 1), grading(1, 0, 1, 1),
+}}
+
+func TestGradeProfile_Distribution(t *testing.T) {
+	profile := GradeProfile{NbAlternatives: 2, NbGrades: 3, Ballots: []GradeBallot{
+		grading(1, 2, 1), grading(0.5, 2), {Weight: 1},
+	}}
+	expect := [][]float64{{0, 0, 1.5}, {0.5, 1, 0}}
+	if got := profile.Distribution(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got %v. Expect %v.", got, expect)
+	}
+}
+
+func TestMajorityJudgment(t *testing.T) {
+	runGradeRuleTests(t, MajorityJudgment{}, []gradeRuleTest{
+		{
+			name:    "Empty",
+			profile: GradeProfile{NbAlternatives: 2, NbGrades: 3},
+			expect:  []Result{{0, 0}, {1, 0}},
+		},
+		{
+			name:    "Simple",
+			profile: gradeProfile,
+			expect:  []Result{{0, 2.25}, {1, 1.875}, {2, 1.125}},
+		},
+		{
+			name: "Weighted",
+			profile: GradeProfile{NbAlternatives: 2, NbGrades: 3, Ballots: []GradeBallot{
+				grading(3, 0, 2), grading(1, 2, 1),
+			}},
+			expect: []Result{{1, 1.875}, {0, 0.125}},
+		},
+	})
+}
+
+func TestRangeVoting(t *testing.T) {
+	runGradeRuleTests(t, RangeVoting{}, []gradeRuleTest{
+		{
+			name:    "Empty",
+			profile: GradeProfile{NbAlternatives: 2, NbGrades: 3},
+			expect:  []Result{{0, 0}, {1, 0}},
+		},
+		{
+			name:    "Simple",
+			profile: gradeProfile,
+			expect:  []Result{{0, 2}, {1, 1.75}, {2, 1.75}},
+		},
+		{
+			name: "Weighted",
+			profile: GradeProfile{NbAlternatives: 2, NbGrades: 3, Ballots: []GradeBallot{
+				grading(3, 0, 2), grading(1, 2, 1),
+			}},
+			expect: []Result{{1, 1.75}, {0, 0.5}},
+		},
+	})
+}
@@ -0,0 +1,79 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rules
+
+// InstantRunoff repeatedly eliminates the alternative with the lowest number of first preferences
+// among the remaining alternatives. When a ballot has several remaining alternatives with the best
+// rank, its weight is divided equally between them. Ties for elimination are broken by eliminating
+// the alternative with the highest identifier.
+//
+// The score of each alternative is the number of eliminations it survived. Hence the winner has
+// score NbAlternatives - 1.
+type InstantRunoff struct{}
+
+// Outcome implements Rule.
+func (self InstantRunoff) Outcome(profile *Profile) []Result {
+	nbAlt := int(profile.NbAlternatives)
+	remaining := make([]bool, nbAlt)
+	for a := range remaining {
+		remaining[a] = true
+	}
+	scores := make([]float64, nbAlt)
+	counts := make([]float64, nbAlt)
+
+	for round := 0; round < nbAlt; round++ {
+		for a := range counts {
+			counts[a] = 0
+		}
+
+		for _, ballot := range profile.Ballots {
+			var best uint8 = 255
+			var nbBest int
+			for alt, rank := range ballot.Ranks {
+				if int(alt) >= nbAlt || !remaining[alt] {
+					continue
+				}
+				if rank < best {
+					best = rank
+					nbBest = 1
+				} else if rank == best {
+					nbBest += 1
+				}
+			}
+			if nbBest == 0 {
+				continue
+			}
+			share := ballot.Weight / float64(nbBest)
+			for alt, rank := range ballot.Ranks {
+				if int(alt) < nbAlt && remaining[alt] && rank == best {
+					counts[alt] += share
+				}
+			}
+		}
+
+		eliminated := -1
+		for a := nbAlt - 1; a >= 0; a-- {
+			if remaining[a] && (eliminated < 0 || counts[a] < counts[eliminated]) {
+				eliminated = a
+			}
+		}
+		remaining[eliminated] = false
+		scores[eliminated] = float64(round)
+	}
+
+	return sortedResults(scores)
+}
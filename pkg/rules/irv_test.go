@@ -0,0 +1,53 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"testing"
+)
+
+func TestInstantRunoff(t *testing.T) {
+	runRuleTests(t, InstantRunoff{}, []ruleTest{
+		{
+			name: "Transfer",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				ranking(4, "ABC"), ranking(3, "BCA"), ranking(2, "CBA"),
+			}},
+			// C is eliminated first, then A (4 against 5).
+			expect: []Result{{1, 2}, {0, 1}, {2, 0}},
+		},
+		{
+			name: "Exhausted ballots",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				ranking(3, "A"), ranking(2, "B"), ranking(2, "CA"),
+			}},
+			expect: []Result{{0, 2}, {1, 1}, {2, 0}},
+		},
+		{
+			name: "Tied ballot",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				approval(2, "AB"), ranking(1.5, "C"),
+			}},
+			expect: []Result{{0, 2}, {2, 1}, {1, 0}},
+		},
+		{
+			name:    "Empty",
+			profile: Profile{NbAlternatives: 3},
+			expect:  []Result{{0, 2}, {1, 1}, {2, 0}},
+		},
+	})
+}
@@ -0,0 +1,139 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package rules implements voting rules computing the outcome of a profile of ballots.
+//
+// A ballot ranks some of the alternatives, possibly with ties. Alternatives with lower ranks are
+// preferred. Unranked alternatives are less preferred than all ranked ones, and are all tied.
+// This representation covers uninominal ballots (a single alternative with rank 1), approval
+// ballots (all approved alternatives with rank 1) and full or partial rankings.
+//
+// Grading ballots, giving a grade to each alternative, have their own representation (GradeBallot)
+// and their own rules (GradeRule).
+package rules
+
+import (
+	"sort"
+)
+
+// Ballot is the preference of a single participant.
+type Ballot struct {
+	// Ranks maps each ranked alternative to its rank. Lower ranks are preferred.
+	Ranks map[uint8]uint8
+
+	// Weight is the weight of the ballot. It is usually 1.
+	Weight float64
+}
+
+// Ranked returns whether alt is ranked in the ballot.
+func (self Ballot) Ranked(alt uint8) bool {
+	_, ok := self.Ranks[alt]
+	return ok
+}
+
+// Prefers returns whether a is strictly preferred to b in the ballot.
+func (self Ballot) Prefers(a, b uint8) bool {
+	rankA, okA := self.Ranks[a]
+	if !okA {
+		return false
+	}
+	rankB, okB := self.Ranks[b]
+	return !okB || rankA < rankB
+}
+
+// Profile is the set of ballots for a round of a poll.
+// Alternatives are numbered from 0 to NbAlternatives - 1.
+type Profile struct {
+	NbAlternatives uint8
+	Ballots        []Ballot
+}
+
+// Result is the score of an alternative in an outcome.
+type Result struct {
+	Alternative uint8
+	Score       float64
+}
+
+// Rule computes the outcome of a profile.
+type Rule interface {
+	// Outcome returns one result for each alternative of the profile, sorted from the best
+	// alternative to the worst one. Alternatives with equal scores are sorted by increasing
+	// identifier.
+	Outcome(profile *Profile) []Result
+}
+
+// sortedResults creates the results from scores, and sorts them as required by Rule.
+func sortedResults(scores []float64) []Result {
+	ret := make([]Result, len(scores))
+	for i, score := range scores {
+		ret[i] = Result{Alternative: uint8(i), Score: score}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Score > ret[j].Score
+	})
+	return ret
+}
+
+// Plurality gives to each alternative the total weight of the ballots in which the alternative
+// has the best rank. For approval ballots, Plurality is the approval rule.
+type Plurality struct{}
+
+// Outcome implements Rule.
+func (self Plurality) Outcome(profile *Profile) []Result {
+	scores := make([]float64, profile.NbAlternatives)
+	for _, ballot := range profile.Ballots {
+		if len(ballot.Ranks) == 0 {
+			continue
+		}
+		var best uint8 = 255
+		for _, rank := range ballot.Ranks {
+			if rank < best {
+				best = rank
+			}
+		}
+		for alt, rank := range ballot.Ranks {
+			if rank == best && alt < profile.NbAlternatives {
+				scores[alt] += ballot.Weight
+			}
+		}
+	}
+	return sortedResults(scores)
+}
+
+// Borda gives to each alternative, for each ballot, as many points as the number of alternatives
+// strictly less preferred in that ballot, multiplied by the weight of the ballot.
+type Borda struct{}
+
+// Outcome implements Rule.
+func (self Borda) Outcome(profile *Profile) []Result {
+	nbAlt := int(profile.NbAlternatives)
+	scores := make([]float64, nbAlt)
+	for _, ballot := range profile.Ballots {
+		for a := 0; a < nbAlt; a++ {
+			if !ballot.Ranked(uint8(a)) {
+				continue
+			}
+			var worse float64
+			for b := 0; b < nbAlt; b++ {
+				if ballot.Prefers(uint8(a), uint8(b)) {
+					worse += 1
+				}
+			}
+			scores[a] += worse * ballot.Weight
+		}
+	}
+	return sortedResults(scores)
+}
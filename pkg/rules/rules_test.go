@@ -0,0 +1,135 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"reflect"
+	"testing"
+)
+
+// ranking creates a ballot from a list of alternatives, from the most preferred to the least
+// preferred. Alternatives are letters, starting from 'A'.
+func ranking(weight float64, alternatives string) Ballot {
+	ret := Ballot{Ranks: make(map[uint8]uint8, len(alternatives)), Weight: weight}
+	for i, alt := range alternatives {
+		ret.Ranks[uint8(alt-'A')] = uint8(i + 1)
+	}
+	return ret
+}
+
+// approval creates a ballot where all the given alternatives have rank 1.
+func approval(weight float64, alternatives string) Ballot {
+	ret := Ballot{Ranks: make(map[uint8]uint8, len(alternatives)), Weight: weight}
+	for _, alt := range alternatives {
+		ret.Ranks[uint8(alt-'A')] = 1
+	}
+	return ret
+}
+
+type ruleTest struct {
+	name    string
+	profile Profile
+	expect  []Result
+}
+
+func runRuleTests(t *testing.T, rule Rule, tests []ruleTest) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rule.Outcome(&tt.profile)
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("Got %v. Expect %v.", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestBallot_Prefers(t *testing.T) {
+	ballot := Ballot{Ranks: map[uint8]uint8{0: 1, 1: 2, 2: 2}, Weight: 1}
+	tests := []struct {
+		a, b   uint8
+		expect bool
+	}{
+		{a: 0, b: 1, expect: true},
+		{a: 1, b: 0, expect: false},
+		{a: 1, b: 2, expect: false},
+		{a: 2, b: 3, expect: true},
+		{a: 3, b: 2, expect: false},
+		{a: 3, b: 4, expect: false},
+	}
+	for _, tt := range tests {
+		if got := ballot.Prefers(tt.a, tt.b); got != tt.expect {
+			t.Errorf("Prefers(%d, %d). Got %t. Expect %t.", tt.a, tt.b, got, tt.expect)
+		}
+	}
+}
+
+func TestPlurality(t *testing.T) {
+	runRuleTests(t, Plurality{}, []ruleTest{
+		{
+			name:    "Empty",
+			profile: Profile{NbAlternatives: 3},
+			expect:  []Result{{0, 0}, {1, 0}, {2, 0}},
+		},
+		{
+			name: "Uninominal",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				ranking(1, "C"), ranking(1, "B"), ranking(1, "C"), {Weight: 1},
+			}},
+			expect: []Result{{2, 2}, {1, 1}, {0, 0}},
+		},
+		{
+			name: "Approval",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				approval(1, "AB"), approval(1, "B"), approval(2, "AC"),
+			}},
+			expect: []Result{{0, 3}, {1, 2}, {2, 2}},
+		},
+		{
+			name: "Ranked",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				ranking(1, "ABC"), ranking(1, "BAC"), ranking(1, "BC"),
+			}},
+			expect: []Result{{1, 2}, {0, 1}, {2, 0}},
+		},
+	})
+}
+
+func TestBorda(t *testing.T) {
+	runRuleTests(t, Borda{}, []ruleTest{
+		{
+			name: "Full rankings",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				ranking(1, "ABC"), ranking(1, "BCA"), ranking(1, "BAC"),
+			}},
+			expect: []Result{{1, 5}, {0, 3}, {2, 1}},
+		},
+		{
+			name: "Partial rankings",
+			profile: Profile{NbAlternatives: 4, Ballots: []Ballot{
+				ranking(1, "D"), ranking(1, "AB"), ranking(2, "C"),
+			}},
+			expect: []Result{{2, 6}, {0, 3}, {3, 3}, {1, 2}},
+		},
+		{
+			name: "Weighted approval",
+			profile: Profile{NbAlternatives: 3, Ballots: []Ballot{
+				approval(1, "AB"), approval(0.5, "C"),
+			}},
+			expect: []Result{{0, 1}, {1, 1}, {2, 1}},
+		},
+	})
+}
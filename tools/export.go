@@ -0,0 +1,417 @@
+// Itero - Online iterative vote application
+// Copyright (C) 2021 Joseph Boudou
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// Export writes anonymised data about terminated polls. See doc/Export.md for the format.
+type Export struct{}
+
+func (self Export) Cmd() string {
+	return "export"
+}
+
+func (self Export) String() string {
+	return "Export terminated polls as anonymised PrefLib and JSON files."
+}
+
+func init() {
+	AddCommand(Export{})
+}
+
+func (self Export) Run(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	dsn := flags.String("dsn", "", "Data source name of the database (mandatory).")
+	out := flags.String("out", ".", "Directory where the files are written.")
+	flags.Usage = func() {
+		fmt.Printf("Usage: %s export -dsn <dsn> [-out <dir>] <poll id>...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *dsn == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return
+	}
+
+	separator := "?"
+	if strings.Contains(*dsn, "?") {
+		separator = "&"
+	}
+	conn, err := sql.Open("mysql", *dsn+separator+"parseTime=true")
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+
+	rand.Seed(time.Now().UnixNano())
+	for _, arg := range flags.Args() {
+		id, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil {
+			fmt.Printf("Wrong poll id %s.\n", arg)
+			continue
+		}
+		poll, err := loadExportPoll(conn, uint32(id))
+		if err != nil {
+			fmt.Printf("Poll %d not exported: %v.\n", id, err)
+			continue
+		}
+		if err = poll.write(*out, uint32(id)); err != nil {
+			panic(err)
+		}
+		fmt.Printf("Poll %d exported.\n", id)
+	}
+}
+
+//
+// Data
+//
+
+type exportAlternative struct {
+	Id   uint8
+	Name string
+	Cost float64
+}
+
+type exportRound struct {
+	Round uint8
+	Start time.Time
+	File  string // Name of the PrefLib file.
+}
+
+// exportBallot is a ballot of a voter for a round. Ranking contains groups of alternatives, from
+// the most preferred to the least preferred one. Alternatives in the same group have the same
+// rank. For grading polls, Grades contains the grade of each alternative, or -1 for alternatives
+// without grade. Blank ballots have neither Ranking nor Grades.
+type exportBallot struct {
+	Round    uint8
+	Modified *time.Time `json:",omitempty"`
+	Blank    bool
+	Ranking  [][]uint8 `json:",omitempty"`
+	Grades   []int     `json:",omitempty"`
+}
+
+type exportVoter struct {
+	Pseudonym string
+	Ballots   []exportBallot
+}
+
+type exportPoll struct {
+	Title        string
+	Type         string
+	Rule         string
+	RoundType    string
+	ReportVote   bool
+	Created      time.Time
+	Alternatives []exportAlternative
+	Grades       []string `json:",omitempty"` // From the worst to the best.
+	Rounds       []exportRound
+	Voters       []exportVoter
+
+	grading bool
+}
+
+func loadExportPoll(conn *sql.DB, id uint32) (poll *exportPoll, err error) {
+	const (
+		qPoll = `
+		  SELECT p.Title, t.Label, r.Label, rt.Label, p.ReportVote, p.Created, p.CurrentRound,
+		         t.Label = 'Grading'
+		    FROM Polls AS p
+		    JOIN PollType AS t ON p.Type = t.Id
+		    JOIN PollRule AS r ON p.Rule = r.Id
+		    JOIN RoundType AS rt ON p.RoundType = rt.Id
+		   WHERE p.Id = ? AND p.State = 'Terminated'`
+		qAlternatives = `SELECT Id, Name, Cost FROM Alternatives WHERE Poll = ? ORDER BY Id`
+		qGrades       = `SELECT Name FROM Grades WHERE Poll = ? ORDER BY Id`
+		qRounds       = `SELECT Round, Start FROM Rounds WHERE Poll = ? AND Round < ? ORDER BY Round`
+	)
+
+	poll = &exportPoll{}
+	var nbRounds uint8
+	err = conn.QueryRow(qPoll, id).Scan(&poll.Title, &poll.Type, &poll.Rule, &poll.RoundType,
+		&poll.ReportVote, &poll.Created, &nbRounds, &poll.grading)
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("no terminated poll with this id")
+	}
+	if err != nil {
+		return
+	}
+
+	rows, err := conn.Query(qAlternatives, id)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var alt exportAlternative
+		if err = rows.Scan(&alt.Id, &alt.Name, &alt.Cost); err != nil {
+			rows.Close()
+			return
+		}
+		poll.Alternatives = append(poll.Alternatives, alt)
+	}
+	rows.Close()
+
+	if poll.grading {
+		if rows, err = conn.Query(qGrades, id); err != nil {
+			return
+		}
+		for rows.Next() {
+			var grade string
+			if err = rows.Scan(&grade); err != nil {
+				rows.Close()
+				return
+			}
+			poll.Grades = append(poll.Grades, grade)
+		}
+		rows.Close()
+	}
+
+	if rows, err = conn.Query(qRounds, id, nbRounds); err != nil {
+		return
+	}
+	for rows.Next() {
+		var round exportRound
+		if err = rows.Scan(&round.Round, &round.Start); err != nil {
+			rows.Close()
+			return
+		}
+		round.File = poll.prefLibName(id, round.Round)
+		poll.Rounds = append(poll.Rounds, round)
+	}
+	rows.Close()
+
+	err = poll.loadVoters(conn, id, nbRounds)
+	return
+}
+
+// loadVoters fills Voters. Pseudonyms are assigned in a random order.
+func (self *exportPoll) loadVoters(conn *sql.DB, id uint32, nbRounds uint8) error {
+	const qBallots = `
+	  SELECT p.User, p.Round, b.Alternative, b.%[2]s, b.Modified
+	    FROM Participants AS p
+	    LEFT JOIN %[1]s AS b ON (p.User, p.Poll, p.Round) = (b.User, b.Poll, b.Round)
+	   WHERE p.Poll = ? AND p.Round < ?
+	   ORDER BY p.User, p.Round, b.%[2]s, b.Alternative`
+
+	query := fmt.Sprintf(qBallots, "Ballots", "Rank")
+	if self.grading {
+		query = fmt.Sprintf(qBallots, "GradeBallots", "Grade")
+	}
+	rows, err := conn.Query(query, id, nbRounds)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var lastUser uint32
+	var voter *exportVoter
+	var ballot *exportBallot
+	var lastValue int
+	for rows.Next() {
+		var user uint32
+		var round uint8
+		var alternative, value sql.NullInt32
+		var modified sql.NullTime
+		if err = rows.Scan(&user, &round, &alternative, &value, &modified); err != nil {
+			return err
+		}
+
+		if voter == nil || user != lastUser {
+			self.Voters = append(self.Voters, exportVoter{})
+			voter = &self.Voters[len(self.Voters)-1]
+			lastUser = user
+			ballot = nil
+		}
+		if ballot == nil || round != ballot.Round {
+			voter.Ballots = append(voter.Ballots, exportBallot{Round: round, Blank: true})
+			ballot = &voter.Ballots[len(voter.Ballots)-1]
+		}
+		if !alternative.Valid || !value.Valid {
+			continue
+		}
+
+		if modified.Valid && (ballot.Modified == nil || modified.Time.After(*ballot.Modified)) {
+			tmp := modified.Time
+			ballot.Modified = &tmp
+		}
+		if self.grading {
+			if ballot.Blank {
+				ballot.Grades = make([]int, len(self.Alternatives))
+				for i := range ballot.Grades {
+					ballot.Grades[i] = -1
+				}
+			}
+			ballot.Grades[alternative.Int32] = int(value.Int32)
+		} else if ballot.Blank || int(value.Int32) != lastValue {
+			ballot.Ranking = append(ballot.Ranking, []uint8{uint8(alternative.Int32)})
+		} else {
+			last := len(ballot.Ranking) - 1
+			ballot.Ranking[last] = append(ballot.Ranking[last], uint8(alternative.Int32))
+		}
+		ballot.Blank = false
+		lastValue = int(value.Int32)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	rand.Shuffle(len(self.Voters), func(i, j int) {
+		self.Voters[i], self.Voters[j] = self.Voters[j], self.Voters[i]
+	})
+	for i := range self.Voters {
+		self.Voters[i].Pseudonym = "V" + strconv.Itoa(i+1)
+	}
+	return nil
+}
+
+//
+// Output
+//
+
+func (self *exportPoll) prefLibType() string {
+	switch {
+	case self.grading:
+		return "cat"
+	case self.Type == "Ranked":
+		return "soi"
+	default:
+		return "toi"
+	}
+}
+
+func (self *exportPoll) prefLibName(id uint32, round uint8) string {
+	return fmt.Sprintf("%d-%d.%s", id, round, self.prefLibType())
+}
+
+func (self *exportPoll) write(dir string, id uint32) error {
+	for _, round := range self.Rounds {
+		if err := self.writePrefLib(filepath.Join(dir, round.File), id, round.Round); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Create(filepath.Join(dir, fmt.Sprintf("%d.json", id)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(self)
+}
+
+// prefLibLine returns the PrefLib representation of a non-blank ballot, with alternatives numbered
+// from 1.
+func (self *exportPoll) prefLibLine(ballot *exportBallot) string {
+	group := func(alternatives []uint8, braces bool) string {
+		names := make([]string, len(alternatives))
+		for i, alt := range alternatives {
+			names[i] = strconv.Itoa(int(alt) + 1)
+		}
+		ret := strings.Join(names, ",")
+		if braces {
+			ret = "{" + ret + "}"
+		}
+		return ret
+	}
+
+	var groups []string
+	if self.grading {
+		// Categories are grades, from the best one to the worst one.
+		for grade := len(self.Grades) - 1; grade >= 0; grade-- {
+			var alternatives []uint8
+			for alt, got := range ballot.Grades {
+				if got == grade {
+					alternatives = append(alternatives, uint8(alt))
+				}
+			}
+			groups = append(groups, group(alternatives, true))
+		}
+	} else {
+		for _, alternatives := range ballot.Ranking {
+			groups = append(groups, group(alternatives, len(alternatives) > 1))
+		}
+	}
+	return strings.Join(groups, ",")
+}
+
+func (self *exportPoll) writePrefLib(path string, id uint32, round uint8) error {
+	counts := make(map[string]int)
+	nbVoters := 0
+	for i := range self.Voters {
+		for j := range self.Voters[i].Ballots {
+			ballot := &self.Voters[i].Ballots[j]
+			if ballot.Round == round && !ballot.Blank {
+				counts[self.prefLibLine(ballot)] += 1
+				nbVoters += 1
+			}
+		}
+	}
+	lines := make([]string, 0, len(counts))
+	for line := range counts {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if counts[lines[i]] != counts[lines[j]] {
+			return counts[lines[i]] > counts[lines[j]]
+		}
+		return lines[i] < lines[j]
+	})
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fmt.Fprintf(file, "# FILE NAME: %s\n", filepath.Base(path))
+	fmt.Fprintf(file, "# TITLE: %s\n", self.Title)
+	fmt.Fprintf(file, "# DESCRIPTION: Round %d of Itero poll %d.\n", round, id)
+	fmt.Fprintf(file, "# DATA TYPE: %s\n", self.prefLibType())
+	fmt.Fprintf(file, "# MODIFICATION TYPE: original\n")
+	fmt.Fprintf(file, "# NUMBER ALTERNATIVES: %d\n", len(self.Alternatives))
+	fmt.Fprintf(file, "# NUMBER VOTERS: %d\n", nbVoters)
+	if self.grading {
+		fmt.Fprintf(file, "# NUMBER UNIQUE PREFERENCES: %d\n", len(lines))
+		fmt.Fprintf(file, "# NUMBER CATEGORIES: %d\n", len(self.Grades))
+		for i := range self.Grades {
+			fmt.Fprintf(file, "# CATEGORY NAME %d: %s\n", i+1, self.Grades[len(self.Grades)-1-i])
+		}
+	} else {
+		fmt.Fprintf(file, "# NUMBER UNIQUE ORDERS: %d\n", len(lines))
+	}
+	for _, alt := range self.Alternatives {
+		fmt.Fprintf(file, "# ALTERNATIVE NAME %d: %s\n", alt.Id+1, alt.Name)
+	}
+	for _, line := range lines {
+		fmt.Fprintf(file, "%d: %s\n", counts[line], line)
+	}
+	return nil
+}